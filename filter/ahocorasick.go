@@ -0,0 +1,181 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+)
+
+// acNode is a state of the Aho-Corasick automaton.
+type acNode struct {
+	next    map[byte]int
+	fail    int
+	depth   int // Length of the prefix of the patterns represented by the state
+	pattern int // Index of the pattern ending at this state, -1 if none
+	output  int // Nearest state on the failure chain ending a pattern, -1 if none
+}
+
+// multiReplacer replaces several patterns in a single pass with leftmost-longest semantics: at each position the
+// leftmost match is replaced by the longest pattern starting there, the replaced text is not scanned again.
+type multiReplacer struct {
+	nodes    []acNode
+	patterns []string
+	values   []string
+}
+
+// newMultiReplacer builds the automaton of the patterns, the patterns must be unique and not empty.
+func newMultiReplacer(pairs map[string]string) *multiReplacer {
+	m := &multiReplacer{nodes: []acNode{{next: map[byte]int{}, pattern: -1, output: -1}}}
+
+	patterns := make([]string, 0, len(pairs))
+	for p := range pairs {
+		patterns = append(patterns, p)
+	}
+
+	// The automaton and the logs do not depend on the random order of the map
+	sort.Strings(patterns)
+
+	for _, p := range patterns {
+		m.add(p, pairs[p])
+	}
+
+	m.build()
+
+	return m
+}
+
+func (m *multiReplacer) add(pattern string, value string) {
+	state := 0
+
+	for i := 0; i < len(pattern); i++ {
+		next, ok := m.nodes[state].next[pattern[i]]
+		if !ok {
+			next = len(m.nodes)
+			m.nodes = append(m.nodes, acNode{next: map[byte]int{}, depth: i + 1, pattern: -1, output: -1})
+			m.nodes[state].next[pattern[i]] = next
+		}
+
+		state = next
+	}
+
+	m.nodes[state].pattern = len(m.patterns)
+	m.patterns = append(m.patterns, pattern)
+	m.values = append(m.values, value)
+}
+
+// build computes the failure and output links with a breadth first walk of the trie.
+func (m *multiReplacer) build() {
+	queue := []int{}
+
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for c, child := range m.nodes[state].next {
+			queue = append(queue, child)
+
+			fail := m.nodes[state].fail
+			for fail != 0 && !m.hasNext(fail, c) {
+				fail = m.nodes[fail].fail
+			}
+
+			if next, ok := m.nodes[fail].next[c]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+
+			f := m.nodes[child].fail
+			if m.nodes[f].pattern >= 0 {
+				m.nodes[child].output = f
+			} else {
+				m.nodes[child].output = m.nodes[f].output
+			}
+		}
+	}
+}
+
+func (m *multiReplacer) hasNext(state int, c byte) bool {
+	_, ok := m.nodes[state].next[c]
+
+	return ok
+}
+
+// step returns the state reached from state with c.
+func (m *multiReplacer) step(state int, c byte) int {
+	for state != 0 && !m.hasNext(state, c) {
+		state = m.nodes[state].fail
+	}
+
+	if next, ok := m.nodes[state].next[c]; ok {
+		return next
+	}
+
+	return 0
+}
+
+// longest returns the longest pattern ending at state, -1 if none.
+func (m *multiReplacer) longest(state int) int {
+	if m.nodes[state].pattern >= 0 {
+		return m.nodes[state].pattern
+	}
+
+	if out := m.nodes[state].output; out > 0 {
+		return m.nodes[out].pattern
+	}
+
+	return -1
+}
+
+// Replace returns s with all the leftmost-longest non overlapping matches replaced. The best match found since the
+// last replacement is kept while the automaton can still extend a match starting at or before it, then it is
+// replaced and the scan restarts at its end.
+func (m *multiReplacer) Replace(s string) string {
+	var b strings.Builder
+
+	cursor := 0 // Start of the text not written yet
+	best := -1  // Pattern of the best match since the cursor, -1 if none
+	bestStart := 0
+	state := 0
+
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			state = m.step(state, s[i])
+
+			if p := m.longest(state); p >= 0 {
+				start := i + 1 - len(m.patterns[p])
+				if best < 0 || start < bestStart || (start == bestStart && len(m.patterns[p]) > len(m.patterns[best])) {
+					best, bestStart = p, start
+				}
+			}
+
+			if best < 0 || i+1-m.nodes[state].depth <= bestStart {
+				continue
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		if cursor == 0 {
+			b.Grow(len(s))
+		}
+
+		b.WriteString(s[cursor:bestStart])
+		b.WriteString(m.values[best])
+		cursor = bestStart + len(m.patterns[best])
+
+		best, state = -1, 0
+		i = cursor - 1
+	}
+
+	if cursor == 0 {
+		return s
+	}
+
+	b.WriteString(s[cursor:])
+
+	return b.String()
+}
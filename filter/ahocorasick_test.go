@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_multiReplacer_Replace(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs map[string]string
+		s     string
+		want  string
+	}{
+		{
+			"no match",
+			map[string]string{"foo": "bar"},
+			"hello world",
+			"hello world",
+		},
+		{
+			"several patterns",
+			map[string]string{"cat": "dog", "red": "blue"},
+			"a red cat and a cat",
+			"a blue dog and a dog",
+		},
+		{
+			"longest at same position",
+			map[string]string{"http://old": "X", "http://old/shop": "Y"},
+			"http://old/shop http://old/home",
+			"Y X/home",
+		},
+		{
+			"leftmost wins over longer later match",
+			map[string]string{"abc": "1", "bcdef": "2"},
+			"abcdef",
+			"1def",
+		},
+		{
+			"match after a pending longer candidate",
+			map[string]string{"abcde": "1", "ab": "2", "cx": "3"},
+			"abcx",
+			"23",
+		},
+		{
+			"earlier longer match found after an inner one",
+			map[string]string{"bc": "1", "abcd": "2"},
+			"abcd abce",
+			"2 a1e",
+		},
+		{
+			"suffix patterns",
+			map[string]string{"he": "1", "she": "2", "hers": "3", "his": "4"},
+			"ushers and his",
+			"u2rs and 4",
+		},
+		{
+			"no rescan of replacement",
+			map[string]string{"a": "aa", "aa": "b"},
+			"aaa",
+			"baa",
+		},
+		{
+			"overlapping with shared prefix",
+			map[string]string{"aab": "1", "ab": "2"},
+			"aaab",
+			"a1",
+		},
+		{
+			"utf-8",
+			map[string]string{"café": "coffee", "é": "e"},
+			"un café élégant",
+			"un coffee elegant",
+		},
+		{
+			"empty text",
+			map[string]string{"a": "b"},
+			"",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMultiReplacer(tt.pairs).Replace(tt.s); got != tt.want {
+				t.Errorf("multiReplacer.Replace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkPairs returns n migration pairs and a body containing some of them.
+func benchmarkPairs(n int) (map[string]string, []replaceParameters, string) {
+	pairs := make(map[string]string, n)
+	rules := make([]replaceParameters, 0, n)
+
+	for i := 0; i < n; i++ {
+		from := fmt.Sprintf("http://legacy.example.com/page%04d.php", i)
+		to := fmt.Sprintf("/pages/%d", i)
+		pairs[from] = to
+		rules = append(rules, replaceParameters{from: from, to: to})
+	}
+
+	var b strings.Builder
+
+	for i := 0; b.Len() < 256*1024; i++ {
+		fmt.Fprintf(&b, `<p>Some text <a href="http://legacy.example.com/page%04d.php">link %d</a></p>`, (i*7)%(2*n), i)
+	}
+
+	return pairs, rules, b.String()
+}
+
+func BenchmarkDictionary(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		pairs, _, body := benchmarkPairs(n)
+		replacer := newMultiReplacer(pairs)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+
+			for i := 0; i < b.N; i++ {
+				replacer.Replace(body)
+			}
+		})
+	}
+}
+
+func BenchmarkDo(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		_, rules, body := benchmarkPairs(n)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+
+			for i := 0; i < b.N; i++ {
+				do("/", body, rules, false)
+			}
+		})
+	}
+}
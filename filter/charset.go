@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// Number of bytes of an HTML body where the meta charset declaration is looked for.
+const charsetPrescanSize = 1024
+
+var metaCharset = regexp.MustCompile( //nolint: gochecknoglobals
+	`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`,
+)
+
+func isHTML(contentType string) bool {
+	return strings.Contains(contentType, "html")
+}
+
+// bodyCharset returns the encoding declared in the Content-Type header or, for HTML, in the meta tag
+// of the beginning of the body. It returns nil for UTF-8, unknown or undeclared charsets.
+func bodyCharset(contentType string, head []byte) (encoding.Encoding, string) {
+	label := ""
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		label = params["charset"]
+	}
+
+	if label == "" && isHTML(contentType) {
+		if len(head) > charsetPrescanSize {
+			head = head[:charsetPrescanSize]
+		}
+
+		if m := metaCharset.FindSubmatch(head); m != nil {
+			label = string(m[1])
+		}
+	}
+
+	if label == "" {
+		return nil, ""
+	}
+
+	enc, name := charset.Lookup(label)
+	if enc == nil || name == "utf-8" {
+		return nil, name
+	}
+
+	return enc, name
+}
+
+// charsetEncoder returns the transformer converting back UTF-8 to the original charset,
+// characters not available in the charset are replaced by HTML entities for HTML bodies.
+func charsetEncoder(enc encoding.Encoding, contentType string) *encoding.Encoder {
+	if isHTML(contentType) {
+		return encoding.HTMLEscapeUnsupported(enc.NewEncoder())
+	}
+
+	return encoding.ReplaceUnsupported(enc.NewEncoder())
+}
@@ -0,0 +1,169 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_bodyCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		head        string
+		wantNil     bool
+		wantName    string
+	}{
+		{
+			"undeclared",
+			"text/html",
+			"<html><head></head></html>",
+			true,
+			"",
+		},
+		{
+			"utf-8 header",
+			"text/html; charset=UTF-8",
+			"",
+			true,
+			"utf-8",
+		},
+		{
+			"latin1 header",
+			"text/html; charset=ISO-8859-1",
+			"",
+			false,
+			"windows-1252",
+		},
+		{
+			"windows-1252 header",
+			"text/css; charset=windows-1252",
+			"",
+			false,
+			"windows-1252",
+		},
+		{
+			"meta charset",
+			"text/html",
+			`<html><head><meta charset="iso-8859-15"></head></html>`,
+			false,
+			"iso-8859-15",
+		},
+		{
+			"meta http-equiv",
+			"text/html",
+			`<html><head><META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=windows-1252"></head></html>`,
+			false,
+			"windows-1252",
+		},
+		{
+			"meta ignored for non HTML",
+			"application/javascript",
+			`var s = '<meta charset="iso-8859-1">'`,
+			true,
+			"",
+		},
+		{
+			"header before meta",
+			"text/html; charset=utf-8",
+			`<html><head><meta charset="iso-8859-1"></head></html>`,
+			true,
+			"utf-8",
+		},
+		{
+			"unknown charset",
+			"text/html; charset=klingon",
+			"",
+			true,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotName := bodyCharset(tt.contentType, []byte(tt.head))
+			if (got == nil) != tt.wantNil {
+				t.Errorf("bodyCharset() = %v, want nil %v", got, tt.wantNil)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("bodyCharset() name = %v, want %v", gotName, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestFilter_readAndReplaceBodyCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		rep         []replaceParameters
+		body        []byte
+		want        []byte
+	}{
+		{
+			"latin1",
+			"text/html; charset=ISO-8859-1",
+			[]replaceParameters{{from: "book", to: "résumé"}},
+			[]byte("caf\xe9 book"),
+			[]byte("caf\xe9 r\xe9sum\xe9"),
+		},
+		{
+			"latin1 meta",
+			"text/html",
+			[]replaceParameters{{from: "book", to: "résumé"}},
+			[]byte("<meta charset=\"iso-8859-1\">caf\xe9 book"),
+			[]byte("<meta charset=\"iso-8859-1\">caf\xe9 r\xe9sum\xe9"),
+		},
+		{
+			"utf-8",
+			"text/html; charset=utf-8",
+			[]replaceParameters{{from: "book", to: "résumé"}},
+			[]byte("caf\xc3\xa9 book"),
+			[]byte("caf\xc3\xa9 r\xc3\xa9sum\xc3\xa9"),
+		},
+		{
+			"unsupported character",
+			"text/html; charset=ISO-8859-1",
+			[]replaceParameters{{from: "book", to: "résumé"}, {from: " é", to: " →"}},
+			[]byte("caf\xe9 book \xe9"),
+			[]byte("caf\xe9 r\xe9sum\xe9 &#8594;"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+			f := &Filter{
+				log: log,
+			}
+
+			header := http.Header{"Content-Type": []string{tt.contentType}}
+
+			got, body, _, _, err := f.readAndReplaceBody("/", bodyRules{replace: tt.rep}, io.NopCloser(bytes.NewReader(tt.body)), header)
+			if err != nil {
+				t.Fatalf("Filter.readAndReplaceBody() error = %v", err)
+			}
+
+			b, _ := io.ReadAll(body)
+			if !bytes.Equal(b, tt.want) {
+				t.Errorf("Filter.readAndReplaceBody() = %q, want %q", b, tt.want)
+			}
+			if got != len(tt.want) {
+				t.Errorf("Filter.readAndReplaceBody() length = %d, want %d", got, len(tt.want))
+			}
+
+			streamed, err := f.streamAndReplaceBody("/", tt.rep, io.NopCloser(bytes.NewReader(tt.body)), header)
+			if err != nil {
+				t.Fatalf("Filter.streamAndReplaceBody() error = %v", err)
+			}
+
+			b, _ = io.ReadAll(streamed)
+			if !bytes.Equal(b, tt.want) {
+				t.Errorf("Filter.streamAndReplaceBody() = %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
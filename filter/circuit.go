@@ -0,0 +1,354 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// States of a circuit breaker.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// Default values of the circuit breakers.
+const (
+	defaultCircuitWindow   = 20
+	defaultCircuitCooldown = 30 * time.Second
+	defaultCircuitBody     = "Service unavailable\n"
+)
+
+// Path of the health server giving the state of the circuit breakers.
+const circuitStatusPath = "/circuits"
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// Make time.Now mockable for unit test.
+var _now = time.Now //nolint: gochecknoglobals
+
+// circuitConfig is the configuration of the circuit breakers of the upstreams of a filter.
+type circuitConfig struct {
+	failures       int // Consecutive failures opening the circuit, 0 to disable
+	failurePercent int // Percentage of failures over the window opening the circuit, 0 to disable
+	window         int // Number of requests of the failure percentage
+	cooldown       time.Duration
+	status         int // Answer to the client while the circuit is open
+	body           string
+}
+
+// circuitBreaker stops sending requests to an upstream after too many failures.
+type circuitBreaker struct {
+	config   *circuitConfig
+	upstream string
+	log      logrus.FieldLogger
+
+	mu          sync.Mutex
+	state       string
+	consecutive int    // Consecutive failures
+	results     []bool // Last results, true for a failure
+	next        int    // Position of the next result in results
+	openedAt    time.Time
+	probing     bool // A request is trying the upstream in half-open state
+}
+
+// parseCircuitConfig verifies the circuit breaker configuration, it returns nil if there is no circuit breaker.
+func parseCircuitConfig(log logrus.FieldLogger, c CcircuitBreaker) *circuitConfig {
+	if c.Failures == 0 && c.FailurePercent == 0 {
+		if c != (CcircuitBreaker{}) {
+			log.Fatal("The circuit breaker needs failures or failurePercent")
+		}
+
+		return nil
+	}
+
+	cc := &circuitConfig{
+		failures:       c.Failures,
+		failurePercent: c.FailurePercent,
+		window:         defaultCircuitWindow,
+		cooldown:       defaultCircuitCooldown,
+		status:         http.StatusServiceUnavailable,
+		body:           defaultCircuitBody,
+	}
+
+	if c.Failures < 0 {
+		log.Fatalf("%d is not a valid number of failures", c.Failures)
+	}
+
+	if c.FailurePercent < 0 || c.FailurePercent > 100 {
+		log.Fatalf("%d is not a valid failure percentage", c.FailurePercent)
+	}
+
+	if c.Window < 0 {
+		log.Fatalf("%d is not a valid circuit breaker window", c.Window)
+	}
+
+	if c.Window > 0 {
+		cc.window = c.Window
+	}
+
+	if c.Cooldown != "" {
+		d, err := time.ParseDuration(c.Cooldown)
+		if err != nil || d <= 0 {
+			log.Fatalf("%s is not a valid circuit breaker cooldown", c.Cooldown)
+		}
+
+		cc.cooldown = d
+	}
+
+	if c.Status != 0 {
+		if c.Status < 100 || c.Status > 599 {
+			log.Fatalf("%d is not a valid circuit breaker status", c.Status)
+		}
+
+		cc.status = c.Status
+	}
+
+	if c.Body != "" {
+		cc.body = c.Body
+	}
+
+	return cc
+}
+
+func newCircuitBreaker(log logrus.FieldLogger, config *circuitConfig, upstream string) *circuitBreaker {
+	return &circuitBreaker{
+		config:   config,
+		upstream: upstream,
+		log:      log.WithField("upstream", upstream),
+		state:    circuitClosed,
+		results:  make([]bool, 0, config.window),
+	}
+}
+
+// newCircuitBreakers returns a circuit breaker for each upstream of the filter.
+func (f *Filter) newCircuitBreakers() map[string]*circuitBreaker {
+	breakers := map[string]*circuitBreaker{}
+
+	for _, upstream := range f.upstreamURLs() {
+		breakers[upstream] = newCircuitBreaker(f.log, f.circuit, upstream)
+	}
+
+	if f.upstreams != nil {
+		// The upstreams whose circuit is open are ejected from the load balancing
+		for _, up := range f.upstreams.upstreams {
+			up.breaker = breakers[up.url]
+		}
+	}
+
+	return breakers
+}
+
+// available returns true if a request could be sent to the upstream, without reserving the half-open attempt.
+func (b *circuitBreaker) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		return _now().Sub(b.openedAt) >= b.config.cooldown
+	case circuitHalfOpen:
+		return !b.probing
+	}
+
+	return true
+}
+
+// allow returns true if the request can be sent to the upstream, a single request tries the upstream after the
+// cooldown.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if _now().Sub(b.openedAt) < b.config.cooldown {
+			return false
+		}
+
+		b.state = circuitHalfOpen
+		b.log.Info("Circuit half-open, trying a request")
+
+		fallthrough
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+	}
+
+	return true
+}
+
+// record updates the breaker with the result of a request.
+func (b *circuitBreaker) record(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.probing = false
+
+		if failure {
+			b.open("the trial request failed")
+		} else {
+			b.reset()
+			b.log.Info("Circuit closed")
+		}
+
+		return
+	}
+
+	if b.state == circuitOpen {
+		// Request sent before the opening
+		return
+	}
+
+	if failure {
+		b.consecutive++
+	} else {
+		b.consecutive = 0
+	}
+
+	if len(b.results) < b.config.window {
+		b.results = append(b.results, failure)
+	} else {
+		b.results[b.next] = failure
+	}
+
+	b.next = (b.next + 1) % b.config.window
+
+	switch {
+	case b.config.failures > 0 && b.consecutive >= b.config.failures:
+		b.open(fmt.Sprintf("%d consecutive failures", b.consecutive))
+	case b.config.failurePercent > 0 && len(b.results) == b.config.window &&
+		b.failurePercent() >= b.config.failurePercent:
+		b.open(fmt.Sprintf("%d%% of failures over the last %d requests", b.failurePercent(), b.config.window))
+	}
+}
+
+// cancel forgets a request aborted by the client, another request will try the upstream in half-open state.
+func (b *circuitBreaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *circuitBreaker) open(reason string) {
+	b.state = circuitOpen
+	b.openedAt = _now()
+	b.log.Warnf("Circuit opened after %s, failing fast during %s", reason, b.config.cooldown)
+}
+
+func (b *circuitBreaker) reset() {
+	b.state = circuitClosed
+	b.consecutive = 0
+	b.results = b.results[:0]
+	b.next = 0
+}
+
+// failurePercent returns the percentage of failures of the last requests.
+func (b *circuitBreaker) failurePercent() int {
+	if len(b.results) == 0 {
+		return 0
+	}
+
+	failures := 0
+
+	for _, failure := range b.results {
+		if failure {
+			failures++
+		}
+	}
+
+	return failures * 100 / len(b.results)
+}
+
+// circuitTransport fails fast the requests to the upstreams whose circuit is open.
+type circuitTransport struct {
+	http.RoundTripper
+	filter *Filter
+}
+
+func (t *circuitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.filter.breakers[t.filter.upstreamURL(req)]
+	if b == nil {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	if !b.allow() {
+		return nil, errCircuitOpen
+	}
+
+	res, err := t.RoundTripper.RoundTrip(req)
+
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		// Canceled by the client, the upstream is not responsible
+		b.cancel()
+	case err != nil:
+		b.record(true)
+	default:
+		b.record(res.StatusCode >= http.StatusInternalServerError)
+	}
+
+	return res, err
+}
+
+// circuitOpenError answers to the client while the circuit of the upstream is open.
+func (f *Filter) circuitOpenError(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Header().Set("Content-Length", strconv.Itoa(len(f.circuit.body)))
+	res.WriteHeader(f.circuit.status)
+	_, _ = res.Write([]byte(f.circuit.body))
+}
+
+// circuitStatus is the state of the circuit breaker of an upstream displayed by the health server.
+type circuitStatus struct {
+	Upstream            string `json:"upstream"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	FailurePercent      int    `json:"failurePercent"`
+	OpenedAt            string `json:"openedAt,omitempty"`
+}
+
+// filterCircuitsStatus is the state of the circuit breakers of a filter displayed by the health server.
+type filterCircuitsStatus struct {
+	Port     string          `json:"port"`
+	Priority string          `json:"priority"`
+	Circuits []circuitStatus `json:"circuits"`
+}
+
+// circuitsStatus returns the state of the circuit breakers of the filter.
+func (f *Filter) circuitsStatus() interface{} {
+	status := filterCircuitsStatus{Port: f.port, Priority: f.priority}
+
+	for _, upstream := range f.upstreamURLs() {
+		b := f.breakers[upstream]
+
+		b.mu.Lock()
+		s := circuitStatus{
+			Upstream:            upstream,
+			State:               b.state,
+			ConsecutiveFailures: b.consecutive,
+			FailurePercent:      b.failurePercent(),
+		}
+
+		if b.state != circuitClosed {
+			s.OpenedAt = b.openedAt.Format(time.RFC3339)
+		}
+		b.mu.Unlock()
+
+		status.Circuits = append(status.Circuits, s)
+	}
+
+	return status
+}
@@ -0,0 +1,303 @@
+package filter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseCircuitConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    CcircuitBreaker
+		want      *circuitConfig
+		wantFatal bool
+	}{
+		{"no circuit breaker", CcircuitBreaker{}, nil, false},
+		{
+			"defaults",
+			CcircuitBreaker{Failures: 5},
+			&circuitConfig{failures: 5, window: 20, cooldown: 30 * time.Second, status: 503, body: "Service unavailable\n"},
+			false,
+		},
+		{
+			"configured",
+			CcircuitBreaker{FailurePercent: 50, Window: 10, Cooldown: "1m", Status: 502, Body: "Come back later"},
+			&circuitConfig{failurePercent: 50, window: 10, cooldown: time.Minute, status: 502, body: "Come back later"},
+			false,
+		},
+		{"cooldown without threshold", CcircuitBreaker{Cooldown: "1m"}, nil, true},
+		{"negative failures", CcircuitBreaker{Failures: -1, FailurePercent: 50}, nil, true},
+		{"wrong percentage", CcircuitBreaker{FailurePercent: 150}, nil, true},
+		{"negative window", CcircuitBreaker{Failures: 5, Window: -1}, nil, true},
+		{"wrong cooldown", CcircuitBreaker{Failures: 5, Cooldown: "0s"}, nil, true},
+		{"wrong status", CcircuitBreaker{Failures: 5, Status: 50}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseCircuitConfig(log, tt.config)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Fatalf("parseCircuitConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if !tt.wantFatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCircuitConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mockNow returns a clock advanced by the tests, restored at the end of the test.
+func mockNow(t *testing.T) *time.Time {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	_now = func() time.Time { return now }
+
+	t.Cleanup(func() { _now = time.Now })
+
+	return &now
+}
+
+func Test_circuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    circuitConfig
+		results   []bool // Results of the requests, true for a failure
+		wantState string
+		wantLog   []string
+	}{
+		{
+			"success",
+			circuitConfig{failures: 3, window: 4},
+			[]bool{true, true, false, true, true},
+			circuitClosed,
+			[]string{},
+		},
+		{
+			"consecutive failures",
+			circuitConfig{failures: 3, window: 4, cooldown: time.Minute},
+			[]bool{false, true, true, true},
+			circuitOpen,
+			[]string{"Circuit opened after 3 consecutive failures, failing fast during 1m0s"},
+		},
+		{
+			"failure percentage",
+			circuitConfig{failurePercent: 50, window: 4, cooldown: time.Minute},
+			[]bool{true, false, true, false},
+			circuitOpen,
+			[]string{"Circuit opened after 50% of failures over the last 4 requests, failing fast during 1m0s"},
+		},
+		{
+			"failure percentage over the last requests",
+			circuitConfig{failurePercent: 75, window: 4, cooldown: time.Minute},
+			[]bool{true, true, false, false, true, false, true},
+			circuitClosed,
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockNow(t)
+
+			log, hook := logrustest.NewNullLogger()
+			b := newCircuitBreaker(log, &tt.config, "http://app1")
+
+			for _, failure := range tt.results {
+				b.record(failure)
+			}
+
+			if b.state != tt.wantState {
+				t.Errorf("circuitBreaker.record() state = %s, want %s", b.state, tt.wantState)
+			}
+
+			verifyLogged("circuitBreaker.record", tt.wantLog, hook, t)
+		})
+	}
+}
+
+func Test_circuitBreaker_halfOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		failure   bool
+		wantState string
+		wantLog   string
+	}{
+		{"trial succeeded", false, circuitClosed, "Circuit closed"},
+		{"trial failed", true, circuitOpen, "Circuit opened after the trial request failed, failing fast during 1m0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := mockNow(t)
+
+			log, hook := logrustest.NewNullLogger()
+			b := newCircuitBreaker(log, &circuitConfig{failures: 1, window: 20, cooldown: time.Minute}, "http://app1")
+			b.record(true)
+
+			if b.allow() || b.available() {
+				t.Fatalf("circuitBreaker.allow() = true during the cooldown")
+			}
+
+			*now = now.Add(time.Minute)
+
+			if !b.available() || !b.allow() {
+				t.Fatalf("circuitBreaker.allow() = false after the cooldown")
+			}
+
+			// A single request tries the upstream
+			if b.allow() || b.available() {
+				t.Errorf("circuitBreaker.allow() = true during the trial request")
+			}
+
+			b.record(tt.failure)
+
+			if b.state != tt.wantState {
+				t.Errorf("circuitBreaker.record() state = %s, want %s", b.state, tt.wantState)
+			}
+
+			verifyLogged("circuitBreaker.record", []string{
+				"Circuit opened after 1 consecutive failures, failing fast during 1m0s",
+				"Circuit half-open, trying a request",
+				tt.wantLog,
+			}, hook, t)
+		})
+	}
+}
+
+func Test_circuitTransport_RoundTrip(t *testing.T) {
+	mockNow(t)
+
+	log, _ := logrustest.NewNullLogger()
+
+	var attempts atomic.Int64
+
+	f := &Filter{
+		url:     "http://app1",
+		log:     log,
+		circuit: &circuitConfig{failures: 2, window: 20, cooldown: time.Minute},
+		retry:   &retryPolicy{attempts: 5, backoff: time.Millisecond, maxBackoff: time.Millisecond, status: []int{503}},
+	}
+	f.breakers = f.newCircuitBreakers()
+
+	proxy := f.newReverseProxy(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader("down"))}, nil
+	}))
+
+	// The retries stop when the circuit opens
+	req := httptest.NewRequest(http.MethodGet, "http://app1/books", nil)
+
+	if _, err := proxy.Transport.RoundTrip(req); err != errCircuitOpen || attempts.Load() != 2 {
+		t.Errorf("circuitTransport.RoundTrip() = %v after %d attempts", err, attempts.Load())
+	}
+}
+
+func TestFilter_ServeCircuitOpen(t *testing.T) {
+	mockNow(t)
+
+	var calls atomic.Int64
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url:     backend.URL,
+		log:     log,
+		circuit: &circuitConfig{failures: 2, window: 20, cooldown: time.Minute, status: 502, body: "Come back later"},
+	}
+	f.breakers = f.newCircuitBreakers()
+
+	want := []int{500, 500, 502, 502}
+	for i, w := range want {
+		rec := httptest.NewRecorder()
+		f.Serve(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/books", nil))
+
+		if rec.Code != w {
+			t.Errorf("Filter.Serve() request %d status = %d, want %d", i+1, rec.Code, w)
+		}
+
+		if w == 502 && rec.Body.String() != "Come back later" {
+			t.Errorf("Filter.Serve() request %d body = %q", i+1, rec.Body.String())
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Filter.Serve() sent %d requests to the upstream, want 2", calls.Load())
+	}
+}
+
+func TestFilter_ServeCircuitEjection(t *testing.T) {
+	now := mockNow(t)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("up"))
+	}))
+	defer up.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url:       down.URL,
+		log:       log,
+		upstreams: parseUpstreamsConfig(log, []string{down.URL, up.URL}, Cbalancing{}, ChealthCheck{}, false),
+		circuit:   &circuitConfig{failures: 1, window: 20, cooldown: time.Minute, status: 503, body: "Come back later"},
+	}
+	f.breakers = f.newCircuitBreakers()
+
+	get := func() (int, string) {
+		rec := httptest.NewRecorder()
+		f.Serve(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/books", nil))
+
+		return rec.Code, rec.Body.String()
+	}
+
+	if status, _ := get(); status != http.StatusBadGateway {
+		t.Fatalf("Filter.Serve() status = %d, want 502", status)
+	}
+
+	// The upstream whose circuit is open is ejected from the load balancing
+	for i := 0; i < 3; i++ {
+		if status, got := get(); status != http.StatusOK || got != "up" {
+			t.Errorf("Filter.Serve() = %d %q", status, got)
+		}
+	}
+
+	f.breakers[up.URL].record(true)
+
+	if status, got := get(); status != http.StatusServiceUnavailable || got != "Come back later" {
+		t.Errorf("Filter.Serve() with all the circuits open = %d %q", status, got)
+	}
+
+	status := f.circuitsStatus().(filterCircuitsStatus)
+	if len(status.Circuits) != 2 || status.Circuits[0].State != circuitOpen || status.Circuits[0].OpenedAt != "2024-01-01T12:00:00Z" {
+		t.Errorf("Filter.circuitsStatus() = %#v", status)
+	}
+
+	// Back in the load balancing after the cooldown
+	*now = now.Add(time.Minute)
+
+	if status, got := get(); status != http.StatusBadGateway && got != "up" {
+		t.Errorf("Filter.Serve() after the cooldown = %d %q", status, got)
+	}
+}
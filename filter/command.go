@@ -0,0 +1,142 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Maximum duration of an external command if the configuration does not give one.
+const defaultCommandTimeout = 10 * time.Second
+
+// command is an external executable transforming a body, the body is written on its standard input and its standard
+// output is the new body.
+type command struct {
+	path    string
+	args    []string
+	timeout time.Duration
+	headers []string // Headers given to the command as VILLIP_HEADER_* environment variables
+	urls    []*regexp.Regexp
+	when    *conditions
+}
+
+// parseTransformConfig verifies the external commands of the transform stage.
+func parseTransformConfig(log logrus.FieldLogger, transforms []Ctransform, prefix []replaceParameters) []command {
+	result := make([]command, 0, len(transforms))
+
+	for _, t := range transforms {
+		if t.Command == "" {
+			log.Fatal("Missing command in transform")
+		}
+
+		path, err := exec.LookPath(t.Command)
+		if err != nil {
+			log.Fatalf("Cannot find transform command: %v", err)
+		}
+
+		timeout := defaultCommandTimeout
+
+		if t.Timeout != "" {
+			timeout, err = time.ParseDuration(t.Timeout)
+			if err != nil || timeout <= 0 {
+				log.Fatalf("%s is not a valid transform timeout", t.Timeout)
+			}
+		}
+
+		result = append(result, command{
+			path:    path,
+			args:    t.Args,
+			timeout: timeout,
+			headers: t.Headers,
+			urls:    parseURLsConfig(log, t.Urls, prefix),
+			when:    parseConditionsConfig(log, t.When),
+		})
+	}
+
+	return result
+}
+
+// headerVariable returns the name of the environment variable giving the header to the commands.
+func headerVariable(name string) string {
+	return "VILLIP_HEADER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// run executes the command on the body, the body is returned unchanged if the command fails.
+func (c command) run(log logrus.FieldLogger, requestURL string, body string, parsedHeader http.Header) string {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.path, c.args...) //nolint: gosec
+	cmd.Env = append(os.Environ(), "VILLIP_URL="+requestURL)
+	// Do not wait for the processes started by the command that keep the output open
+	cmd.WaitDelay = time.Second
+
+	for _, name := range c.headers {
+		if _, values := headerValues(parsedHeader, name); len(values) > 0 {
+			cmd.Env = append(cmd.Env, headerVariable(name)+"="+strings.Join(values, ", "))
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdin = strings.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timeout after %s", c.timeout)
+		}
+
+		log.WithFields(logrus.Fields{"command": c.path, "requestURL": requestURL}).
+			Errorf("Transform command failed, body not transformed: %v %s", err, strings.TrimSpace(stderr.String()))
+
+		return body
+	}
+
+	return stdout.String()
+}
+
+// transform pipes the body through the commands concerning the url.
+func (f *Filter) transform(requestURL string, commands []command, body string, parsedHeader http.Header) string {
+	for _, c := range commands {
+		if isURLConcerned(requestURL, c.urls) {
+			body = c.run(f.log, requestURL, body, parsedHeader)
+			f.log.Debug(fmt.Sprintf("Body after the %s command : %s", c.path, body))
+		}
+	}
+
+	return body
+}
+
+// hasCommand returns true if at least one command concerns the url.
+func hasCommand(requestURL string, commands []command) bool {
+	for _, c := range commands {
+		if isURLConcerned(requestURL, c.urls) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectCommands returns the commands whose conditions are fulfilled.
+func selectCommands(commands []command, ctx *ruleContext) []command {
+	selected := make([]command, 0, len(commands))
+
+	for _, c := range commands {
+		if c.when.match(ctx) {
+			selected = append(selected, c)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,191 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseTransformConfig(t *testing.T) {
+	upper, _ := filepath.Abs("testdata/transform/upper.sh")
+
+	tests := []struct {
+		name        string
+		transforms  []Ctransform
+		expectFatal bool
+		want        []command
+	}{
+		{
+			"command",
+			[]Ctransform{{
+				Command: upper,
+				Args:    []string{"-v"},
+				Timeout: "2s",
+				Headers: []string{"X-Tenant"},
+				Urls:    []string{"/api/"},
+				When:    &Cconditions{ContentTypes: []string{"application/pdf"}},
+			}},
+			false,
+			[]command{{
+				path:    upper,
+				args:    []string{"-v"},
+				timeout: 2 * time.Second,
+				headers: []string{"X-Tenant"},
+				urls:    []*regexp.Regexp{regexp.MustCompile("^/dev/api/")},
+				when:    &conditions{contentTypes: []string{"application/pdf"}},
+			}},
+		},
+		{
+			"default timeout",
+			[]Ctransform{{Command: upper}},
+			false,
+			[]command{{path: upper, timeout: defaultCommandTimeout, urls: []*regexp.Regexp{}}},
+		},
+		{"missing command", []Ctransform{{Timeout: "1s"}}, true, nil},
+		{"unknown command", []Ctransform{{Command: "testdata/transform/missing.sh"}}, true, nil},
+		{"wrong timeout", []Ctransform{{Command: upper, Timeout: "soon"}}, true, nil},
+		{"negative timeout", []Ctransform{{Command: upper, Timeout: "-1s"}}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			prefix := []replaceParameters{{from: "/", to: "/dev/", urls: []*regexp.Regexp{}}}
+			got := parseTransformConfig(log, tt.transforms, prefix)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseTransformConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTransformConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_transform(t *testing.T) {
+	tests := []struct {
+		name       string
+		requestURL string
+		commands   []command
+		want       string
+		wantLog    string
+	}{
+		{
+			"command output",
+			"/page",
+			[]command{{path: "testdata/transform/upper.sh", timeout: time.Second}},
+			"HELLO BOOK",
+			"",
+		},
+		{
+			"headers",
+			"/page",
+			[]command{{path: "testdata/transform/headers.sh", timeout: time.Second, headers: []string{"x-tenant", "X-Missing"}}},
+			"tenant=acme url=/page\nhello book",
+			"",
+		},
+		{
+			"pipeline",
+			"/page",
+			[]command{
+				{path: "testdata/transform/headers.sh", timeout: time.Second},
+				{path: "testdata/transform/upper.sh", timeout: time.Second},
+			},
+			"TENANT= URL=/PAGE\nHELLO BOOK",
+			"",
+		},
+		{
+			"url not concerned",
+			"/other",
+			[]command{{path: "testdata/transform/upper.sh", timeout: time.Second, urls: []*regexp.Regexp{regexp.MustCompile("^/page")}}},
+			"hello book",
+			"",
+		},
+		{
+			"failure",
+			"/page",
+			[]command{{path: "testdata/transform/fail.sh", timeout: time.Second}},
+			"hello book",
+			"Transform command failed, body not transformed: exit status 3 cannot transform",
+		},
+		{
+			"timeout",
+			"/page",
+			[]command{{path: "testdata/transform/slow.sh", timeout: 100 * time.Millisecond}},
+			"hello book",
+			"Transform command failed, body not transformed: timeout after 100ms ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			f := &Filter{log: log}
+
+			got := f.transform(tt.requestURL, tt.commands, "hello book", http.Header{"X-Tenant": {"acme"}})
+			if got != tt.want {
+				t.Errorf("Filter.transform() = %q, want %q", got, tt.want)
+			}
+
+			errors := []string{}
+
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.ErrorLevel {
+					errors = append(errors, entry.Message)
+				}
+			}
+
+			if strings.Join(errors, "|") != tt.wantLog {
+				t.Errorf("Filter.transform() logged %q, want %q", errors, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateResponseTransform(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/app.js", nil)
+	r := http.Response{
+		Header:     http.Header{"Content-Type": []string{"application/javascript"}},
+		StatusCode: http.StatusOK,
+		Request:    req,
+		Body:       io.NopCloser(bytes.NewBufferString("var book = 1")),
+	}
+
+	f := &Filter{
+		stream: true,
+		response: response{
+			Replace: []replaceParameters{{from: "book", to: "novel"}},
+			Transform: []command{
+				{path: "testdata/transform/upper.sh", timeout: time.Second, when: &conditions{contentTypes: []string{"javascript"}}},
+				{path: "testdata/transform/fail.sh", timeout: time.Second, when: &conditions{contentTypes: []string{"pdf"}}},
+			},
+		},
+		contentTypes: []string{"application/javascript"},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	got, _ := io.ReadAll(r.Body)
+	if string(got) != "VAR NOVEL = 1" || r.Header.Get("Content-Length") != "13" {
+		t.Errorf("Filter.UpdateResponse() = %s with length %s", got, r.Header.Get("Content-Length"))
+	}
+}
@@ -0,0 +1,217 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// valueCondition checks the values of a request header or of a query parameter.
+type valueCondition struct {
+	name  string
+	value *regexp.Regexp // nil if the header or the parameter must only be present
+}
+
+// conditions restricts a rule to some requests or responses, a nil conditions accepts all of them.
+type conditions struct {
+	methods      []string
+	status       []int
+	contentTypes []string
+	headers      []valueCondition
+	query        []valueCondition
+}
+
+// String describes the conditions for the logs.
+func (c *conditions) String() string {
+	parts := []string{}
+
+	if len(c.methods) > 0 {
+		parts = append(parts, fmt.Sprintf("method in %v", c.methods))
+	}
+
+	if len(c.status) > 0 {
+		parts = append(parts, fmt.Sprintf("status in %v", c.status))
+	}
+
+	if len(c.contentTypes) > 0 {
+		parts = append(parts, fmt.Sprintf("content-type in %v", c.contentTypes))
+	}
+
+	parts = append(parts, describeValues("header", c.headers)...)
+	parts = append(parts, describeValues("query", c.query)...)
+
+	return strings.Join(parts, " and ")
+}
+
+func describeValues(kind string, values []valueCondition) []string {
+	result := []string{}
+
+	for _, v := range values {
+		if v.value == nil {
+			result = append(result, fmt.Sprintf("%s %s present", kind, v.name))
+		} else {
+			result = append(result, fmt.Sprintf("%s %s matches %s", kind, v.name, v.value))
+		}
+	}
+
+	return result
+}
+
+// ruleContext is the part of the exchange checked by the conditions of the rules.
+type ruleContext struct {
+	method      string
+	status      int // 0 for the requests
+	contentType string
+	header      http.Header
+	query       url.Values
+}
+
+// newRuleContext returns the context of a request, or of its response if r is not nil.
+func newRuleContext(req *http.Request, r *http.Response) *ruleContext {
+	ctx := &ruleContext{header: http.Header{}, query: url.Values{}}
+
+	if req != nil {
+		ctx.method = req.Method
+		ctx.contentType = req.Header.Get("Content-Type")
+		ctx.header = req.Header
+
+		if req.URL != nil {
+			ctx.query = req.URL.Query()
+		}
+	}
+
+	if r != nil {
+		ctx.status = r.StatusCode
+		ctx.contentType = r.Header.Get("Content-Type")
+	}
+
+	return ctx
+}
+
+func matchValues(values []string, condition valueCondition) bool {
+	for _, v := range values {
+		if condition.value == nil || condition.value.MatchString(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match returns true if the context fulfills all the conditions.
+func (c *conditions) match(ctx *ruleContext) bool {
+	if c == nil {
+		return true
+	}
+
+	if len(c.methods) > 0 && !containsFold(c.methods, ctx.method) {
+		return false
+	}
+
+	if len(c.status) > 0 && !containsInt(c.status, ctx.status) {
+		return false
+	}
+
+	if len(c.contentTypes) > 0 {
+		found := false
+
+		for _, ct := range c.contentTypes {
+			if strings.Contains(ctx.contentType, ct) {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for _, h := range c.headers {
+		if !matchValues(ctx.header.Values(h.name), h) {
+			return false
+		}
+	}
+
+	for _, q := range c.query {
+		if !matchValues(ctx.query[q.name], q) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsInt(list []int, i int) bool {
+	for _, l := range list {
+		if l == i {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectReplace returns the replacement rules whose conditions are fulfilled.
+func selectReplace(rules []replaceParameters, ctx *ruleContext) []replaceParameters {
+	selected := make([]replaceParameters, 0, len(rules))
+
+	for _, r := range rules {
+		if r.when.match(ctx) {
+			selected = append(selected, r)
+		}
+	}
+
+	if len(selected) == len(rules) {
+		return rules
+	}
+
+	return selected
+}
+
+// selectJSON returns the JSON rules whose conditions are fulfilled.
+func selectJSON(rules []jsonParameters, ctx *ruleContext) []jsonParameters {
+	selected := make([]jsonParameters, 0, len(rules))
+
+	for _, jp := range rules {
+		if jp.when.match(ctx) {
+			selected = append(selected, jp)
+		}
+	}
+
+	if len(selected) == len(rules) {
+		return rules
+	}
+
+	return selected
+}
+
+// selectHeaders returns the header rules concerning the url whose conditions are fulfilled.
+func selectHeaders(requestURL string, rules []headerRule, ctx *ruleContext) []headerRule {
+	selected := make([]headerRule, 0, len(rules))
+
+	for _, h := range rules {
+		if isURLConcerned(requestURL, h.urls) && h.when.match(ctx) {
+			selected = append(selected, h)
+		}
+	}
+
+	if len(selected) == len(rules) {
+		return rules
+	}
+
+	return selected
+}
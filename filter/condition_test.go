@@ -0,0 +1,169 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_conditions_match(t *testing.T) {
+	ctx := &ruleContext{
+		method:      "POST",
+		status:      404,
+		contentType: "application/json; charset=utf-8",
+		header:      http.Header{"X-Tenant": []string{"acme", "other"}},
+		query:       map[string][]string{"debug": {"1"}},
+	}
+
+	tests := []struct {
+		name       string
+		conditions *conditions
+		want       bool
+	}{
+		{"nil", nil, true},
+		{"empty", &conditions{}, true},
+		{"method", &conditions{methods: []string{"get", "post"}}, true},
+		{"other method", &conditions{methods: []string{"GET"}}, false},
+		{"status", &conditions{status: []int{404, 410}}, true},
+		{"other status", &conditions{status: []int{200}}, false},
+		{"content type", &conditions{contentTypes: []string{"application/json"}}, true},
+		{"other content type", &conditions{contentTypes: []string{"text/html"}}, false},
+		{"header present", &conditions{headers: []valueCondition{{name: "x-tenant"}}}, true},
+		{"header value", &conditions{headers: []valueCondition{{name: "X-Tenant", value: regexp.MustCompile("^other$")}}}, true},
+		{"header other value", &conditions{headers: []valueCondition{{name: "X-Tenant", value: regexp.MustCompile("^foo$")}}}, false},
+		{"header missing", &conditions{headers: []valueCondition{{name: "X-Missing"}}}, false},
+		{"query", &conditions{query: []valueCondition{{name: "debug", value: regexp.MustCompile("^(1|true)$")}}}, true},
+		{"query missing", &conditions{query: []valueCondition{{name: "lang"}}}, false},
+		{
+			"all",
+			&conditions{
+				methods:      []string{"POST"},
+				status:       []int{404},
+				contentTypes: []string{"json"},
+				headers:      []valueCondition{{name: "X-Tenant"}},
+				query:        []valueCondition{{name: "debug"}},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conditions.match(ctx); got != tt.want {
+				t.Errorf("conditions.match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_conditions_String(t *testing.T) {
+	c := &conditions{
+		methods:      []string{"POST"},
+		status:       []int{404},
+		contentTypes: []string{"application/json"},
+		headers:      []valueCondition{{name: "X-Tenant"}},
+		query:        []valueCondition{{name: "debug", value: regexp.MustCompile("^1$")}},
+	}
+
+	want := "method in [POST] and status in [404] and content-type in [application/json] and header X-Tenant present and query debug matches ^1$"
+	if got := c.String(); got != want {
+		t.Errorf("conditions.String() = %v, want %v", got, want)
+	}
+}
+
+func Test_newRuleContext(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "http://localhost/api?id=5", nil)
+	req.Header.Set("Content-Type", "text/plain")
+
+	got := newRuleContext(req, nil)
+	if got.method != "PUT" || got.status != 0 || got.contentType != "text/plain" || got.query.Get("id") != "5" {
+		t.Errorf("newRuleContext() = %#v", got)
+	}
+
+	got = newRuleContext(req, &http.Response{StatusCode: 201, Header: http.Header{"Content-Type": []string{"application/json"}}})
+	if got.method != "PUT" || got.status != 201 || got.contentType != "application/json" {
+		t.Errorf("newRuleContext() = %#v", got)
+	}
+}
+
+func Test_selectRules(t *testing.T) {
+	ctx := &ruleContext{method: "GET", header: http.Header{}}
+	post := &conditions{methods: []string{"POST"}}
+
+	replace := []replaceParameters{{from: "a"}, {from: "b", when: post}, {from: "c", when: &conditions{methods: []string{"GET"}}}}
+	if got := selectReplace(replace, ctx); !reflect.DeepEqual(got, []replaceParameters{replace[0], replace[2]}) {
+		t.Errorf("selectReplace() = %#v", got)
+	}
+
+	json := []jsonParameters{{source: "$.a", when: post}, {source: "$.b"}}
+	if got := selectJSON(json, ctx); !reflect.DeepEqual(got, []jsonParameters{json[1]}) {
+		t.Errorf("selectJSON() = %#v", got)
+	}
+
+	headers := []headerRule{{name: "X-A"}, {name: "X-B", when: post}}
+	if got := selectHeaders("/", headers, ctx); !reflect.DeepEqual(got, []headerRule{headers[0]}) {
+		t.Errorf("selectHeaders() = %#v", got)
+	}
+
+	all := []replaceParameters{{from: "a"}}
+	if got := selectReplace(all, ctx); &got[0] != &all[0] {
+		t.Errorf("selectReplace() should return the rules as is when they are all selected")
+	}
+}
+
+func TestFilter_UpdateResponseConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+		want   string
+	}{
+		{"post not found", "POST", http.StatusNotFound, "page missing"},
+		{"get not found", "GET", http.StatusNotFound, "page not found"},
+		{"post ok", "POST", http.StatusOK, "page not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+
+			req, _ := http.NewRequest(tt.method, "http://localhost:8081/api/1", nil)
+			r := http.Response{
+				Header:     http.Header{"Content-Type": []string{"text/html"}},
+				StatusCode: tt.status,
+				Request:    req,
+				Body:       io.NopCloser(bytes.NewBufferString("page not found")),
+			}
+
+			f := &Filter{
+				response: response{
+					Replace: []replaceParameters{
+						{from: "not found", to: "missing", when: &conditions{methods: []string{"POST"}, status: []int{404}}},
+					},
+					Header: []headerRule{
+						{name: "X-Method", value: "post", when: &conditions{methods: []string{"POST"}}},
+					},
+				},
+				contentTypes: []string{"text/html"},
+				status:       []int{404},
+				log:          log,
+			}
+
+			if err := f.UpdateResponse(&r); err != nil {
+				t.Fatalf("Filter.UpdateResponse() error = %v", err)
+			}
+
+			got, _ := io.ReadAll(r.Body)
+			if string(got) != tt.want {
+				t.Errorf("Filter.UpdateResponse() = %s, want %s", got, tt.want)
+			}
+
+			if (r.Header.Get("X-Method") == "post") != (tt.method == "POST") {
+				t.Errorf("Filter.UpdateResponse() X-Method = %s", r.Header.Get("X-Method"))
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,24 +18,301 @@ func parseReplaceConfig(log logrus.FieldLogger, rep []Creplacement, prefix []rep
 	result := make([]replaceParameters, 0)
 
 	for _, r := range rep {
-		p := replaceParameters{from: r.From, to: r.To, urls: []*regexp.Regexp{}}
+		p := replaceParameters{
+			from: r.From,
+			to:   r.To,
+			urls: parseURLsConfig(log, r.Urls, prefix),
+			when: parseConditionsConfig(log, r.When),
+		}
+
+		if r.Regex {
+			p.regex = parseRegexConfig(log, r.From)
+		}
+
+		result = append(result, p)
+	}
+
+	return result
+}
+
+func parseRegexConfig(log logrus.FieldLogger, reg string) *regexp.Regexp {
+	re, err := regexp.Compile(reg)
+	if err != nil {
+		log.Fatalf("Failed to compile '%s' regular expression: %v", reg, err)
+	}
+
+	return re
+}
+
+// parseDictionaryConfig loads the dictionaries and builds their matchers.
+func parseDictionaryConfig(log logrus.FieldLogger, dictionaries []Cdictionary, prefix []replaceParameters) []dictionary {
+	result := make([]dictionary, 0, len(dictionaries))
+
+	for _, d := range dictionaries {
+		pairs := loadDictionary(log, d.File)
+
+		result = append(result, dictionary{
+			file:     d.File,
+			size:     len(pairs),
+			replacer: newMultiReplacer(pairs),
+			urls:     parseURLsConfig(log, d.Urls, prefix),
+			when:     parseConditionsConfig(log, d.When),
+		})
+	}
+
+	return result
+}
+
+// parseConditionsConfig compiles the conditions of a rule, it returns nil if the rule has none.
+func parseConditionsConfig(log logrus.FieldLogger, c *Cconditions) *conditions {
+	if c == nil {
+		return nil
+	}
 
-		for _, reg := range r.Urls {
-			reg = _do(reg, reg, prefix, true)
+	parseMatches := func(kind string, matches []Cmatch) []valueCondition {
+		var result []valueCondition
 
-			if !strings.HasPrefix(reg, "^") {
-				reg = "^" + reg
+		for _, m := range matches {
+			if m.Name == "" {
+				log.Fatalf("Missing name in %s condition", kind)
 			}
 
-			r, err := regexp.Compile(reg)
-			if err != nil {
-				log.Fatalf("Failed to compile '%s' regular expression: %v", reg, err)
+			vc := valueCondition{name: m.Name}
+			if m.Value != "" {
+				vc.value = parseRegexConfig(log, m.Value)
 			}
 
-			p.urls = append(p.urls, r)
+			result = append(result, vc)
 		}
 
-		result = append(result, p)
+		return result
+	}
+
+	return &conditions{
+		methods:      c.Methods,
+		status:       c.Status,
+		contentTypes: c.ContentTypes,
+		headers:      parseMatches("header", c.Headers),
+		query:        parseMatches("query", c.Query),
+	}
+}
+
+// checkRequestConditions verifies that the rules of the requests do not use the conditions reserved to the responses.
+func checkRequestConditions(log logrus.FieldLogger, c Caction) {
+	all := []*Cconditions{}
+
+	for _, r := range c.Replace {
+		all = append(all, r.When)
+	}
+
+	for _, h := range c.Header {
+		all = append(all, h.When)
+	}
+
+	for _, j := range c.JSON {
+		all = append(all, j.When)
+	}
+
+	for _, d := range c.Dictionary {
+		all = append(all, d.When)
+	}
+
+	for _, t := range c.Transform {
+		all = append(all, t.When)
+	}
+
+	for _, w := range all {
+		if w != nil && len(w.Status) > 0 {
+			log.Fatal("Status condition is only available for responses")
+		}
+	}
+}
+
+// parsePublicURLConfig verifies the upstream URL and the public URL used by the automatic rebasing.
+func parsePublicURLConfig(log logrus.FieldLogger, upstream string, public string) string {
+	if u, err := url.Parse(upstream); err != nil || u.Host == "" {
+		log.Fatalf("The url %s must be absolute for autoRebase", upstream)
+	}
+
+	if public == "" {
+		return ""
+	}
+
+	u, err := url.Parse(public)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		log.Fatalf("The publicURL %s must be an absolute URL", public)
+	}
+
+	return strings.TrimSuffix(public, "/")
+}
+
+// parsePrefixConfig parses the prefix rules and verifies that the reversed ones can be inverted.
+func parsePrefixConfig(log logrus.FieldLogger, rep []Creplacement) []replaceParameters {
+	result := parseReplaceConfig(log, rep, []replaceParameters{})
+
+	for i, r := range rep {
+		if r.ReverseLinks && !r.Reverse {
+			log.Fatalf("Prefix %s must be reversed to reverse the links", r.From)
+		}
+
+		if r.Reverse && r.Regex {
+			log.Fatalf("Prefix %s is a regular expression and cannot be reversed", r.From)
+		}
+
+		if r.When != nil {
+			log.Fatalf("Prefix %s cannot have conditions", r.From)
+		}
+
+		result[i].reverse = r.Reverse
+		result[i].reverseLinks = r.ReverseLinks
+	}
+
+	return result
+}
+
+// parseHeaderConfig verifies the header rules and compiles their regular expressions.
+func parseHeaderConfig(log logrus.FieldLogger, headers []Cheader, prefix []replaceParameters) []headerRule {
+	result := make([]headerRule, 0, len(headers))
+
+	for _, h := range headers {
+		if h.Name == "" {
+			log.Fatal("Missing name in header rule")
+		}
+
+		if h.Delete && h.Rename != "" {
+			log.Fatalf("Header %s cannot be deleted and renamed by the same rule", h.Name)
+		}
+
+		rule := headerRule{
+			name:   h.Name,
+			value:  h.Value,
+			force:  h.Force,
+			add:    h.Add,
+			uuid:   h.UUID,
+			from:   h.From,
+			to:     h.To,
+			delete: h.Delete,
+			rename: h.Rename,
+			when:   parseConditionsConfig(log, h.When),
+		}
+
+		if h.Regex {
+			if h.From == "" {
+				log.Fatalf("Missing from in regex rule for header %s", h.Name)
+			}
+
+			rule.regex = parseRegexConfig(log, h.From)
+		}
+
+		if len(h.Urls) > 0 {
+			rule.urls = parseURLsConfig(log, h.Urls, prefix)
+		}
+
+		result = append(result, rule)
+	}
+
+	return result
+}
+
+// parseCookiesConfig verifies the cookies rules and compiles their regular expressions.
+func parseCookiesConfig(log logrus.FieldLogger, c *Ccookies, prefix []replaceParameters) *cookieRules {
+	secure := strings.ToLower(c.Secure)
+	if secure != "" && secure != cookieAdd && secure != cookieStrip {
+		log.Fatalf("Unknown secure action '%s' for cookies", c.Secure)
+	}
+
+	sameSite := strings.ToLower(c.SameSite)
+	if _, ok := cookieSameSite[sameSite]; sameSite != "" && !ok {
+		log.Fatalf("Unknown sameSite value '%s' for cookies", c.SameSite)
+	}
+
+	for _, n := range c.Name {
+		if n.Regex {
+			log.Fatalf("Cookie name rule %s cannot be a regular expression", n.From)
+		}
+	}
+
+	return &cookieRules{
+		name:          parseReplaceConfig(log, c.Name, prefix),
+		domain:        parseReplaceConfig(log, c.Domain, prefix),
+		path:          parseReplaceConfig(log, c.Path, prefix),
+		reversePrefix: c.ReversePrefix,
+		secure:        secure,
+		sameSite:      sameSite,
+	}
+}
+
+// parseURLsConfig compiles the URL regular expressions after translating them with the prefix rules.
+func parseURLsConfig(log logrus.FieldLogger, urls []string, prefix []replaceParameters) []*regexp.Regexp {
+	result := []*regexp.Regexp{}
+
+	for _, reg := range urls {
+		reg = _do(reg, reg, prefix, true)
+
+		if !strings.HasPrefix(reg, "^") {
+			reg = "^" + reg
+		}
+
+		r, err := regexp.Compile(reg)
+		if err != nil {
+			log.Fatalf("Failed to compile '%s' regular expression: %v", reg, err)
+		}
+
+		result = append(result, r)
+	}
+
+	return result
+}
+
+func parseJSONConfig(log logrus.FieldLogger, rules []Cjson, prefix []replaceParameters) []jsonParameters {
+	result := make([]jsonParameters, 0)
+
+	for _, j := range rules {
+		path, err := parseJSONPath(j.Path)
+		if err != nil {
+			log.Fatalf("Invalid JSON rule: %v", err)
+		}
+
+		jp := jsonParameters{
+			path:   path,
+			source: j.Path,
+			urls:   parseURLsConfig(log, j.Urls, prefix),
+			when:   parseConditionsConfig(log, j.When),
+		}
+
+		switch strings.ToLower(j.Action) {
+		case "set":
+			jp.action = jsonSet
+		case "replace":
+			jp.action = jsonReplace
+		case "delete":
+			jp.action = jsonDelete
+		case "strings":
+			jp.action = jsonStrings
+		default:
+			log.Fatalf("'%s' is not a valid action for JSON rule", j.Action)
+		}
+
+		switch jp.action {
+		case jsonSet, jsonReplace:
+			if _, err := decodeJSON(j.Value); err != nil {
+				log.Fatalf("Value '%s' of JSON rule for %s is not a valid JSON document: %v", j.Value, j.Path, err)
+			}
+
+			jp.value = j.Value
+		case jsonStrings:
+			if j.From == "" {
+				log.Fatalf("JSON rule for %s must have a from value", j.Path)
+			}
+
+			jp.replace = replaceParameters{from: j.From, to: j.To, urls: []*regexp.Regexp{}}
+			if j.Regex {
+				jp.replace.regex = parseRegexConfig(log, j.From)
+			}
+		case jsonDelete:
+		}
+
+		result = append(result, jp)
 	}
 
 	return result
@@ -70,10 +349,18 @@ func genNewFromConfig() fNewConfig {
 	return func(log logrus.FieldLogger, c Config) (string, uint8, FilteredServer) {
 		f := Filter{}
 
+		if len(c.URLs) > 0 {
+			if c.URL != "" {
+				log.Fatal("url and urls cannot be used at the same time")
+			}
+
+			c.URL = c.URLs[0]
+		}
+
 		if c.URL == "" {
 			url, ok := os.LookupEnv("VILLIP_URL")
 			if !ok {
-				f.log.Fatal("Missing url variable and no VILLIP_URL environment variable defined")
+				log.Fatal("Missing url variable and no VILLIP_URL environment variable defined")
 			}
 
 			c.URL = url
@@ -107,6 +394,23 @@ func genNewFromConfig() fNewConfig {
 
 		f.log = log.WithFields(logrus.Fields{"port": f.port, "url": f.url, "priority": f.priority})
 
+		switch {
+		case len(c.URLs) > 1 || c.HealthCheck.Path != "":
+			if f.kind != HTTP {
+				f.log.Fatal("Several upstreams and health checks are only available for the HTTP filters")
+			}
+
+			urls := c.URLs
+			if len(urls) == 0 {
+				urls = []string{c.URL}
+			}
+
+			// The health checks are started by Start once all the filters are parsed
+			f.upstreams = parseUpstreamsConfig(f.log, urls, c.Balancing, c.HealthCheck, c.Insecure)
+		case c.Balancing != Cbalancing{}:
+			f.log.Fatal("balancing is only used with several urls")
+		}
+
 		if c.Dump.Folder != "" {
 			f.dumpFolder = c.Dump.Folder
 			if _, err := os.Stat(f.dumpFolder); !os.IsNotExist(err) {
@@ -130,11 +434,40 @@ func genNewFromConfig() fNewConfig {
 
 		f.force = c.Force
 		f.insecure = c.Insecure
+		f.stream = c.Stream
+
+		switch c.Websocket {
+		case "", websocketPassthrough:
+		case websocketFilter:
+			f.websocket = websocketFilter
+		default:
+			f.log.Fatalf("Unknown websocket mode '%s' (passthrough or filter)", c.Websocket)
+		}
+
+		if c.FlushInterval != "" {
+			d, err := time.ParseDuration(c.FlushInterval)
+			if err != nil {
+				f.log.Fatalf("%s is not a valid flush interval: %v", c.FlushInterval, err)
+			}
+
+			f.flushInterval = d
+		}
+
+		if c.AutoRebase {
+			f.autoRebase = true
+			f.publicURL = parsePublicURLConfig(f.log, f.url, c.PublicURL)
+		} else if c.PublicURL != "" {
+			f.log.Fatal("publicURL is only used by autoRebase")
+		}
+
+		if len(c.Rewrite) > 0 {
+			f.rewrite = parseRewriteConfig(f.log, c.Rewrite)
+		}
 
 		f.prefix = make([]replaceParameters, 0) // Must be before request and response
 
 		if len(c.Prefix) > 0 {
-			f.prefix = parseReplaceConfig(f.log, c.Prefix, []replaceParameters{})
+			f.prefix = parsePrefixConfig(f.log, c.Prefix)
 		}
 
 		responseReplace := make([]Creplacement, 0)
@@ -158,16 +491,76 @@ func genNewFromConfig() fNewConfig {
 			f.request.Replace = parseReplaceConfig(f.log, c.Request.Replace, f.prefix)
 		}
 
-		f.request.Header = make([]Cheader, 0)
+		if len(c.Response.JSON) > 0 {
+			f.response.JSON = parseJSONConfig(f.log, c.Response.JSON, f.prefix)
+		}
+
+		if len(c.Request.JSON) > 0 {
+			f.request.JSON = parseJSONConfig(f.log, c.Request.JSON, f.prefix)
+		}
+
+		if len(c.Request.HTML) > 0 {
+			f.log.Fatal("HTML rewriting is only available for responses")
+		}
+
+		if len(c.Response.HTML) > 0 {
+			f.response.HTML = parseReplaceConfig(f.log, c.Response.HTML, f.prefix)
+		}
+
+		checkRequestConditions(f.log, c.Request)
+
+		if len(c.Response.Dictionary) > 0 {
+			f.response.Dictionary = parseDictionaryConfig(f.log, c.Response.Dictionary, f.prefix)
+		}
+
+		if len(c.Request.Dictionary) > 0 {
+			f.request.Dictionary = parseDictionaryConfig(f.log, c.Request.Dictionary, f.prefix)
+		}
+
+		if len(c.Response.Transform) > 0 {
+			f.response.Transform = parseTransformConfig(f.log, c.Response.Transform, f.prefix)
+		}
+
+		if len(c.Request.Transform) > 0 {
+			f.request.Transform = parseTransformConfig(f.log, c.Request.Transform, f.prefix)
+		}
+
+		if len(c.Response.Transformers) > 0 {
+			f.response.Transformers = parseTransformersConfig(f.log, c.Response.Transformers)
+		}
+
+		if len(c.Request.Transformers) > 0 {
+			f.request.Transformers = parseTransformersConfig(f.log, c.Request.Transformers)
+		}
+
+		if len(c.Request.Inject) > 0 {
+			f.log.Fatal("Content injection is only available for responses")
+		}
+
+		if len(c.Response.Inject) > 0 {
+			f.response.Inject = parseInjectConfig(f.log, c.Response.Inject, f.prefix)
+		}
+
+		if c.Request.Cookies != nil {
+			f.log.Fatal("Cookies rewriting is only available for responses")
+		}
+
+		if c.Response.Cookies != nil {
+			f.response.Cookies = parseCookiesConfig(f.log, c.Response.Cookies, f.prefix)
+		}
+
+		f.request.Header = make([]headerRule, 0)
 		if len(c.Request.Header) > 0 {
-			f.request.Header = c.Request.Header
+			f.request.Header = parseHeaderConfig(f.log, c.Request.Header, f.prefix)
 		}
 
-		f.response.Header = make([]Cheader, 0)
+		f.response.Header = make([]headerRule, 0)
 		if len(c.Response.Header) > 0 {
-			f.response.Header = c.Response.Header
+			f.response.Header = parseHeaderConfig(f.log, c.Response.Header, f.prefix)
 		}
 
+		f.templates = parseTemplates(f.log, c)
+
 		f.restricted = []*net.IPNet{}
 
 		f.token = make(map[string][]headerConditions)
@@ -181,6 +574,10 @@ func genNewFromConfig() fNewConfig {
 			f.token[header] = append(f.token[header], token)
 		}
 
+		if f.route = parseRouteConfig(f.log, c.Match); f.route != nil && f.kind != HTTP {
+			f.log.Fatal("match is only used by the HTTP filters")
+		}
+
 		for _, ip := range c.Restricted {
 			_, ipnet, err := net.ParseCIDR(ip)
 			if err != nil {
@@ -198,6 +595,34 @@ func genNewFromConfig() fNewConfig {
 
 		if len(f.contentTypes) == 0 {
 			f.contentTypes = append(f.contentTypes, []string{"text/html", "text/css", "application/javascript"}...)
+
+			if c.Stream {
+				// The events are filtered as soon as they are received
+				f.contentTypes = append(f.contentTypes, eventStreamType, "application/x-ndjson")
+			}
+		}
+
+		if f.kind == HTTP {
+			if c.Transport.Timeout != "" {
+				d, err := time.ParseDuration(c.Transport.Timeout)
+				if err != nil || d < 0 {
+					f.log.Fatalf("%s is not a valid timeout", c.Transport.Timeout)
+				}
+
+				f.timeout = d
+			}
+
+			f.retry = parseRetryConfig(f.log, c.Retry)
+
+			if f.circuit = parseCircuitConfig(f.log, c.CircuitBreaker); f.circuit != nil {
+				f.breakers = f.newCircuitBreakers()
+			}
+
+			// Must be after the rules, they decide which steps of the proxy are needed
+			f.transport = parseTransportConfig(f.log, c.Transport, f.insecure)
+			f.proxy = f.newReverseProxy(f.transport)
+		} else if c.Transport != (Ctransport{}) || c.Retry.Attempts != 0 || c.CircuitBreaker != (CcircuitBreaker{}) {
+			f.log.Fatal("transport, retry and circuitBreaker are only used by the HTTP filters")
 		}
 
 		f.startLog()
@@ -1,5 +1,17 @@
 package filter
 
+func (in *Croute) DeepCopyInto(out *Croute) {
+	*out = *in
+	if in.Paths != nil {
+		out.Paths = make([]string, len(in.Paths))
+		copy(out.Paths, in.Paths)
+	}
+	if in.Methods != nil {
+		out.Methods = make([]string, len(in.Methods))
+		copy(out.Methods, in.Methods)
+	}
+}
+
 func (in *Cdump) DeepCopyInto(out *Cdump) {
 	*out = *in
 	if in.URLs != nil {
@@ -10,6 +22,38 @@ func (in *Cdump) DeepCopyInto(out *Cdump) {
 	}
 }
 
+func (in *Cretry) DeepCopyInto(out *Cretry) {
+	*out = *in
+	if in.Status != nil {
+		out.Status = make([]int, len(in.Status))
+		copy(out.Status, in.Status)
+	}
+}
+
+func (in *Cconditions) DeepCopyInto(out *Cconditions) {
+	*out = *in
+	if in.Methods != nil {
+		out.Methods = make([]string, len(in.Methods))
+		copy(out.Methods, in.Methods)
+	}
+	if in.Status != nil {
+		out.Status = make([]int, len(in.Status))
+		copy(out.Status, in.Status)
+	}
+	if in.ContentTypes != nil {
+		out.ContentTypes = make([]string, len(in.ContentTypes))
+		copy(out.ContentTypes, in.ContentTypes)
+	}
+	if in.Headers != nil {
+		out.Headers = make([]Cmatch, len(in.Headers))
+		copy(out.Headers, in.Headers)
+	}
+	if in.Query != nil {
+		out.Query = make([]Cmatch, len(in.Query))
+		copy(out.Query, in.Query)
+	}
+}
+
 func (in *Creplacement) DeepCopyInto(out *Creplacement) {
 	*out = *in
 	if in.Urls != nil {
@@ -18,9 +62,142 @@ func (in *Creplacement) DeepCopyInto(out *Creplacement) {
 			out.Urls = append(out.Urls, i)
 		}
 	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
 }
 func (in *Cheader) DeepCopyInto(out *Cheader) {
 	*out = *in
+	if in.Urls != nil {
+		out.Urls = make([]string, 0, len(in.Urls))
+		for _, i := range in.Urls {
+			out.Urls = append(out.Urls, i)
+		}
+	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
+}
+
+func (in *Cjson) DeepCopyInto(out *Cjson) {
+	*out = *in
+	if in.Urls != nil {
+		out.Urls = make([]string, 0, len(in.Urls))
+		for _, i := range in.Urls {
+			out.Urls = append(out.Urls, i)
+		}
+	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
+}
+
+func (in *Ccookies) DeepCopyInto(out *Ccookies) {
+	*out = *in
+	if in.Name != nil {
+		in, out := &in.Name, &out.Name
+		*out = make([]Creplacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Domain != nil {
+		in, out := &in.Domain, &out.Domain
+		*out = make([]Creplacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = make([]Creplacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *Cdictionary) DeepCopyInto(out *Cdictionary) {
+	*out = *in
+	if in.Urls != nil {
+		out.Urls = make([]string, 0, len(in.Urls))
+		for _, i := range in.Urls {
+			out.Urls = append(out.Urls, i)
+		}
+	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
+}
+
+func (in *Cinject) DeepCopyInto(out *Cinject) {
+	*out = *in
+	if in.Urls != nil {
+		out.Urls = make([]string, 0, len(in.Urls))
+		for _, i := range in.Urls {
+			out.Urls = append(out.Urls, i)
+		}
+	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
+}
+
+func (in *Ctransform) DeepCopyInto(out *Ctransform) {
+	*out = *in
+	if in.Args != nil {
+		out.Args = make([]string, 0, len(in.Args))
+		for _, i := range in.Args {
+			out.Args = append(out.Args, i)
+		}
+	}
+	if in.Headers != nil {
+		out.Headers = make([]string, 0, len(in.Headers))
+		for _, i := range in.Headers {
+			out.Headers = append(out.Headers, i)
+		}
+	}
+	if in.Urls != nil {
+		out.Urls = make([]string, 0, len(in.Urls))
+		for _, i := range in.Urls {
+			out.Urls = append(out.Urls, i)
+		}
+	}
+	if in.When != nil {
+		out.When = new(Cconditions)
+		in.When.DeepCopyInto(out.When)
+	}
+}
+
+func (in *Crewrite) DeepCopyInto(out *Crewrite) {
+	*out = *in
+	if in.Query != nil {
+		out.Query = make([]Cmatch, len(in.Query))
+		copy(out.Query, in.Query)
+	}
+	if in.AddQuery != nil {
+		out.AddQuery = make(map[string]string, len(in.AddQuery))
+		for k, v := range in.AddQuery {
+			out.AddQuery[k] = v
+		}
+	}
+	if in.RemoveQuery != nil {
+		out.RemoveQuery = make([]string, 0, len(in.RemoveQuery))
+		for _, i := range in.RemoveQuery {
+			out.RemoveQuery = append(out.RemoveQuery, i)
+		}
+	}
+	if in.RenameQuery != nil {
+		out.RenameQuery = make(map[string]string, len(in.RenameQuery))
+		for k, v := range in.RenameQuery {
+			out.RenameQuery[k] = v
+		}
+	}
 }
 
 func (in *Caction) DeepCopyInto(out *Caction) {
@@ -39,4 +216,50 @@ func (in *Caction) DeepCopyInto(out *Caction) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.HTML != nil {
+		in, out := &in.HTML, &out.HTML
+		*out = make([]Creplacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.JSON != nil {
+		in, out := &in.JSON, &out.JSON
+		*out = make([]Cjson, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Dictionary != nil {
+		in, out := &in.Dictionary, &out.Dictionary
+		*out = make([]Cdictionary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Inject != nil {
+		in, out := &in.Inject, &out.Inject
+		*out = make([]Cinject, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Transformers != nil {
+		out.Transformers = make([]string, 0, len(in.Transformers))
+		for _, i := range in.Transformers {
+			out.Transformers = append(out.Transformers, i)
+		}
+	}
+	if in.Transform != nil {
+		in, out := &in.Transform, &out.Transform
+		*out = make([]Ctransform, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Cookies != nil {
+		in, out := &in.Cookies, &out.Cookies
+		*out = new(Ccookies)
+		(*in).DeepCopyInto(*out)
+	}
 }
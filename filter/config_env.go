@@ -22,7 +22,36 @@ func (f *Factory) NewFromEnv() (string, uint8, FilteredServer) {
 		f.log.Fatal("Missing VILLIP_URL environment variable")
 	}
 
-	c.URL = url
+	// Several upstreams can be given separated by commas
+	if strings.Contains(url, ",") {
+		c.URLs = strings.Split(strings.ReplaceAll(url, " ", ""), ",")
+	} else {
+		c.URL = url
+	}
+
+	if strategy, ok := f.lookupEnv("VILLIP_BALANCING"); ok {
+		c.Balancing.Strategy = strategy
+	}
+
+	if cookie, ok := f.lookupEnv("VILLIP_BALANCING_COOKIE"); ok {
+		c.Balancing.Cookie = cookie
+	}
+
+	if header, ok := f.lookupEnv("VILLIP_BALANCING_HEADER"); ok {
+		c.Balancing.Header = header
+	}
+
+	if path, ok := f.lookupEnv("VILLIP_HEALTHCHECK_PATH"); ok {
+		c.HealthCheck.Path = path
+	}
+
+	if interval, ok := f.lookupEnv("VILLIP_HEALTHCHECK_INTERVAL"); ok {
+		c.HealthCheck.Interval = interval
+	}
+
+	if timeout, ok := f.lookupEnv("VILLIP_HEALTHCHECK_TIMEOUT"); ok {
+		c.HealthCheck.Timeout = timeout
+	}
 
 	if villipPriority, ok := f.lookupEnv("VILLIP_PRIORITY"); ok {
 		priority, err := strconv.Atoi(villipPriority)
@@ -68,6 +97,126 @@ func (f *Factory) NewFromEnv() (string, uint8, FilteredServer) {
 		c.Insecure = true
 	}
 
+	if _, ok := f.lookupEnv("VILLIP_STREAM"); ok {
+		c.Stream = true
+	}
+
+	if _, ok := f.lookupEnv("VILLIP_AUTOREBASE"); ok {
+		c.AutoRebase = true
+	}
+
+	if publicURL, ok := f.lookupEnv("VILLIP_PUBLICURL"); ok {
+		c.PublicURL = publicURL
+	}
+
+	if websocket, ok := f.lookupEnv("VILLIP_WEBSOCKET"); ok {
+		c.Websocket = websocket
+	}
+
+	if paths, ok := f.lookupEnv("VILLIP_MATCH_PATHS"); ok {
+		c.Match.Paths = strings.Split(strings.ReplaceAll(paths, " ", ""), ",")
+	}
+
+	if _, ok := f.lookupEnv("VILLIP_MATCH_REGEX"); ok {
+		c.Match.Regex = true
+	}
+
+	if methods, ok := f.lookupEnv("VILLIP_MATCH_METHODS"); ok {
+		c.Match.Methods = strings.Split(strings.ReplaceAll(methods, " ", ""), ",")
+	}
+
+	if maxIdle, ok := f.lookupEnv("VILLIP_MAXIDLECONNSPERHOST"); ok {
+		n, err := strconv.Atoi(maxIdle)
+		if err != nil {
+			f.log.Fatalf("%s is not a valid number of idle connections", maxIdle)
+		}
+
+		c.Transport.MaxIdleConnsPerHost = n
+	}
+
+	if idleTimeout, ok := f.lookupEnv("VILLIP_IDLETIMEOUT"); ok {
+		c.Transport.IdleTimeout = idleTimeout
+	}
+
+	if dialTimeout, ok := f.lookupEnv("VILLIP_DIALTIMEOUT"); ok {
+		c.Transport.DialTimeout = dialTimeout
+	}
+
+	if responseHeaderTimeout, ok := f.lookupEnv("VILLIP_RESPONSEHEADERTIMEOUT"); ok {
+		c.Transport.ResponseHeaderTimeout = responseHeaderTimeout
+	}
+
+	if _, ok := f.lookupEnv("VILLIP_HTTP2"); ok {
+		c.Transport.HTTP2 = true
+	}
+
+	if timeout, ok := f.lookupEnv("VILLIP_TIMEOUT"); ok {
+		c.Transport.Timeout = timeout
+	}
+
+	if attempts, ok := f.lookupEnv("VILLIP_RETRY_ATTEMPTS"); ok {
+		n, err := strconv.Atoi(attempts)
+		if err != nil {
+			f.log.Fatalf("%s is not a valid number of attempts", attempts)
+		}
+
+		c.Retry.Attempts = n
+	}
+
+	if backoff, ok := f.lookupEnv("VILLIP_RETRY_BACKOFF"); ok {
+		c.Retry.Backoff = backoff
+	}
+
+	if statusList, ok := f.lookupEnv("VILLIP_RETRY_STATUS"); ok {
+		for _, s := range strings.Split(strings.ReplaceAll(statusList, " ", ""), ",") {
+			status, err := strconv.Atoi(s)
+			if err != nil {
+				f.log.Fatalf("%s is not a valid retry status", s)
+			}
+
+			c.Retry.Status = append(c.Retry.Status, status)
+		}
+	}
+
+	if failures, ok := f.lookupEnv("VILLIP_CIRCUIT_FAILURES"); ok {
+		n, err := strconv.Atoi(failures)
+		if err != nil {
+			f.log.Fatalf("%s is not a valid number of failures", failures)
+		}
+
+		c.CircuitBreaker.Failures = n
+	}
+
+	if percent, ok := f.lookupEnv("VILLIP_CIRCUIT_FAILUREPERCENT"); ok {
+		n, err := strconv.Atoi(percent)
+		if err != nil {
+			f.log.Fatalf("%s is not a valid failure percentage", percent)
+		}
+
+		c.CircuitBreaker.FailurePercent = n
+	}
+
+	if cooldown, ok := f.lookupEnv("VILLIP_CIRCUIT_COOLDOWN"); ok {
+		c.CircuitBreaker.Cooldown = cooldown
+	}
+
+	if status, ok := f.lookupEnv("VILLIP_CIRCUIT_STATUS"); ok {
+		n, err := strconv.Atoi(status)
+		if err != nil {
+			f.log.Fatalf("%s is not a valid circuit breaker status", status)
+		}
+
+		c.CircuitBreaker.Status = n
+	}
+
+	if body, ok := f.lookupEnv("VILLIP_CIRCUIT_BODY"); ok {
+		c.CircuitBreaker.Body = body
+	}
+
+	if flushInterval, ok := f.lookupEnv("VILLIP_FLUSHINTERVAL"); ok {
+		c.FlushInterval = flushInterval
+	}
+
 	if dumpFolder, ok := f.lookupEnv("VILLIP_DUMPFOLDER"); ok {
 		c.Dump.Folder = dumpFolder
 	}
@@ -88,7 +237,9 @@ func (f *Factory) NewFromEnv() (string, uint8, FilteredServer) {
 			urls = strings.Split(strings.ReplaceAll(urlList, " ", ""), ",")
 		}
 
-		c.Response.Replace = append(c.Response.Replace, Creplacement{From: from, To: to, Urls: urls})
+		_, regex := f.lookupEnv("VILLIP_REGEX")
+
+		c.Response.Replace = append(c.Response.Replace, Creplacement{From: from, To: to, Urls: urls, Regex: regex})
 	}
 
 	if restricteds, ok = f.lookupEnv("VILLIP_RESTRICTED"); ok {
@@ -113,7 +264,9 @@ func (f *Factory) NewFromEnv() (string, uint8, FilteredServer) {
 			urls = strings.Split(strings.ReplaceAll(urlList, " ", ""), ",")
 		}
 
-		c.Response.Replace = append(c.Response.Replace, Creplacement{From: from, To: to, Urls: urls})
+		_, regex := f.lookupEnv(fmt.Sprintf("VILLIP_REGEX_%d", i))
+
+		c.Response.Replace = append(c.Response.Replace, Creplacement{From: from, To: to, Urls: urls, Regex: regex})
 		i++
 	}
 
@@ -136,7 +289,9 @@ func (f *Factory) NewFromEnv() (string, uint8, FilteredServer) {
 			f.log.Fatalf("Missing VILLIP_PREFIX_TO environment variable", i)
 		}
 
-		c.Prefix = []Creplacement{{From: from, To: to, Urls: []string{}}}
+		_, reverse := f.lookupEnv("VILLIP_PREFIX_REVERSE")
+
+		c.Prefix = []Creplacement{{From: from, To: to, Urls: []string{}, Reverse: reverse}}
 	}
 
 	return f.newFromConfig(f.log, c)
@@ -109,6 +109,42 @@ func TestNewFromEnv(t *testing.T) {
 				Type:       "",
 				URL:        "http://localhost:8081"},
 		},
+		{
+			"wrong idle connections",
+			args{map[string]string{
+				"VILLIP_URL":                 "http://localhost:8081",
+				"VILLIP_MAXIDLECONNSPERHOST": "many",
+			}},
+			true,
+			filter.Config{},
+		},
+		{
+			"wrong attempts",
+			args{map[string]string{
+				"VILLIP_URL":            "http://localhost:8081",
+				"VILLIP_RETRY_ATTEMPTS": "twice",
+			}},
+			true,
+			filter.Config{},
+		},
+		{
+			"wrong retry status",
+			args{map[string]string{
+				"VILLIP_URL":          "http://localhost:8081",
+				"VILLIP_RETRY_STATUS": "502,bad",
+			}},
+			true,
+			filter.Config{},
+		},
+		{
+			"wrong circuit failures",
+			args{map[string]string{
+				"VILLIP_URL":              "http://localhost:8081",
+				"VILLIP_CIRCUIT_FAILURES": "many",
+			}},
+			true,
+			filter.Config{},
+		},
 		{
 			"missing to",
 			args{map[string]string{
@@ -171,27 +207,83 @@ func TestNewFromEnv(t *testing.T) {
 				URL:        "http://localhost:8081",
 			},
 		},
+		{
+			"several upstreams",
+			args{map[string]string{
+				"VILLIP_URL":                  "http://app1:8081, http://app2:8081",
+				"VILLIP_BALANCING":            "hash",
+				"VILLIP_BALANCING_COOKIE":     "SESSION",
+				"VILLIP_BALANCING_HEADER":     "X-User",
+				"VILLIP_HEALTHCHECK_PATH":     "/health",
+				"VILLIP_HEALTHCHECK_INTERVAL": "5s",
+				"VILLIP_HEALTHCHECK_TIMEOUT":  "1s",
+			}},
+			false,
+			filter.Config{
+				Balancing: filter.Cbalancing{Strategy: "hash", Cookie: "SESSION", Header: "X-User"},
+				HealthCheck: filter.ChealthCheck{
+					Path:     "/health",
+					Interval: "5s",
+					Timeout:  "1s",
+				},
+				Port:    8080,
+				Prefix:  []filter.Creplacement{},
+				Replace: []filter.Creplacement{},
+				Request: filter.Caction{
+					Replace: []filter.Creplacement{},
+					Header:  []filter.Cheader{},
+				},
+				Response: filter.Caction{
+					Replace: []filter.Creplacement{},
+					Header:  []filter.Cheader{},
+				},
+				URLs: []string{"http://app1:8081", "http://app2:8081"},
+			},
+		},
 		{
 			"maximal",
 			args{map[string]string{
-				"VILLIP_URL":         "http://localhost:1234/url1",
-				"VILLIP_PORT":        "8081",
-				"VILLIP_PRIORITY":    "100",
-				"VILLIP_FORCE":       "1",
-				"VILLIP_INSECURE":    "1",
-				"VILLIP_DUMPFOLDER":  "/var/log/villip/dump",
-				"VILLIP_DUMPURLS":    "/books/,/movies/",
-				"VILLIP_FROM":        "book",
-				"VILLIP_TO":          "smartphone",
-				"VILLIP_FOR":         "/youngsters/",
-				"VILLIP_FROM_1":      "dance",
-				"VILLIP_TO_1":        "chat",
-				"VILLIP_FOR_1":       "/youngsters/,/geeks/",
-				"VILLIP_TYPES":       "text/html,application/json",
-				"VILLIP_RESTRICTED":  "192.168.1.0/24,192.168.8.0/24",
-				"VILLIP_PREFIX_FROM": "/env/",
-				"VILLIP_PREFIX_TO":   "/",
-				"VILLIP_STATUS":      "202,203",
+				"VILLIP_URL":                    "http://localhost:1234/url1",
+				"VILLIP_PORT":                   "8081",
+				"VILLIP_PRIORITY":               "100",
+				"VILLIP_FORCE":                  "1",
+				"VILLIP_INSECURE":               "1",
+				"VILLIP_DUMPFOLDER":             "/var/log/villip/dump",
+				"VILLIP_DUMPURLS":               "/books/,/movies/",
+				"VILLIP_FROM":                   "book",
+				"VILLIP_TO":                     "smartphone",
+				"VILLIP_FOR":                    "/youngsters/",
+				"VILLIP_FROM_1":                 "dance",
+				"VILLIP_TO_1":                   "chat",
+				"VILLIP_FOR_1":                  "/youngsters/,/geeks/",
+				"VILLIP_REGEX_1":                "1",
+				"VILLIP_TYPES":                  "text/html,application/json",
+				"VILLIP_RESTRICTED":             "192.168.1.0/24,192.168.8.0/24",
+				"VILLIP_PREFIX_FROM":            "/env/",
+				"VILLIP_PREFIX_TO":              "/",
+				"VILLIP_PREFIX_REVERSE":         "1",
+				"VILLIP_STATUS":                 "202,203",
+				"VILLIP_AUTOREBASE":             "1",
+				"VILLIP_PUBLICURL":              "https://public.example.com",
+				"VILLIP_FLUSHINTERVAL":          "100ms",
+				"VILLIP_WEBSOCKET":              "filter",
+				"VILLIP_MAXIDLECONNSPERHOST":    "64",
+				"VILLIP_IDLETIMEOUT":            "1m",
+				"VILLIP_DIALTIMEOUT":            "5s",
+				"VILLIP_RESPONSEHEADERTIMEOUT":  "20s",
+				"VILLIP_HTTP2":                  "1",
+				"VILLIP_TIMEOUT":                "30s",
+				"VILLIP_RETRY_ATTEMPTS":         "3",
+				"VILLIP_RETRY_BACKOFF":          "50ms",
+				"VILLIP_RETRY_STATUS":           "502, 503",
+				"VILLIP_MATCH_PATHS":            "^/api/, ^/static/",
+				"VILLIP_MATCH_REGEX":            "1",
+				"VILLIP_MATCH_METHODS":          "GET,POST",
+				"VILLIP_CIRCUIT_FAILURES":       "5",
+				"VILLIP_CIRCUIT_FAILUREPERCENT": "50",
+				"VILLIP_CIRCUIT_COOLDOWN":       "10s",
+				"VILLIP_CIRCUIT_STATUS":         "502",
+				"VILLIP_CIRCUIT_BODY":           "Come back later",
 			}},
 			false,
 			filter.Config{
@@ -200,19 +292,28 @@ func TestNewFromEnv(t *testing.T) {
 					Folder: "/var/log/villip/dump",
 					URLs:   []string{"/books/", "/movies/"},
 				},
-				Force:    true,
-				Insecure: true,
-				Port:     8081,
+				AutoRebase:    true,
+				FlushInterval: "100ms",
+				Force:         true,
+				Insecure:      true,
+				Match: filter.Croute{
+					Paths:   []string{"^/api/", "^/static/"},
+					Regex:   true,
+					Methods: []string{"GET", "POST"},
+				},
+				Port: 8081,
 				Prefix: []filter.Creplacement{
 					{
-						From: "/env/",
-						To:   "/",
-						Urls: []string{},
+						From:    "/env/",
+						To:      "/",
+						Urls:    []string{},
+						Reverse: true,
 					},
 				},
-				Priority: 100,
-				Status:   []string{"202", "203"},
-				Replace:  []filter.Creplacement{},
+				Priority:  100,
+				PublicURL: "https://public.example.com",
+				Status:    []string{"202", "203"},
+				Replace:   []filter.Creplacement{},
 				Response: filter.Caction{
 					Replace: []filter.Creplacement{
 						{
@@ -221,9 +322,10 @@ func TestNewFromEnv(t *testing.T) {
 							Urls: []string{"/youngsters/"},
 						},
 						{
-							From: "dance",
-							To:   "chat",
-							Urls: []string{"/youngsters/", "/geeks/"},
+							From:  "dance",
+							To:    "chat",
+							Urls:  []string{"/youngsters/", "/geeks/"},
+							Regex: true,
 						},
 					},
 					Header: []filter.Cheader{},
@@ -235,7 +337,28 @@ func TestNewFromEnv(t *testing.T) {
 				Restricted: []string{"192.168.1.0/24", "192.168.8.0/24"},
 				Token:      []filter.CtokenAction(nil),
 				Type:       "",
-				URL:        "http://localhost:1234/url1",
+				Transport: filter.Ctransport{
+					MaxIdleConnsPerHost:   64,
+					IdleTimeout:           "1m",
+					DialTimeout:           "5s",
+					ResponseHeaderTimeout: "20s",
+					HTTP2:                 true,
+					Timeout:               "30s",
+				},
+				Retry: filter.Cretry{
+					Attempts: 3,
+					Backoff:  "50ms",
+					Status:   []int{502, 503},
+				},
+				CircuitBreaker: filter.CcircuitBreaker{
+					Failures:       5,
+					FailurePercent: 50,
+					Cooldown:       "10s",
+					Status:         502,
+					Body:           "Come back later",
+				},
+				URL:       "http://localhost:1234/url1",
+				Websocket: "filter",
 			},
 		},
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -24,6 +25,8 @@ func (f *Factory) NewFromYAML(filePath string) (string, uint8, FilteredServer) {
 		log.Fatalf("Cannot decode YAML: %v", err)
 	}
 
+	resolvePaths(&c, filepath.Dir(filePath))
+
 	return f.newFromConfig(log, c)
 }
 
@@ -43,5 +46,31 @@ func (f *Factory) NewFromJSON(filePath string) (string, uint8, FilteredServer) {
 		log.Fatalf("Cannot decode JSON: %v", err)
 	}
 
+	resolvePaths(&c, filepath.Dir(filePath))
+
 	return f.newFromConfig(log, c)
 }
+
+// resolvePaths makes the paths of the files referenced by the configuration relative to the folder of the configuration file.
+func resolvePaths(c *Config, folder string) {
+	for _, action := range []*Caction{&c.Request, &c.Response} {
+		for i, d := range action.Dictionary {
+			if d.File != "" && !filepath.IsAbs(d.File) {
+				action.Dictionary[i].File = filepath.Join(folder, d.File)
+			}
+		}
+
+		for i, t := range action.Transform {
+			// A command without path is looked for in the PATH
+			if strings.Contains(t.Command, "/") && !filepath.IsAbs(t.Command) {
+				action.Transform[i].Command = filepath.Join(folder, t.Command)
+			}
+		}
+
+		for i, inject := range action.Inject {
+			if inject.File != "" && !filepath.IsAbs(inject.File) {
+				action.Inject[i].File = filepath.Join(folder, inject.File)
+			}
+		}
+	}
+}
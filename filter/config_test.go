@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	logrustest "github.com/sirupsen/logrus/hooks/test"
@@ -110,6 +111,19 @@ func Test_newFromConfig(t *testing.T) {
 			0,
 			&Filter{},
 		},
+		{
+			"RequestHTML",
+			args{Config{
+				URL: "http://localhost:8080",
+				Request: Caction{
+					HTML: []Creplacement{{From: "http://localhost:8080/", To: "/"}},
+				},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
 		{
 			"ResponseAndReplace",
 			args{Config{
@@ -171,11 +185,11 @@ func Test_newFromConfig(t *testing.T) {
 				prefix:   []replaceParameters{},
 				response: response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				request: request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -208,11 +222,11 @@ func Test_newFromConfig(t *testing.T) {
 				prefix:   []replaceParameters{},
 				response: response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				request: request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/xml", "appplication/xsl"},
 				restricted:   []*net.IPNet{},
@@ -257,11 +271,11 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				request: request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -309,11 +323,11 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				request: request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -359,12 +373,12 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				prefix: []replaceParameters{},
 				response: response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -420,7 +434,7 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				response: response{
 					Replace: []replaceParameters{
@@ -433,7 +447,7 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -528,16 +542,16 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "dev",
-							Force: false,
+							name:  "X-ENV",
+							value: "dev",
+							force: false,
 						},
 						{
-							Name:  "X-Version",
-							Value: "1.2",
-							Force: true,
+							name:  "X-Version",
+							value: "1.2",
+							force: true,
 						},
 					},
 				},
@@ -552,16 +566,16 @@ func Test_newFromConfig(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-TEST",
-							Value: "valid",
-							Force: false,
+							name:  "X-TEST",
+							value: "valid",
+							force: false,
 						},
 						{
-							Name:  "X-Author",
-							Value: "bob",
-							Force: true,
+							name:  "X-Author",
+							value: "bob",
+							force: true,
 						},
 					},
 				},
@@ -619,31 +633,31 @@ func Test_newFromConfig(t *testing.T) {
 				prefix:   []replaceParameters{},
 				request: request{
 					Replace: []replaceParameters{},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "dev",
-							Force: false,
+							name:  "X-ENV",
+							value: "dev",
+							force: false,
 						},
 					},
 				},
 				response: response{
 					Replace: []replaceParameters{},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-TEST",
-							Value: "valid",
-							Force: false,
+							name:  "X-TEST",
+							value: "valid",
+							force: false,
 						},
 						{
-							Name:  "X-Author",
-							Value: "bob",
-							Force: true,
+							name:  "X-Author",
+							value: "bob",
+							force: true,
 						},
 						{
-							Name:  "X-Version",
-							Value: "1.2",
-							Force: true,
+							name:  "X-Version",
+							value: "1.2",
+							force: true,
 						},
 					},
 				},
@@ -689,11 +703,11 @@ func Test_newFromConfig(t *testing.T) {
 				prefix:   []replaceParameters{},
 				response: response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				request: request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				contentTypes: []string{"text/html", "text/css", "application/javascript"},
 				restricted:   []*net.IPNet{},
@@ -723,6 +737,288 @@ func Test_newFromConfig(t *testing.T) {
 				kind:     HTTP,
 			},
 		},
+		{
+			"flushInterval and websocket",
+			args{Config{
+				URL:           "http://localhost:8080",
+				FlushInterval: "-1ms",
+				Websocket:     "filter",
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes:  []string{"text/html", "text/css", "application/javascript"},
+				restricted:    []*net.IPNet{},
+				token:         map[string][]headerConditions{},
+				url:           "http://localhost:8080",
+				port:          "8080",
+				priority:      "0",
+				dumpURLs:      []*regexp.Regexp{},
+				status:        []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:          HTTP,
+				flushInterval: -time.Millisecond,
+				websocket:     websocketFilter,
+			},
+		},
+		{
+			"rewrite",
+			args{Config{
+				URL:     "http://localhost:8080",
+				Rewrite: []Crewrite{{From: "^/blog/(.*)$", To: "/articles/$1"}},
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes: []string{"text/html", "text/css", "application/javascript"},
+				restricted:   []*net.IPNet{},
+				token:        map[string][]headerConditions{},
+				url:          "http://localhost:8080",
+				port:         "8080",
+				priority:     "0",
+				dumpURLs:     []*regexp.Regexp{},
+				status:       []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:         HTTP,
+				rewrite:      []rewriteRule{{from: regexp.MustCompile("^/blog/(.*)$"), to: "/articles/$1"}},
+			},
+		},
+		{
+			"url and urls",
+			args{Config{
+				URL:  "http://localhost:8080",
+				URLs: []string{"http://localhost:8081", "http://localhost:8082"},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"balancing without urls",
+			args{Config{
+				URL:       "http://localhost:8080",
+				Balancing: Cbalancing{Strategy: "random"},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"tcp with urls",
+			args{Config{
+				URLs: []string{"tcp://localhost:8081", "tcp://localhost:8082"},
+				Type: "tcp",
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"timeout and retry",
+			args{Config{
+				URL:       "http://localhost:8080",
+				Transport: Ctransport{Timeout: "30s"},
+				Retry:     Cretry{Attempts: 3, Status: []int{503}},
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes: []string{"text/html", "text/css", "application/javascript"},
+				restricted:   []*net.IPNet{},
+				token:        map[string][]headerConditions{},
+				url:          "http://localhost:8080",
+				port:         "8080",
+				priority:     "0",
+				dumpURLs:     []*regexp.Regexp{},
+				status:       []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:         HTTP,
+				timeout:      30 * time.Second,
+				retry:        &retryPolicy{attempts: 3, backoff: 100 * time.Millisecond, maxBackoff: 2 * time.Second, status: []int{503}},
+			},
+		},
+		{
+			"match",
+			args{Config{
+				URL:   "http://localhost:8080",
+				Match: Croute{Paths: []string{"/api/"}, Methods: []string{"get"}},
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes: []string{"text/html", "text/css", "application/javascript"},
+				restricted:   []*net.IPNet{},
+				token:        map[string][]headerConditions{},
+				url:          "http://localhost:8080",
+				port:         "8080",
+				priority:     "0",
+				dumpURLs:     []*regexp.Regexp{},
+				status:       []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:         HTTP,
+				route:        &route{prefixes: []string{"/api/"}, methods: []string{"GET"}},
+			},
+		},
+		{
+			"tcp with match",
+			args{Config{
+				URL:   "tcp://localhost:8081",
+				Type:  "tcp",
+				Match: Croute{Paths: []string{"/api/"}},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"circuit breaker",
+			args{Config{
+				URL:            "http://localhost:8080",
+				CircuitBreaker: CcircuitBreaker{Failures: 5, Cooldown: "10s", Status: 502, Body: "Come back later"},
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes: []string{"text/html", "text/css", "application/javascript"},
+				restricted:   []*net.IPNet{},
+				token:        map[string][]headerConditions{},
+				url:          "http://localhost:8080",
+				port:         "8080",
+				priority:     "0",
+				dumpURLs:     []*regexp.Regexp{},
+				status:       []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:         HTTP,
+				circuit:      &circuitConfig{failures: 5, window: 20, cooldown: 10 * time.Second, status: 502, body: "Come back later"},
+			},
+		},
+		{
+			"stream",
+			args{Config{
+				URL:    "http://localhost:8080",
+				Stream: true,
+			}},
+			false,
+			"8080",
+			0,
+			&Filter{
+				prefix: []replaceParameters{},
+				response: response{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				request: request{
+					Replace: []replaceParameters{},
+					Header:  []headerRule{},
+				},
+				contentTypes: []string{
+					"text/html", "text/css", "application/javascript", "text/event-stream", "application/x-ndjson",
+				},
+				restricted: []*net.IPNet{},
+				token:      map[string][]headerConditions{},
+				url:        "http://localhost:8080",
+				port:       "8080",
+				priority:   "0",
+				dumpURLs:   []*regexp.Regexp{},
+				status:     []int{http.StatusOK, http.StatusFound, http.StatusMovedPermanently},
+				kind:       HTTP,
+				stream:     true,
+			},
+		},
+		{
+			"tcp with circuit breaker",
+			args{Config{
+				URL:            "tcp://localhost:8081",
+				Type:           "tcp",
+				CircuitBreaker: CcircuitBreaker{Failures: 5},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"wrong timeout",
+			args{Config{
+				URL:       "http://localhost:8080",
+				Transport: Ctransport{Timeout: "30"},
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"wrong websocket",
+			args{Config{
+				URL:       "http://localhost:8080",
+				Websocket: "proxy",
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
+		{
+			"wrong flushInterval",
+			args{Config{
+				URL:           "http://localhost:8080",
+				FlushInterval: "-1",
+			}},
+			true,
+			"8080",
+			0,
+			&Filter{},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -756,6 +1052,19 @@ func Test_newFromConfig(t *testing.T) {
 			g2 := got2.(*Filter)
 			g2.log = nil
 
+			if (g2.kind == HTTP) != (g2.proxy != nil && g2.transport != nil) {
+				t.Errorf("newFromConfig() proxy = %v, transport = %v", g2.proxy, g2.transport)
+			}
+
+			g2.proxy = nil
+			g2.transport = nil
+
+			if (g2.circuit != nil) != (len(g2.breakers) == len(g2.upstreamURLs())) {
+				t.Errorf("newFromConfig() breakers = %v", g2.breakers)
+			}
+
+			g2.breakers = nil
+
 			if len(g2.restricted) != len(tt.args.c.Restricted) {
 				t.Errorf("restricted does not have the correct number of element got %d, want %d", len(g2.restricted), len(tt.args.c.Restricted))
 			}
@@ -873,6 +1182,44 @@ func Test_parseReplaceConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			"regex",
+			args{
+				[]Creplacement{
+					{
+						From:  `legacy-(\w+)\.corp`,
+						To:    "/svc/$1",
+						Urls:  []string{"/"},
+						Regex: true,
+					},
+				},
+				[]replaceParameters{},
+			},
+			false,
+			[]replaceParameters{
+				{
+					from:  `legacy-(\w+)\.corp`,
+					to:    "/svc/$1",
+					urls:  []*regexp.Regexp{regexp.MustCompile("^/")},
+					regex: regexp.MustCompile(`legacy-(\w+)\.corp`),
+				},
+			},
+		},
+		{
+			"regex error",
+			args{
+				[]Creplacement{
+					{
+						From:  "legacy-(",
+						To:    "/svc/$1",
+						Regex: true,
+					},
+				},
+				[]replaceParameters{},
+			},
+			true,
+			[]replaceParameters{},
+		},
 		{
 			"prefixed",
 			args{
@@ -1141,3 +1488,433 @@ func TestFilter_convertStatus(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseJSONConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       []Cjson
+		expectFatal bool
+		want        []jsonParameters
+	}{
+		{
+			"all actions",
+			[]Cjson{
+				{Path: "$.url", Action: "set", Value: `"http://public"`, Urls: []string{"/api"}},
+				{Path: "$.id", Action: "Replace", Value: `12`},
+				{Path: "$.secret", Action: "delete"},
+				{Path: "$..href", Action: "strings", From: "http://backend", To: "http://public"},
+				{Path: "$..href", Action: "strings", From: `backend(\d)`, To: "public$1", Regex: true},
+			},
+			false,
+			[]jsonParameters{
+				{
+					path:   jsonPath{{kind: stepKey, key: "url"}},
+					action: jsonSet,
+					value:  `"http://public"`,
+					urls:   []*regexp.Regexp{regexp.MustCompile("^/dev/api")},
+					source: "$.url",
+				},
+				{
+					path:   jsonPath{{kind: stepKey, key: "id"}},
+					action: jsonReplace,
+					value:  `12`,
+					urls:   []*regexp.Regexp{},
+					source: "$.id",
+				},
+				{
+					path:   jsonPath{{kind: stepKey, key: "secret"}},
+					action: jsonDelete,
+					urls:   []*regexp.Regexp{},
+					source: "$.secret",
+				},
+				{
+					path:    jsonPath{{kind: stepRecursive, key: "href"}},
+					action:  jsonStrings,
+					replace: replaceParameters{from: "http://backend", to: "http://public", urls: []*regexp.Regexp{}},
+					urls:    []*regexp.Regexp{},
+					source:  "$..href",
+				},
+				{
+					path:   jsonPath{{kind: stepRecursive, key: "href"}},
+					action: jsonStrings,
+					replace: replaceParameters{
+						from:  `backend(\d)`,
+						to:    "public$1",
+						urls:  []*regexp.Regexp{},
+						regex: regexp.MustCompile(`backend(\d)`),
+					},
+					urls:   []*regexp.Regexp{},
+					source: "$..href",
+				},
+			},
+		},
+		{
+			"wrong path",
+			[]Cjson{{Path: "url", Action: "delete"}},
+			true,
+			nil,
+		},
+		{
+			"wrong action",
+			[]Cjson{{Path: "$.url", Action: "move"}},
+			true,
+			nil,
+		},
+		{
+			"wrong value",
+			[]Cjson{{Path: "$.url", Action: "set", Value: "http://public"}},
+			true,
+			nil,
+		},
+		{
+			"missing from",
+			[]Cjson{{Path: "$.url", Action: "strings", To: "http://public"}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+			log.SetLevel(logrus.DebugLevel)
+
+			prefix := []replaceParameters{{from: "/", to: "/dev/", urls: []*regexp.Regexp{}}}
+			got := parseJSONConfig(log, tt.rules, prefix)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseJSONConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if fatal {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseHeaderConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     []Cheader
+		expectFatal bool
+		want        []headerRule
+	}{
+		{
+			"valid",
+			[]Cheader{
+				{Name: "X-Powered-By", Delete: true},
+				{Name: "Link", From: "http://legacy/", To: "/"},
+				{Name: "Location", From: `^http://legacy(:\d+)?/`, To: "/", Regex: true},
+				{Name: "Cache-Control", Value: "no-store", Urls: []string{"/api/"}},
+			},
+			false,
+			[]headerRule{
+				{name: "X-Powered-By", delete: true},
+				{name: "Link", from: "http://legacy/", to: "/"},
+				{name: "Location", from: `^http://legacy(:\d+)?/`, to: "/", regex: regexp.MustCompile(`^http://legacy(:\d+)?/`)},
+				{
+					name:  "Cache-Control",
+					value: "no-store",
+					urls:  []*regexp.Regexp{regexp.MustCompile("^/dev/api/")},
+				},
+			},
+		},
+		{
+			"missing name",
+			[]Cheader{{Value: "1"}},
+			true,
+			nil,
+		},
+		{
+			"delete and rename",
+			[]Cheader{{Name: "X-Old", Delete: true, Rename: "X-New"}},
+			true,
+			nil,
+		},
+		{
+			"regex without from",
+			[]Cheader{{Name: "Location", To: "/", Regex: true}},
+			true,
+			nil,
+		},
+		{
+			"wrong regex",
+			[]Cheader{{Name: "Location", From: "(", Regex: true}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+			log.SetLevel(logrus.DebugLevel)
+
+			prefix := []replaceParameters{{from: "/", to: "/dev/", urls: []*regexp.Regexp{}}}
+			got := parseHeaderConfig(log, tt.headers, prefix)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseHeaderConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if fatal {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHeaderConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseCookiesConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		cookies     Ccookies
+		expectFatal bool
+		want        *cookieRules
+	}{
+		{
+			"valid",
+			Ccookies{
+				Name:          []Creplacement{{From: "JSESSIONID", To: "LEGACYSESSION"}},
+				Domain:        []Creplacement{{From: "legacy.internal", To: "example.com", Urls: []string{"/app"}}},
+				ReversePrefix: true,
+				Secure:        "Add",
+				SameSite:      "Lax",
+			},
+			false,
+			&cookieRules{
+				name:          []replaceParameters{{from: "JSESSIONID", to: "LEGACYSESSION", urls: []*regexp.Regexp{}}},
+				domain:        []replaceParameters{{from: "legacy.internal", to: "example.com", urls: []*regexp.Regexp{regexp.MustCompile("^/dev/app")}}},
+				path:          []replaceParameters{},
+				reversePrefix: true,
+				secure:        cookieAdd,
+				sameSite:      "lax",
+			},
+		},
+		{
+			"wrong secure",
+			Ccookies{Secure: "force"},
+			true,
+			nil,
+		},
+		{
+			"wrong samesite",
+			Ccookies{SameSite: "always"},
+			true,
+			nil,
+		},
+		{
+			"regex name",
+			Ccookies{Name: []Creplacement{{From: "SID.*", To: "LSID", Regex: true}}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+			log.SetLevel(logrus.DebugLevel)
+
+			prefix := []replaceParameters{{from: "/", to: "/dev/", urls: []*regexp.Regexp{}}}
+			got := parseCookiesConfig(log, &tt.cookies, prefix)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseCookiesConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if fatal {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCookiesConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePrefixConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      []Creplacement
+		expectFatal bool
+		want        []replaceParameters
+	}{
+		{
+			"valid",
+			[]Creplacement{
+				{From: "/dev/", To: "/", Reverse: true, ReverseLinks: true},
+				{From: "/quick", To: "/urgent", Urls: []string{"/quick/a"}},
+			},
+			false,
+			[]replaceParameters{
+				{from: "/dev/", to: "/", urls: []*regexp.Regexp{}, reverse: true, reverseLinks: true},
+				{from: "/quick", to: "/urgent", urls: []*regexp.Regexp{regexp.MustCompile("^/quick/a")}},
+			},
+		},
+		{
+			"links without reverse",
+			[]Creplacement{{From: "/dev/", To: "/", ReverseLinks: true}},
+			true,
+			nil,
+		},
+		{
+			"regex reversed",
+			[]Creplacement{{From: "/(v[0-9])/", To: "/", Regex: true, Reverse: true}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+			log.SetLevel(logrus.DebugLevel)
+
+			got := parsePrefixConfig(log, tt.prefix)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parsePrefixConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if fatal {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePrefixConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePublicURLConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		upstream    string
+		public      string
+		expectFatal bool
+		want        string
+	}{
+		{"from request", "http://legacy:8080", "", false, ""},
+		{"public url", "http://legacy:8080", "https://public.example.com/", false, "https://public.example.com"},
+		{"relative upstream", "/legacy", "", true, ""},
+		{"relative public url", "http://legacy:8080", "public.example.com", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := parsePublicURLConfig(log, tt.upstream, tt.public)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parsePublicURLConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && got != tt.want {
+				t.Errorf("parsePublicURLConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseConditionsConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		conditions  *Cconditions
+		expectFatal bool
+		want        *conditions
+	}{
+		{"none", nil, false, nil},
+		{
+			"all",
+			&Cconditions{
+				Methods:      []string{"POST"},
+				Status:       []int{404},
+				ContentTypes: []string{"application/json"},
+				Headers:      []Cmatch{{Name: "X-Tenant", Value: "^acme$"}},
+				Query:        []Cmatch{{Name: "debug"}},
+			},
+			false,
+			&conditions{
+				methods:      []string{"POST"},
+				status:       []int{404},
+				contentTypes: []string{"application/json"},
+				headers:      []valueCondition{{name: "X-Tenant", value: regexp.MustCompile("^acme$")}},
+				query:        []valueCondition{{name: "debug"}},
+			},
+		},
+		{"missing name", &Cconditions{Headers: []Cmatch{{Value: "1"}}}, true, nil},
+		{"wrong regex", &Cconditions{Query: []Cmatch{{Name: "id", Value: "("}}}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := parseConditionsConfig(log, tt.conditions)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseConditionsConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConditionsConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkRequestConditions(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      Caction
+		expectFatal bool
+	}{
+		{"no conditions", Caction{Replace: []Creplacement{{From: "a"}}}, false},
+		{"method", Caction{Header: []Cheader{{Name: "X-A", When: &Cconditions{Methods: []string{"GET"}}}}}, false},
+		{"status", Caction{JSON: []Cjson{{Path: "$", When: &Cconditions{Status: []int{404}}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			checkRequestConditions(log, tt.action)
+
+			if fatal := HadErrorLevel(hook, logrus.FatalLevel); fatal != tt.expectFatal {
+				t.Errorf("checkRequestConditions() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+		})
+	}
+}
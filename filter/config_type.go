@@ -1,11 +1,40 @@
 package filter
 
+// Configuration of a condition on a request header or a query parameter.
+type Cmatch struct {
+	Name string `yaml:"name" json:"name,omitempty"`
+	// +kubebuilder:validation:Optional
+	Value string `yaml:"value" json:"value,omitempty"`
+}
+
+// Configuration of the conditions restricting a rule.
+type Cconditions struct {
+	// +kubebuilder:validation:Optional
+	Methods []string `yaml:"methods" json:"methods,omitempty"`
+	// +kubebuilder:validation:Optional
+	Status []int `yaml:"status" json:"status,omitempty"`
+	// +kubebuilder:validation:Optional
+	ContentTypes []string `yaml:"content-types" json:"content-types,omitempty"` //nolint: tagliatelle
+	// +kubebuilder:validation:Optional
+	Headers []Cmatch `yaml:"headers" json:"headers,omitempty"`
+	// +kubebuilder:validation:Optional
+	Query []Cmatch `yaml:"query" json:"query,omitempty"`
+}
+
 // Configuration  for replacement.
 type Creplacement struct {
 	From string `yaml:"from" json:"from,omitempty"`
 	To   string `yaml:"to" json:"to,omitempty"`
 	// +kubebuilder:validation:Optional
 	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:default=false
+	Regex bool `yaml:"regex" json:"regex,omitempty"`
+	// +kubebuilder:default=false
+	Reverse bool `yaml:"reverse" json:"reverse,omitempty"`
+	// +kubebuilder:default=false
+	ReverseLinks bool `yaml:"reverseLinks" json:"reverseLinks,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
 }
 
 // Configuration for dump log.
@@ -24,12 +53,129 @@ type Cheader struct {
 	Add bool `yaml:"add" json:"add,omitempty"`
 	// +kubebuilder:validation:Optional
 	UUID bool `yaml:"uuid" json:"uuid,omitempty"`
+	// +kubebuilder:validation:Optional
+	From string `yaml:"from" json:"from,omitempty"`
+	// +kubebuilder:validation:Optional
+	To string `yaml:"to" json:"to,omitempty"`
+	// +kubebuilder:default=false
+	Regex bool `yaml:"regex" json:"regex,omitempty"`
+	// +kubebuilder:default=false
+	Delete bool `yaml:"delete" json:"delete,omitempty"`
+	// +kubebuilder:validation:Optional
+	Rename string `yaml:"rename" json:"rename,omitempty"`
+	// +kubebuilder:validation:Optional
+	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
+}
+
+// Configuration for JSON body transformation.
+type Cjson struct {
+	Path string `yaml:"path" json:"path,omitempty"`
+	// +kubebuilder:validation:Enum=set;replace;delete;strings
+	Action string `yaml:"action" json:"action,omitempty"`
+	// +kubebuilder:validation:Optional
+	Value string `yaml:"value" json:"value,omitempty"`
+	// +kubebuilder:validation:Optional
+	From string `yaml:"from" json:"from,omitempty"`
+	// +kubebuilder:validation:Optional
+	To string `yaml:"to" json:"to,omitempty"`
+	// +kubebuilder:default=false
+	Regex bool `yaml:"regex" json:"regex,omitempty"`
+	// +kubebuilder:validation:Optional
+	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
+}
+
+// Configuration for replacement dictionary files.
+type Cdictionary struct {
+	File string `yaml:"file" json:"file,omitempty"`
+	// +kubebuilder:validation:Optional
+	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
+}
+
+// Configuration for content injection in the HTML pages.
+type Cinject struct {
+	// +kubebuilder:validation:Enum=head-start;head-end;body-start;body-end
+	Position string `yaml:"position" json:"position,omitempty"`
+	// +kubebuilder:validation:Optional
+	Content string `yaml:"content" json:"content,omitempty"`
+	// +kubebuilder:validation:Optional
+	File string `yaml:"file" json:"file,omitempty"`
+	// +kubebuilder:validation:Optional
+	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
+}
+
+// Configuration for the external commands transforming the bodies.
+type Ctransform struct {
+	Command string `yaml:"command" json:"command,omitempty"`
+	// +kubebuilder:validation:Optional
+	Args []string `yaml:"args" json:"args,omitempty"`
+	// +kubebuilder:validation:Optional
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	Headers []string `yaml:"headers" json:"headers,omitempty"`
+	// +kubebuilder:validation:Optional
+	Urls []string `yaml:"urls" json:"urls,omitempty"`
+	// +kubebuilder:validation:Optional
+	When *Cconditions `yaml:"when" json:"when,omitempty"`
+}
+
+// Configuration for the rewriting of the request URLs.
+type Crewrite struct {
+	// +kubebuilder:validation:Optional
+	From string `yaml:"from" json:"from,omitempty"`
+	// +kubebuilder:validation:Optional
+	Query []Cmatch `yaml:"query" json:"query,omitempty"`
+	// +kubebuilder:validation:Optional
+	To string `yaml:"to" json:"to,omitempty"`
+	// +kubebuilder:validation:Optional
+	AddQuery map[string]string `yaml:"addQuery" json:"addQuery,omitempty"`
+	// +kubebuilder:validation:Optional
+	RemoveQuery []string `yaml:"removeQuery" json:"removeQuery,omitempty"`
+	// +kubebuilder:validation:Optional
+	RenameQuery map[string]string `yaml:"renameQuery" json:"renameQuery,omitempty"`
+}
+
+// Configuration for Set-Cookie rewriting.
+type Ccookies struct {
+	// +kubebuilder:validation:Optional
+	Name []Creplacement `yaml:"name" json:"name,omitempty"`
+	// +kubebuilder:validation:Optional
+	Domain []Creplacement `yaml:"domain" json:"domain,omitempty"`
+	// +kubebuilder:validation:Optional
+	Path []Creplacement `yaml:"path" json:"path,omitempty"`
+	// +kubebuilder:default=false
+	ReversePrefix bool `yaml:"reversePrefix" json:"reversePrefix,omitempty"`
+	// +kubebuilder:validation:Enum=add;strip
+	Secure string `yaml:"secure" json:"secure,omitempty"`
+	// +kubebuilder:validation:Enum=strict;lax;none;strip
+	SameSite string `yaml:"sameSite" json:"sameSite,omitempty"`
 }
 
 // Configuration for request and response  management.
 type Caction struct {
 	Replace []Creplacement `yaml:"replace" json:"replace,omitempty"`
 	Header  []Cheader      `yaml:"header" json:"header,omitempty"`
+	// +kubebuilder:validation:Optional
+	JSON []Cjson `yaml:"json" json:"json,omitempty"`
+	// +kubebuilder:validation:Optional
+	HTML []Creplacement `yaml:"html" json:"html,omitempty"`
+	// +kubebuilder:validation:Optional
+	Cookies *Ccookies `yaml:"cookies" json:"cookies,omitempty"`
+	// +kubebuilder:validation:Optional
+	Dictionary []Cdictionary `yaml:"dictionary" json:"dictionary,omitempty"`
+	// +kubebuilder:validation:Optional
+	Inject []Cinject `yaml:"inject" json:"inject,omitempty"`
+	// +kubebuilder:validation:Optional
+	Transform []Ctransform `yaml:"transform" json:"transform,omitempty"`
+	// +kubebuilder:validation:Optional
+	Transformers []string `yaml:"transformers" json:"transformers,omitempty"`
 }
 
 // Configuration for token management.
@@ -40,21 +186,90 @@ type CtokenAction struct {
 	Action string `yaml:"action" json:"action,omitempty"`
 }
 
+// Cbalancing configures the load balancing between the upstreams.
+type Cbalancing struct {
+	// +kubebuilder:validation:Enum=round-robin;random;least-connections;hash
+	Strategy string `yaml:"strategy" json:"strategy,omitempty"`
+	Cookie   string `yaml:"cookie" json:"cookie,omitempty"` // Key of the hash strategy
+	Header   string `yaml:"header" json:"header,omitempty"` // Key of the hash strategy if the cookie is absent
+}
+
+// ChealthCheck configures the active health checks of the upstreams.
+type ChealthCheck struct {
+	Path               string `yaml:"path" json:"path,omitempty"`
+	Interval           string `yaml:"interval" json:"interval,omitempty"`
+	Timeout            string `yaml:"timeout" json:"timeout,omitempty"`
+	UnhealthyThreshold int    `yaml:"unhealthyThreshold" json:"unhealthyThreshold,omitempty"`
+	HealthyThreshold   int    `yaml:"healthyThreshold" json:"healthyThreshold,omitempty"`
+}
+
+// Ctransport configures the connections to the upstreams.
+type Ctransport struct {
+	MaxIdleConnsPerHost   int    `yaml:"maxIdleConnsPerHost" json:"maxIdleConnsPerHost,omitempty"`
+	IdleTimeout           string `yaml:"idleTimeout" json:"idleTimeout,omitempty"`
+	DialTimeout           string `yaml:"dialTimeout" json:"dialTimeout,omitempty"`
+	ResponseHeaderTimeout string `yaml:"responseHeaderTimeout" json:"responseHeaderTimeout,omitempty"`
+	HTTP2                 bool   `yaml:"http2" json:"http2,omitempty"`
+	Timeout               string `yaml:"timeout" json:"timeout,omitempty"` // Total duration of a request, retries included
+}
+
+// Cretry configures the retries of the idempotent requests to the upstream.
+type Cretry struct {
+	Attempts   int    `yaml:"attempts" json:"attempts,omitempty"` // Including the first one
+	Backoff    string `yaml:"backoff" json:"backoff,omitempty"`
+	MaxBackoff string `yaml:"maxBackoff" json:"maxBackoff,omitempty"`
+	// +kubebuilder:validation:Optional
+	Status []int `yaml:"status" json:"status,omitempty"` // Retried in addition to the connection errors
+}
+
+// CcircuitBreaker configures the circuit breakers failing fast the requests to the upstreams after too many failures.
+type CcircuitBreaker struct {
+	Failures       int    `yaml:"failures" json:"failures,omitempty"`             // Consecutive failures opening the circuit
+	FailurePercent int    `yaml:"failurePercent" json:"failurePercent,omitempty"` // Percentage of failures over the window
+	Window         int    `yaml:"window" json:"window,omitempty"`                 // Number of requests of the failure percentage
+	Cooldown       string `yaml:"cooldown" json:"cooldown,omitempty"`             // Duration before trying the upstream again
+	Status         int    `yaml:"status" json:"status,omitempty"`                 // Answer while the circuit is open
+	Body           string `yaml:"body" json:"body,omitempty"`
+}
+
+// Croute selects the requests sent to a filter sharing its port with other filters.
+type Croute struct {
+	// +kubebuilder:validation:Optional
+	Paths []string `yaml:"paths" json:"paths,omitempty"` // Prefixes of the paths, regular expressions if regex is true
+	// +kubebuilder:default=false
+	Regex bool `yaml:"regex" json:"regex,omitempty"`
+	// +kubebuilder:validation:Optional
+	Methods []string `yaml:"methods" json:"methods,omitempty"`
+}
+
 // Rule configuration.
 type Config struct {
-	ContentTypes []string       `yaml:"content-types" json:"content-types,omitempty"` //nolint: tagliatelle
-	Dump         Cdump          `yaml:"dump" json:"dump,omitempty"`
-	Force        bool           `yaml:"force" json:"force,omitempty"`
-	Insecure     bool           `yaml:"insecure" json:"insecure,omitempty"`
-	Port         int            `yaml:"port" json:"port,omitempty"`
-	Prefix       []Creplacement `yaml:"prefix" json:"prefix,omitempty"`
-	Priority     uint8          `yaml:"priority" json:"priority,omitempty"`
-	Replace      []Creplacement `yaml:"replace" json:"replace,omitempty"`
-	Request      Caction        `yaml:"request" json:"request,omitempty"`
-	Response     Caction        `yaml:"response" json:"response,omitempty"`
-	Restricted   []string       `yaml:"restricted" json:"restricted,omitempty"`
-	Status       []string       `yaml:"status" json:"status,omitempty"`
-	Token        []CtokenAction `yaml:"token" json:"token,omitempty"`
-	Type         string         `yaml:"type" json:"type,omitempty"`
-	URL          string         `yaml:"url" json:"url,omitempty"`
+	AutoRebase     bool            `yaml:"autoRebase" json:"autoRebase,omitempty"`
+	Balancing      Cbalancing      `yaml:"balancing" json:"balancing,omitempty"`
+	CircuitBreaker CcircuitBreaker `yaml:"circuitBreaker" json:"circuitBreaker,omitempty"`
+	ContentTypes   []string        `yaml:"content-types" json:"content-types,omitempty"` //nolint: tagliatelle
+	Dump           Cdump           `yaml:"dump" json:"dump,omitempty"`
+	FlushInterval  string          `yaml:"flushInterval" json:"flushInterval,omitempty"`
+	Force          bool            `yaml:"force" json:"force,omitempty"`
+	HealthCheck    ChealthCheck    `yaml:"healthCheck" json:"healthCheck,omitempty"`
+	Insecure       bool            `yaml:"insecure" json:"insecure,omitempty"`
+	Match          Croute          `yaml:"match" json:"match,omitempty"`
+	Port           int             `yaml:"port" json:"port,omitempty"`
+	Prefix         []Creplacement  `yaml:"prefix" json:"prefix,omitempty"`
+	Priority       uint8           `yaml:"priority" json:"priority,omitempty"`
+	PublicURL      string          `yaml:"publicURL" json:"publicURL,omitempty"`
+	Replace        []Creplacement  `yaml:"replace" json:"replace,omitempty"`
+	Request        Caction         `yaml:"request" json:"request,omitempty"`
+	Response       Caction         `yaml:"response" json:"response,omitempty"`
+	Restricted     []string        `yaml:"restricted" json:"restricted,omitempty"`
+	Retry          Cretry          `yaml:"retry" json:"retry,omitempty"`
+	Rewrite        []Crewrite      `yaml:"rewrite" json:"rewrite,omitempty"`
+	Status         []string        `yaml:"status" json:"status,omitempty"`
+	Stream         bool            `yaml:"stream" json:"stream,omitempty"`
+	Token          []CtokenAction  `yaml:"token" json:"token,omitempty"`
+	Transport      Ctransport      `yaml:"transport" json:"transport,omitempty"`
+	Type           string          `yaml:"type" json:"type,omitempty"`
+	URL            string          `yaml:"url" json:"url,omitempty"`
+	URLs           []string        `yaml:"urls" json:"urls,omitempty"`
+	Websocket      string          `yaml:"websocket" json:"websocket,omitempty"`
 }
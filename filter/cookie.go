@@ -0,0 +1,141 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cookieAdd   = "add"
+	cookieStrip = "strip"
+)
+
+// Canonical values of the SameSite attribute.
+var cookieSameSite = map[string]string{"strict": "Strict", "lax": "Lax", "none": "None", cookieStrip: ""} //nolint: gochecknoglobals
+
+type cookieRules struct {
+	name          []replaceParameters
+	domain        []replaceParameters
+	path          []replaceParameters
+	reversePrefix bool
+	secure        string
+	sameSite      string
+}
+
+// renameCookie returns the new name of the cookie, the rules are applied from the to value to the from value if reverse is true.
+func renameCookie(requestURL string, name string, rules []replaceParameters, reverse bool) string {
+	for _, r := range rules {
+		if !isURLConcerned(requestURL, r.urls) {
+			continue
+		}
+
+		if !reverse && name == r.from {
+			return r.to
+		}
+
+		if reverse && name == r.to {
+			return r.from
+		}
+	}
+
+	return name
+}
+
+// rewriteSetCookie applies the rules on the attributes of a Set-Cookie header value,
+// the attributes that are not concerned by the rules are kept as is.
+func (f *Filter) rewriteSetCookie(requestURL string, originalPath string, cookie string) string {
+	rules := f.response.Cookies
+	if rules == nil {
+		// Only the reversed prefix rules are applied
+		rules = &cookieRules{}
+	}
+
+	attributes := strings.Split(cookie, ";")
+	result := make([]string, 0, len(attributes)+2)
+	secure := false
+
+	if name, value, ok := strings.Cut(strings.TrimSpace(attributes[0]), "="); ok {
+		attributes[0] = renameCookie(requestURL, name, rules.name, false) + "=" + value
+	}
+
+	result = append(result, attributes[0])
+
+	for _, attr := range attributes[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(attr), "=")
+
+		switch strings.ToLower(key) {
+		case "domain":
+			value = do(requestURL, value, rules.domain, false)
+			if value == "" {
+				continue
+			}
+
+			attr = " " + key + "=" + value
+		case "path":
+			value = f.reversePrefix(originalPath, value, func(p replaceParameters) bool {
+				return rules.reversePrefix || p.reverse
+			})
+
+			attr = " " + key + "=" + do(requestURL, value, rules.path, true)
+		case "secure":
+			if rules.secure == cookieStrip {
+				continue
+			}
+
+			secure = true
+		case "samesite":
+			if rules.sameSite != "" {
+				continue
+			}
+		}
+
+		result = append(result, attr)
+	}
+
+	if rules.secure == cookieAdd && !secure {
+		result = append(result, " Secure")
+	}
+
+	if rules.sameSite != "" && rules.sameSite != cookieStrip {
+		result = append(result, " SameSite="+cookieSameSite[rules.sameSite])
+	}
+
+	return strings.Join(result, ";")
+}
+
+// rewriteSetCookies applies the cookies rules on all the Set-Cookie headers of the response.
+func (f *Filter) rewriteSetCookies(log logrus.FieldLogger, r *http.Response, requestURL string) {
+	originalPath := originalRequestPath(r.Request)
+
+	for i, cookie := range r.Header["Set-Cookie"] {
+		rewritten := f.rewriteSetCookie(requestURL, originalPath, cookie)
+		if rewritten != cookie {
+			r.Header["Set-Cookie"][i] = rewritten
+			log.Debug(fmt.Sprintf("Rewrite cookie %s to %s", cookie, rewritten))
+		}
+	}
+}
+
+// rewriteCookieHeader gives back to the cookies sent by the client the name used by the upstream.
+func (f *Filter) rewriteCookieHeader(log logrus.FieldLogger, r *http.Request, requestURL string) {
+	for i, header := range r.Header["Cookie"] {
+		cookies := strings.Split(header, ";")
+
+		for j, cookie := range cookies {
+			name, value, ok := strings.Cut(strings.TrimSpace(cookie), "=")
+			if !ok {
+				continue
+			}
+
+			if renamed := renameCookie(requestURL, name, f.response.Cookies.name, true); renamed != name {
+				cookies[j] = strings.Replace(cookie, name+"=", renamed+"=", 1)
+				log.Debug(fmt.Sprintf("Rename cookie %s to %s with value %s", name, renamed, value))
+			}
+		}
+
+		r.Header["Cookie"][i] = strings.Join(cookies, ";")
+	}
+}
@@ -0,0 +1,219 @@
+package filter
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestFilter_rewriteSetCookie(t *testing.T) {
+	prefix := []replaceParameters{{from: "/dev/", to: "/"}}
+
+	tests := []struct {
+		name    string
+		url     string
+		cookies cookieRules
+		cookie  string
+		want    string
+	}{
+		{
+			"no rules",
+			"/login",
+			cookieRules{},
+			"SID=abc; Domain=legacy.internal; Path=/; HttpOnly",
+			"SID=abc; Domain=legacy.internal; Path=/; HttpOnly",
+		},
+		{
+			"domain and path",
+			"/login",
+			cookieRules{
+				domain: []replaceParameters{{from: "legacy.internal", to: "example.com"}},
+				path:   []replaceParameters{{from: "/app/", to: "/legacy/"}},
+			},
+			"SID=abc; domain=.legacy.internal; path=/app/session; HttpOnly",
+			"SID=abc; domain=.example.com; path=/legacy/session; HttpOnly",
+		},
+		{
+			"domain removed",
+			"/login",
+			cookieRules{domain: []replaceParameters{{from: "legacy.internal", to: ""}}},
+			"SID=abc; Domain=legacy.internal; Path=/",
+			"SID=abc; Path=/",
+		},
+		{
+			"reverse prefix",
+			"/login",
+			cookieRules{reversePrefix: true},
+			"SID=abc; Path=/",
+			"SID=abc; Path=/dev/",
+		},
+		{
+			"add secure and samesite",
+			"/login",
+			cookieRules{secure: cookieAdd, sameSite: "none"},
+			"SID=abc; Path=/; SameSite=Lax",
+			"SID=abc; Path=/; Secure; SameSite=None",
+		},
+		{
+			"secure already present",
+			"/login",
+			cookieRules{secure: cookieAdd},
+			"SID=abc; secure",
+			"SID=abc; secure",
+		},
+		{
+			"strip secure and samesite",
+			"/login",
+			cookieRules{secure: cookieStrip, sameSite: cookieStrip},
+			"SID=abc; Secure; SameSite=Strict; Max-Age=60",
+			"SID=abc; Max-Age=60",
+		},
+		{
+			"rename",
+			"/login",
+			cookieRules{name: []replaceParameters{{from: "JSESSIONID", to: "LEGACYSESSION"}}},
+			"JSESSIONID=abc=; Path=/",
+			"LEGACYSESSION=abc=; Path=/",
+		},
+		{
+			"other url",
+			"/login",
+			cookieRules{
+				name:   []replaceParameters{{from: "SID", to: "LSID", urls: []*regexp.Regexp{regexp.MustCompile("^/app")}}},
+				domain: []replaceParameters{{from: "legacy.internal", to: "example.com", urls: []*regexp.Regexp{regexp.MustCompile("^/app")}}},
+			},
+			"SID=abc; Domain=legacy.internal",
+			"SID=abc; Domain=legacy.internal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies := tt.cookies
+			f := &Filter{
+				prefix:   prefix,
+				response: response{Cookies: &cookies},
+				log:      logrus.New(),
+			}
+
+			if got := f.rewriteSetCookie(tt.url, "/dev/login", tt.cookie); got != tt.want {
+				t.Errorf("Filter.rewriteSetCookie() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_rewriteSetCookies(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	req, _ := http.NewRequest("GET", "http://legacy:8080/login", nil)
+	req = req.WithContext(context.WithValue(req.Context(), originalPathKey, "/dev/login"))
+
+	r := &http.Response{
+		Header: http.Header{
+			"Set-Cookie": []string{"SID=abc; Path=/", "LANG=fr; Path=/i18n"},
+		},
+		Request: req,
+	}
+
+	f := &Filter{
+		prefix:   []replaceParameters{{from: "/dev/", to: "/"}},
+		response: response{Cookies: &cookieRules{reversePrefix: true}},
+		log:      log,
+	}
+
+	f.rewriteSetCookies(log, r, "/login")
+
+	want := []string{"SID=abc; Path=/dev/", "LANG=fr; Path=/dev/i18n"}
+	if !reflect.DeepEqual(r.Header["Set-Cookie"], want) {
+		t.Errorf("Filter.rewriteSetCookies() = %v, want %v", r.Header["Set-Cookie"], want)
+	}
+
+	verifyLogged(
+		"Filter.rewriteSetCookies",
+		[]string{
+			"Rewrite cookie SID=abc; Path=/ to SID=abc; Path=/dev/",
+			"Rewrite cookie LANG=fr; Path=/i18n to LANG=fr; Path=/dev/i18n",
+		},
+		hook,
+		t,
+	)
+}
+
+func TestFilter_rewriteCookieHeader(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	req, _ := http.NewRequest("GET", "http://legacy:8080/login", nil)
+	req.Header["Cookie"] = []string{"LEGACYSESSION=abc; LANG=fr", "invalid; LEGACYSESSION=def"}
+
+	f := &Filter{
+		response: response{Cookies: &cookieRules{name: []replaceParameters{{from: "JSESSIONID", to: "LEGACYSESSION"}}}},
+		log:      log,
+	}
+
+	f.rewriteCookieHeader(log, req, "/login")
+
+	want := []string{"JSESSIONID=abc; LANG=fr", "invalid; JSESSIONID=def"}
+	if !reflect.DeepEqual(req.Header["Cookie"], want) {
+		t.Errorf("Filter.rewriteCookieHeader() = %v, want %v", req.Header["Cookie"], want)
+	}
+
+	verifyLogged(
+		"Filter.rewriteCookieHeader",
+		[]string{
+			"Rename cookie LEGACYSESSION to JSESSIONID with value abc",
+			"Rename cookie LEGACYSESSION to JSESSIONID with value def",
+		},
+		hook,
+		t,
+	)
+}
+
+func TestFilter_UpdateResponseCookies(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	req, _ := http.NewRequest("GET", "http://legacy:8080/login", nil)
+	r := http.Response{
+		Header: http.Header{
+			"Content-Type": []string{"image/png"},
+			"Set-Cookie":   []string{"SID=abc; Domain=legacy"},
+		},
+		StatusCode: http.StatusFound,
+		Request:    req,
+	}
+
+	f := &Filter{
+		response:     response{Cookies: &cookieRules{domain: []replaceParameters{{from: "legacy", to: ""}}}},
+		contentTypes: []string{"text/html"},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	if got := r.Header.Get("Set-Cookie"); got != "SID=abc" {
+		t.Errorf("Filter.UpdateResponse() Set-Cookie = %v, want %v", got, "SID=abc")
+	}
+}
+
+func TestFilter_rewriteSetCookieReversedPrefix(t *testing.T) {
+	f := &Filter{
+		prefix: []replaceParameters{{from: "/dev/", to: "/", reverse: true}, {from: "/prod/", to: "/"}},
+		log:    logrus.New(),
+	}
+
+	if got := f.rewriteSetCookie("/login", "/dev/login", "SID=abc; Path=/"); got != "SID=abc; Path=/dev/" {
+		t.Errorf("Filter.rewriteSetCookie() = %v, want %v", got, "SID=abc; Path=/dev/")
+	}
+
+	if got := f.rewriteSetCookie("/login", "/prod/login", "SID=abc; Path=/"); got != "SID=abc; Path=/" {
+		t.Errorf("Filter.rewriteSetCookie() = %v, want %v", got, "SID=abc; Path=/")
+	}
+}
@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// dictionary is a set of replacements loaded from a file and applied in a single pass.
+type dictionary struct {
+	file     string
+	size     int
+	replacer *multiReplacer
+	urls     []*regexp.Regexp
+	when     *conditions
+}
+
+// loadDictionary reads the replacement pairs of a CSV (from,to) or YAML/JSON (from: to) file.
+func loadDictionary(log logrus.FieldLogger, file string) map[string]string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalf("Cannot read dictionary: %v", err)
+
+		return nil
+	}
+
+	pairs := map[string]string{}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		r := csv.NewReader(bytes.NewReader(content))
+		r.FieldsPerRecord = 2
+		r.Comment = '#'
+
+		records, err := r.ReadAll()
+		if err != nil {
+			log.Fatalf("Cannot decode CSV dictionary %s: %v", file, err)
+		}
+
+		for _, record := range records {
+			if _, ok := pairs[record[0]]; ok {
+				log.Fatalf("Duplicate entry '%s' in dictionary %s", record[0], file)
+			}
+
+			pairs[record[0]] = record[1]
+		}
+	case ".yaml", ".yml", ".json":
+		if err := yaml.Unmarshal(content, &pairs); err != nil {
+			log.Fatalf("Cannot decode dictionary %s: %v", file, err)
+		}
+	default:
+		log.Fatalf("Unknown format of dictionary %s (csv, yaml or json)", file)
+	}
+
+	if _, ok := pairs[""]; ok {
+		log.Fatalf("Empty entry in dictionary %s", file)
+	}
+
+	return pairs
+}
+
+// applyDictionaries replaces the entries of the dictionaries concerning the url.
+func (f *Filter) applyDictionaries(requestURL string, dictionaries []dictionary, body string) string {
+	for _, d := range dictionaries {
+		if isURLConcerned(requestURL, d.urls) {
+			body = d.replacer.Replace(body)
+		}
+	}
+
+	return body
+}
+
+// hasDictionary returns true if at least one dictionary concerns the url.
+func hasDictionary(requestURL string, dictionaries []dictionary) bool {
+	for _, d := range dictionaries {
+		if isURLConcerned(requestURL, d.urls) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectDictionaries returns the dictionaries whose conditions are fulfilled.
+func selectDictionaries(dictionaries []dictionary, ctx *ruleContext) []dictionary {
+	selected := make([]dictionary, 0, len(dictionaries))
+
+	for _, d := range dictionaries {
+		if d.when.match(ctx) {
+			selected = append(selected, d)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_loadDictionary(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		expectFatal bool
+		want        map[string]string
+	}{
+		{
+			"csv",
+			"testdata/dictionary/urls.csv",
+			false,
+			map[string]string{
+				"http://old.example.com/shop": "https://shop.example.com",
+				"http://old.example.com":      "https://www.example.com",
+				"Acme, Inc.":                  "Acme Corp",
+			},
+		},
+		{"yaml", "testdata/dictionary/words.yaml", false, map[string]string{"colour": "color", "colours": "colors"}},
+		{"json", "testdata/dictionary/words.json", false, map[string]string{"foo": "bar"}},
+		{"missing", "testdata/dictionary/missing.csv", true, nil},
+		{"duplicate csv", "testdata/dictionary/duplicate.csv", true, nil},
+		{"duplicate yaml", "testdata/dictionary/duplicate.yaml", true, nil},
+		{"invalid csv", "testdata/dictionary/invalid.csv", true, nil},
+		{"empty entry", "testdata/dictionary/empty.csv", true, nil},
+		{"unknown format", "testdata/dictionary/words.txt", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := loadDictionary(log, tt.file)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("loadDictionary() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadDictionary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDictionaryConfig(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+	prefix := []replaceParameters{{from: "/", to: "/dev/", urls: []*regexp.Regexp{}}}
+
+	got := parseDictionaryConfig(log, []Cdictionary{
+		{File: "testdata/dictionary/words.yaml", Urls: []string{"/blog/"}, When: &Cconditions{Methods: []string{"GET"}}},
+	}, prefix)
+
+	if len(got) != 1 {
+		t.Fatalf("parseDictionaryConfig() = %#v", got)
+	}
+
+	if got[0].file != "testdata/dictionary/words.yaml" || got[0].size != 2 {
+		t.Errorf("parseDictionaryConfig() file = %s, size = %d", got[0].file, got[0].size)
+	}
+
+	if !reflect.DeepEqual(got[0].urls, []*regexp.Regexp{regexp.MustCompile("^/dev/blog/")}) {
+		t.Errorf("parseDictionaryConfig() urls = %v", got[0].urls)
+	}
+
+	if !reflect.DeepEqual(got[0].when, &conditions{methods: []string{"GET"}}) {
+		t.Errorf("parseDictionaryConfig() when = %v", got[0].when)
+	}
+
+	if r := got[0].replacer.Replace("colours and colour"); r != "colors and color" {
+		t.Errorf("parseDictionaryConfig() replacer = %s", r)
+	}
+}
+
+func TestFilter_UpdateResponseDictionary(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/blog/1", nil)
+	r := http.Response{
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		StatusCode: http.StatusOK,
+		Request:    req,
+		Body:       io.NopCloser(bytes.NewBufferString("The colour of the colours")),
+	}
+
+	f := &Filter{
+		stream: true,
+		response: response{
+			Replace: []replaceParameters{{from: "The", to: "A"}},
+			Dictionary: []dictionary{
+				{replacer: newMultiReplacer(map[string]string{"colour": "color", "colours": "colors"})},
+				{replacer: newMultiReplacer(map[string]string{"color": "hue"}), urls: []*regexp.Regexp{regexp.MustCompile("^/shop/")}},
+			},
+		},
+		contentTypes: []string{"text/html"},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	got, _ := io.ReadAll(r.Body)
+	if string(got) != "A color of the colors" {
+		t.Errorf("Filter.UpdateResponse() = %s, want %s", got, "A color of the colors")
+	}
+
+	if r.Header.Get("Content-Length") != "21" {
+		t.Errorf("Filter.UpdateResponse() Content-Length = %s, the body must not be streamed", r.Header.Get("Content-Length"))
+	}
+}
+
+func Test_resolvePaths(t *testing.T) {
+	c := Config{
+		Request: Caction{
+			Dictionary: []Cdictionary{{File: "request.csv"}},
+			Transform:  []Ctransform{{Command: "bin/fix.sh"}, {Command: "jq"}, {Command: "/usr/bin/tidy"}},
+		},
+		Response: Caction{Dictionary: []Cdictionary{
+			{File: "dictionaries/urls.csv"},
+			{File: "/etc/villip/words.yaml"},
+		}, Inject: []Cinject{{File: "banner.html"}, {Content: "<div></div>"}}},
+	}
+
+	resolvePaths(&c, "/conf")
+
+	want := []string{
+		"/conf/request.csv",
+		"/conf/dictionaries/urls.csv",
+		"/etc/villip/words.yaml",
+		"/conf/banner.html",
+		"",
+		"/conf/bin/fix.sh",
+		"jq",
+		"/usr/bin/tidy",
+	}
+	got := []string{
+		c.Request.Dictionary[0].File,
+		c.Response.Dictionary[0].File,
+		c.Response.Dictionary[1].File,
+		c.Response.Inject[0].File,
+		c.Response.Inject[1].File,
+		c.Request.Transform[0].Command,
+		c.Request.Transform[1].Command,
+		c.Request.Transform[2].Command,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolvePaths() = %v, want %v", got, want)
+	}
+}
@@ -86,6 +86,21 @@ func (f *Filter) dumpToLog(fileType string, requestID string, url string, header
 	return requestID
 }
 
+// isDumpURL returns true if the messages of the url must be dumped.
+func (f *Filter) isDumpURL(url string) bool {
+	if len(f.dumpURLs) == 0 {
+		return true
+	}
+
+	for _, reg := range f.dumpURLs {
+		if reg.MatchString(url) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (f *Filter) dumpHTTPMessage(
 	requestID string,
 	requestIDFromRequest string,
@@ -118,20 +133,8 @@ func (f *Filter) dumpHTTPMessage(
 		fileType = "original" + httpMessageType
 	}
 
-	if len(f.dumpURLs) != 0 {
-		found := false
-
-		for _, reg := range f.dumpURLs {
-			if reg.MatchString(url) {
-				found = true
-
-				break
-			}
-		}
-
-		if !found {
-			return requestID
-		}
+	if !f.isDumpURL(url) {
+		return requestID
 	}
 
 	if f.dumpFolder != "" {
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-Encoding values that villip is able to decode and re-encode.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	encodingBrotli  = "br"
+	encodingZstd    = "zstd"
+)
+
+// contentEncoding returns the normalized Content-Encoding of a message.
+func contentEncoding(encoding string) string {
+	return strings.ToLower(strings.TrimSpace(encoding))
+}
+
+// isKnownEncoding returns true if the body with this encoding can be filtered.
+func isKnownEncoding(encoding string) bool {
+	switch contentEncoding(encoding) {
+	case "", "identity", encodingGzip, encodingDeflate, encodingBrotli, encodingZstd:
+		return true
+	}
+
+	return false
+}
+
+// newDecoder returns a reader providing the decompressed body, the body is returned as is
+// if the encoding is not a compression.
+func newDecoder(encoding string, r io.Reader) (io.Reader, error) {
+	switch contentEncoding(encoding) {
+	case encodingGzip:
+		return gzip.NewReader(r)
+	case encodingDeflate:
+		// RFC 9110 deflate is zlib wrapped but some servers send raw deflate
+		br := bufio.NewReader(r)
+
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, fmt.Errorf("deflate: %w", err)
+		}
+
+		if (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(br)
+		}
+
+		return flate.NewReader(br), nil
+	case encodingBrotli:
+		return brotli.NewReader(r), nil
+	case encodingZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.IOReadCloser(), nil
+	default:
+		return r, nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newEncoder returns a writer compressing to w with the given encoding, closing it
+// flushes the compressed stream but does not close w.
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch contentEncoding(encoding) {
+	case encodingGzip:
+		return gzip.NewWriter(w), nil
+	case encodingDeflate:
+		return zlib.NewWriter(w), nil
+	case encodingBrotli:
+		return brotli.NewWriter(w), nil
+	case encodingZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
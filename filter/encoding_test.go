@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+)
+
+func encodeString(t *testing.T, encoding string, s string) []byte {
+	var b bytes.Buffer
+
+	w, err := newEncoder(encoding, &b)
+	if err != nil {
+		t.Fatalf("newEncoder(%s) error = %v", encoding, err)
+	}
+
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("newEncoder(%s) write error = %v", encoding, err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("newEncoder(%s) close error = %v", encoding, err)
+	}
+
+	return b.Bytes()
+}
+
+func Test_isKnownEncoding(t *testing.T) {
+	tests := []struct {
+		encoding string
+		want     bool
+	}{
+		{"", true},
+		{"identity", true},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate", true},
+		{"br", true},
+		{"zstd", true},
+		{"compress", false},
+		{"gzip, br", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			if got := isKnownEncoding(tt.encoding); got != tt.want {
+				t.Errorf("isKnownEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_encodingRoundTrip(t *testing.T) {
+	content := "take your book,\ntry to dance\n sing often"
+
+	for _, encoding := range []string{"", "identity", "gzip", "deflate", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			encoded := encodeString(t, encoding, content)
+
+			if (encoding == "" || encoding == "identity") != (string(encoded) == content) {
+				t.Errorf("newEncoder(%s) = %#v", encoding, string(encoded))
+			}
+
+			r, err := newDecoder(encoding, bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("newDecoder(%s) error = %v", encoding, err)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("newDecoder(%s) read error = %v", encoding, err)
+			}
+
+			if string(got) != content {
+				t.Errorf("newDecoder(%s) = %#v, want %#v", encoding, string(got), content)
+			}
+		})
+	}
+}
+
+func Test_newDecoderRawDeflate(t *testing.T) {
+	var b bytes.Buffer
+
+	w, _ := flate.NewWriter(&b, flate.DefaultCompression)
+	_, _ = w.Write([]byte("take your book"))
+	_ = w.Close()
+
+	r, err := newDecoder("deflate", &b)
+	if err != nil {
+		t.Fatalf("newDecoder() error = %v", err)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("newDecoder() read error = %v", err)
+	}
+
+	if string(got) != "take your book" {
+		t.Errorf("newDecoder() = %#v, want %#v", string(got), "take your book")
+	}
+}
+
+func Test_newDecoderError(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			if _, err := newDecoder(encoding, bytes.NewReader([]byte{})); err == nil {
+				t.Errorf("newDecoder(%s) expected an error on empty body", encoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+
+	"golang.org/x/text/transform"
+)
+
+// Content types of the responses sent progressively by the upstream, filtered line by line.
+var lineStreamTypes = map[string]bool{ //nolint: gochecknoglobals
+	"application/x-ndjson":      true,
+	"application/jsonl":         true,
+	"application/stream+json":   true,
+	"application/json-seq":      true,
+	"application/x-json-stream": true,
+}
+
+const eventStreamType = "text/event-stream"
+
+// streamMode returns how a response of this content type is sent progressively by the upstream: "event" for the
+// Server-Sent Events, "line" for the line delimited streams and "" for the other responses.
+func streamMode(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case mediaType == eventStreamType:
+		return "event"
+	case lineStreamTypes[mediaType]:
+		return "line"
+	}
+
+	return ""
+}
+
+// eventReader applies a transformation on each event (or line) of a stream as soon as it is complete, so the
+// reverse proxy can send it to the client without waiting for the end of the body.
+type eventReader struct {
+	src       *bufio.Reader
+	events    bool // The units are the events separated by an empty line instead of the lines
+	transform func(string) string
+	out       bytes.Buffer
+	err       error
+}
+
+func newEventReader(src io.Reader, events bool, transform func(string) string) *eventReader {
+	return &eventReader{src: bufio.NewReader(src), events: events, transform: transform}
+}
+
+func (er *eventReader) Read(p []byte) (int, error) {
+	for er.out.Len() == 0 {
+		if er.err != nil {
+			return 0, er.err
+		}
+
+		unit, err := er.next()
+		if err != nil {
+			er.err = err
+		}
+
+		if unit != "" {
+			er.out.WriteString(er.transform(unit))
+		}
+	}
+
+	return er.out.Read(p)
+}
+
+// next returns the next complete unit with its terminating newlines.
+func (er *eventReader) next() (string, error) {
+	var unit string
+
+	for {
+		line, err := er.src.ReadString('\n')
+		unit += line
+
+		if err != nil || !er.events || line == "\n" || line == "\r\n" {
+			return unit, err
+		}
+	}
+}
+
+// ignoredStreamRules returns the kinds of the rules concerning the url which are not applied on the events, they
+// need the whole body.
+func ignoredStreamRules(requestURL string, rules bodyRules) []string {
+	ignored := []string{}
+
+	if hasReplaceRule(requestURL, rules.html) || rules.links != nil {
+		ignored = append(ignored, "html")
+	}
+
+	if hasJSONRule(requestURL, rules.json) {
+		ignored = append(ignored, "json")
+	}
+
+	if hasInjection(requestURL, rules.inject) {
+		ignored = append(ignored, "inject")
+	}
+
+	if hasCommand(requestURL, rules.commands) {
+		ignored = append(ignored, "transform")
+	}
+
+	return ignored
+}
+
+// streamEvents filters the body of a streamed response unit by unit, the body is sent uncompressed to be
+// flushed after each unit.
+func (f *Filter) streamEvents(
+	requestURL string,
+	rules bodyRules,
+	mode string,
+	bod io.ReadCloser,
+	parsedHeader http.Header,
+) (io.ReadCloser, error) {
+	closers := []io.Closer{bod}
+	encoding := parsedHeader.Get("Content-Encoding")
+
+	if !isKnownEncoding(encoding) {
+		f.log.WithField("encoding", encoding).Debug("Unsupported content encoding, body not filtered")
+
+		return bod, nil
+	}
+
+	decoded, err := newDecoder(encoding, bod)
+	if err != nil {
+		f.log.Errorf("Impossible to decompress: %v", err)
+
+		return nil, err
+	}
+
+	if c, ok := decoded.(io.Closer); ok && decoded != io.Reader(bod) {
+		closers = append([]io.Closer{c}, closers...)
+	}
+
+	parsedHeader.Del("Content-Encoding")
+
+	log := f.log.WithField("requestURL", requestURL)
+	log.Debug("Filtering the stream by " + mode)
+
+	if (f.dumpFolder != "" || len(f.dumpURLs) != 0) && f.isDumpURL(requestURL) {
+		log.Warn("The streamed responses are not dumped")
+	}
+
+	// The charset is only declared by the header, waiting for the beginning of the body would delay the first event
+	contentType := parsedHeader.Get("Content-Type")
+	enc, charsetName := bodyCharset(contentType, nil)
+
+	var body io.Reader = decoded
+
+	if enc != nil {
+		log.WithField("charset", charsetName).Debug("Converting stream to UTF-8")
+
+		body = transform.NewReader(body, enc.NewDecoder())
+	}
+
+	var reader io.Reader = newEventReader(body, mode == "event", func(unit string) string {
+		modified := _do(requestURL, unit, rules.replace, false)
+		modified = f.applyDictionaries(requestURL, rules.dictionaries, modified)
+
+		return _do(requestURL, modified, rules.rebase, false)
+	})
+
+	if enc != nil {
+		reader = transform.NewReader(reader, charsetEncoder(enc, contentType))
+	}
+
+	return &streamBody{Reader: reader, closers: closers}, nil
+}
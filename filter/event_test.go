@@ -0,0 +1,265 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_streamMode(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"text/event-stream", "event"},
+		{"text/event-stream; charset=utf-8", "event"},
+		{"application/x-ndjson", "line"},
+		{"application/stream+json", "line"},
+		{"application/json", ""},
+		{"text/html", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := streamMode(tt.contentType); got != tt.want {
+				t.Errorf("streamMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_eventReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		events bool
+		body   string
+		want   []string
+	}{
+		{
+			"events",
+			true,
+			"event: a\ndata: 1\n\ndata: 2\r\n\r\n: comment\n\ndata: last",
+			[]string{"event: a\ndata: 1\n\n", "data: 2\r\n\r\n", ": comment\n\n", "data: last"},
+		},
+		{
+			"lines",
+			false,
+			"{\"a\":1}\n{\"a\":2}\n",
+			[]string{"{\"a\":1}\n", "{\"a\":2}\n"},
+		},
+		{
+			"empty",
+			true,
+			"",
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units := []string{}
+			r := newEventReader(strings.NewReader(tt.body), tt.events, func(unit string) string {
+				units = append(units, unit)
+
+				return strings.ToUpper(unit)
+			})
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("eventReader.Read() error = %v", err)
+			}
+
+			if string(got) != strings.ToUpper(tt.body) {
+				t.Errorf("eventReader.Read() = %q, want %q", got, strings.ToUpper(tt.body))
+			}
+
+			if strings.Join(units, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("eventReader units = %q, want %q", units, tt.want)
+			}
+		})
+	}
+}
+
+func Test_eventReaderDoesNotWait(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := newEventReader(pr, true, strings.ToUpper)
+
+	go func() {
+		_, _ = pw.Write([]byte("data: first\n"))
+		_, _ = pw.Write([]byte("\n"))
+		// The stream stays open
+	}()
+
+	buf := make([]byte, 100)
+
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "DATA: FIRST\n\n" {
+		t.Errorf("eventReader.Read() = %q, %v, want the first event", buf[:n], err)
+	}
+}
+
+func Test_ignoredStreamRules(t *testing.T) {
+	rules := bodyRules{
+		replace: []replaceParameters{{from: "a", to: "b"}},
+		json:    []jsonParameters{{source: "$.a"}},
+		inject:  []injection{{position: injectBodyEnd, content: "x", urls: []*regexp.Regexp{regexp.MustCompile("^/app/")}}},
+	}
+
+	if got := ignoredStreamRules("/events", rules); !reflect.DeepEqual(got, []string{"json"}) {
+		t.Errorf("ignoredStreamRules() = %v, want [json]", got)
+	}
+}
+
+func TestFilter_UpdateResponseEventStream(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	var compressed bytes.Buffer
+
+	w := gzip.NewWriter(&compressed)
+	_, _ = w.Write([]byte("data: http://backend:8080/item/1\n\ndata: legacy-api\n\n"))
+	_ = w.Close()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/events", nil)
+	r := http.Response{
+		Header: http.Header{
+			"Content-Type":     []string{"text/event-stream"},
+			"Content-Encoding": []string{"gzip"},
+			"Content-Length":   []string{"123"},
+			"Location":         []string{"http://backend:8080/events/2"},
+		},
+		StatusCode:    http.StatusOK,
+		Request:       req,
+		ContentLength: 123,
+		Body:          io.NopCloser(&compressed),
+	}
+
+	f := &Filter{
+		response: response{Replace: []replaceParameters{
+			{from: "http://backend:8080", to: "https://public"},
+			{from: `legacy-(\w+)`, to: "new-$1", regex: regexp.MustCompile(`legacy-(\w+)`)},
+		}},
+		contentTypes: []string{"text/event-stream"},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	got, _ := io.ReadAll(r.Body)
+	want := "data: https://public/item/1\n\ndata: new-api\n\n"
+
+	if string(got) != want {
+		t.Errorf("Filter.UpdateResponse() = %q, want %q", got, want)
+	}
+
+	if r.Header.Get("Location") != "https://public/events/2" {
+		t.Errorf("Filter.UpdateResponse() location = %s", r.Header.Get("Location"))
+	}
+
+	if r.ContentLength != -1 || r.Header.Get("Content-Length") != "" || r.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Filter.UpdateResponse() length = %d, headers = %v", r.ContentLength, r.Header)
+	}
+}
+
+func TestFilter_UpdateResponseEventStreamCharset(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/events", nil)
+	r := http.Response{
+		Header:     http.Header{"Content-Type": []string{"text/event-stream; charset=iso-8859-1"}},
+		StatusCode: http.StatusOK,
+		Request:    req,
+		// café in ISO-8859-1
+		Body: io.NopCloser(bytes.NewReader([]byte("data: caf\xe9\n\n"))),
+	}
+
+	f := &Filter{
+		response:     response{Replace: []replaceParameters{{from: "café", to: "thé"}}},
+		contentTypes: []string{"text/event-stream"},
+		dumpURLs:     []*regexp.Regexp{regexp.MustCompile("^/events")},
+		url:          "http://localhost:8081",
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	got, _ := io.ReadAll(r.Body)
+	if want := "data: th\xe9\n\n"; string(got) != want {
+		t.Errorf("Filter.UpdateResponse() = %q, want %q", got, want)
+	}
+
+	if !HadErrorLevel(hook, logrus.WarnLevel) {
+		t.Errorf("Filter.UpdateResponse() did not warn that the stream is not dumped")
+	}
+}
+
+func TestFilter_ServeEventStream(t *testing.T) {
+	release := make(chan struct{})
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: book 1\n\n"))
+		w.(http.Flusher).Flush()
+		// The second event is sent only when the client has received the first one
+		<-release
+		_, _ = w.Write([]byte("data: book 2\n\n"))
+	}))
+	defer backend.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		contentTypes: []string{"text/event-stream"},
+		response:     response{Replace: []replaceParameters{{from: "book", to: "smartphone"}}},
+		url:          backend.URL,
+		log:          log,
+	}
+
+	front := httptest.NewServer(http.HandlerFunc(f.Serve))
+	defer front.Close()
+
+	res, err := http.Get(front.URL)
+	if err != nil {
+		t.Fatalf("Filter.Serve() error = %v", err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	first := make(chan string)
+
+	go func() {
+		line, _ := reader.ReadString('\n')
+		first <- line
+	}()
+
+	select {
+	case line := <-first:
+		if line != "data: smartphone 1\n" {
+			t.Errorf("Filter.Serve() first event = %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Filter.Serve() the first event has not been flushed")
+	}
+
+	close(release)
+
+	rest, _ := io.ReadAll(reader)
+	if string(rest) != "\ndata: smartphone 2\n\n" {
+		t.Errorf("Filter.Serve() rest = %q", rest)
+	}
+}
@@ -1,16 +1,39 @@
 package filter
 
 import (
+	"context"
 	"net"
+	"net/http"
+	"net/http/httputil"
 	"regexp"
+	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type replaceParameters struct {
-	from string
-	to   string
-	urls []*regexp.Regexp
+	from  string
+	to    string
+	urls  []*regexp.Regexp
+	regex *regexp.Regexp // Compiled from when the rule is a regular expression
+	// Prefix rules only, apply the inverse mapping on the response headers and links
+	reverse      bool
+	reverseLinks bool
+	when         *conditions
+}
+
+// bodyRules groups the rules applied on a body.
+type bodyRules struct {
+	replace []replaceParameters
+	json    []jsonParameters
+	html    []replaceParameters
+	links   func(string) string // Reverse prefix mapping of the links, nil if there is none for the request
+	rebase  []replaceParameters // Automatic rebasing applied after all the other rules
+	// Dictionaries applied after the replacements
+	dictionaries []dictionary
+	inject       []injection // Contents inserted in the HTML pages after all the other rules
+	commands     []command   // External commands applied before the rebasing
 }
 
 type headerAction int
@@ -36,24 +59,55 @@ type headerConditions struct {
 	action headerAction
 }
 
+// headerRule is a header rule with its regular expressions compiled.
+type headerRule struct {
+	name   string
+	value  string
+	force  bool
+	add    bool
+	uuid   bool
+	from   string
+	to     string
+	regex  *regexp.Regexp // Compiled from when the rule is a regular expression
+	delete bool
+	rename string
+	urls   []*regexp.Regexp
+	when   *conditions
+}
+
 type response struct {
-	Replace []replaceParameters `yaml:"replace" json:"replace"`
-	Header  []Cheader           `yaml:"header" json:"header"`
+	Replace    []replaceParameters `yaml:"replace" json:"replace"`
+	Header     []headerRule        `yaml:"header" json:"header"`
+	JSON       []jsonParameters    `yaml:"json" json:"json"`
+	HTML       []replaceParameters `yaml:"html" json:"html"`
+	Cookies    *cookieRules        `yaml:"cookies" json:"cookies"`
+	Dictionary []dictionary        `yaml:"dictionary" json:"dictionary"`
+	Inject     []injection         `yaml:"inject" json:"inject"`
+	Transform  []command           `yaml:"transform" json:"transform"`
+	// Registered transformers called after the other rules
+	Transformers []namedTransformer `yaml:"transformers" json:"transformers"`
 }
 
 type request struct {
-	Replace []replaceParameters `yaml:"replace" json:"replace"`
-	Header  []Cheader           `yaml:"header" json:"header"`
+	Replace    []replaceParameters `yaml:"replace" json:"replace"`
+	Header     []headerRule        `yaml:"header" json:"header"`
+	JSON       []jsonParameters    `yaml:"json" json:"json"`
+	Dictionary []dictionary        `yaml:"dictionary" json:"dictionary"`
+	Transform  []command           `yaml:"transform" json:"transform"`
+	// Registered transformers called after the other rules
+	Transformers []namedTransformer `yaml:"transformers" json:"transformers"`
 }
 
 // Filter proxifies an URL and filter the response.
 type Filter struct {
 	insecure     bool
 	force        bool
+	stream       bool
 	response     response
 	request      request
 	contentTypes []string
 	status       []int
+	route        *route // nil if the filter receives all the requests of its port
 	restricted   []*net.IPNet
 	token        map[string][]headerConditions
 	url          string
@@ -64,6 +118,22 @@ type Filter struct {
 	dumpFolder   string
 	dumpURLs     []*regexp.Regexp
 	kind         Type
+	templates    map[string]*template.Template
+	autoRebase   bool
+	publicURL    string
+	// Period of the flushes of the response to the client, negative to flush after each write
+	flushInterval time.Duration
+	websocket     string // Proxying mode of the WebSocket connections, passthrough if empty
+	rewrite       []rewriteRule
+	upstreams     *upstreamPool      // nil if the filter has a single upstream without health checks
+	stopChecks    context.CancelFunc // Stops the health checks of the upstreams, nil if they are not running
+	// Proxy and connections to the upstreams shared by the requests, nil for the filters not created from a configuration
+	proxy     *httputil.ReverseProxy
+	transport *http.Transport
+	timeout   time.Duration              // Total duration of a request to the upstream, no limit if 0
+	retry     *retryPolicy               // nil if the requests are not retried
+	circuit   *circuitConfig             // nil if the filter has no circuit breaker
+	breakers  map[string]*circuitBreaker // Circuit breaker of each upstream URL
 }
 
 // Kind returns the type of proxy.
@@ -0,0 +1,300 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	cssURL     = regexp.MustCompile(`(url\(\s*['"]?)([^'")]*)(['"]?\s*\))`)                   //nolint: gochecknoglobals
+	refreshURL = regexp.MustCompile(`(?i)^(\s*[\d.]*\s*[;,]\s*url\s*=\s*['"]?)([^'"]*)(.*)$`) //nolint: gochecknoglobals
+	srcsetURL  = regexp.MustCompile(`(^|,)(\s*)([^\s,]+)`)                                    //nolint: gochecknoglobals
+)
+
+// Attributes containing an URL.
+var htmlURLAttributes = map[string]bool{"href": true, "src": true, "action": true} //nolint: gochecknoglobals
+
+// rewriteCSS rewrites the URLs of the url() functions of a CSS text.
+func rewriteCSS(css string, rewrite func(string) string) string {
+	return cssURL.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssURL.FindStringSubmatch(m)
+
+		return parts[1] + rewrite(parts[2]) + parts[3]
+	})
+}
+
+// rewriteSrcset rewrites the URLs of the image candidates of a srcset attribute, the separators and the descriptors
+// are kept as is.
+func rewriteSrcset(srcset string, rewrite func(string) string) string {
+	return srcsetURL.ReplaceAllStringFunc(srcset, func(m string) string {
+		parts := srcsetURL.FindStringSubmatch(m)
+
+		return parts[1] + parts[2] + rewrite(parts[3])
+	})
+}
+
+// rewriteAttributes rewrites the URL attributes of a tag and returns true if one of them has been modified.
+func rewriteAttributes(token *html.Token, rewrite func(string) string) bool {
+	modified := false
+	refresh := false
+
+	if token.Data == "meta" {
+		for _, attr := range token.Attr {
+			if strings.EqualFold(attr.Key, "http-equiv") && strings.EqualFold(strings.TrimSpace(attr.Val), "refresh") {
+				refresh = true
+			}
+		}
+	}
+
+	for i, attr := range token.Attr {
+		value := attr.Val
+
+		switch {
+		case htmlURLAttributes[attr.Key]:
+			value = rewrite(value)
+		case attr.Key == "srcset":
+			value = rewriteSrcset(value, rewrite)
+		case attr.Key == "style":
+			value = rewriteCSS(value, rewrite)
+		case attr.Key == "content" && refresh:
+			if parts := refreshURL.FindStringSubmatch(value); parts != nil {
+				value = parts[1] + rewrite(parts[2]) + parts[3]
+			}
+		}
+
+		if value != attr.Val {
+			token.Attr[i].Val = value
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// tagAttribute is the position of the value of an attribute in a raw tag.
+type tagAttribute struct {
+	key   string
+	start int
+	end   int
+	quote byte // 0 for an unquoted value
+	bare  bool // The attribute has no value, start is the end of its name
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f'
+}
+
+// scanTagAttributes returns the positions of the attribute values of a raw start tag, read like the HTML tokenizer.
+func scanTagAttributes(raw []byte) []tagAttribute {
+	attrs := []tagAttribute{}
+	n := len(raw)
+	i := 1
+
+	skipSpaces := func() {
+		for i < n && isHTMLSpace(raw[i]) {
+			i++
+		}
+	}
+
+	for i < n && !isHTMLSpace(raw[i]) && raw[i] != '/' && raw[i] != '>' {
+		i++
+	}
+
+	skipSpaces()
+
+	for i < n && raw[i] != '>' {
+		keyStart := i
+
+		for ; i < n; i++ {
+			c := raw[i]
+			if c == '=' && i == keyStart {
+				// An equals sign starting the name is part of it
+				continue
+			}
+
+			if c == '=' || c == '/' || c == '>' || isHTMLSpace(c) {
+				break
+			}
+		}
+
+		attr := tagAttribute{key: strings.ToLower(string(raw[keyStart:i])), start: i, end: i, bare: true}
+
+		skipSpaces()
+
+		switch {
+		case i >= n:
+		case raw[i] == '/':
+			i++
+		case raw[i] == '=':
+			i++
+			skipSpaces()
+
+			attr.bare = false
+			attr.start, attr.end = i, i
+
+			if i < n && (raw[i] == '"' || raw[i] == '\'') {
+				attr.quote = raw[i]
+				i++
+				attr.start = i
+
+				for i < n && raw[i] != attr.quote {
+					i++
+				}
+
+				attr.end = i
+
+				if i < n {
+					i++
+				}
+			} else {
+				for i < n && raw[i] != '>' && !isHTMLSpace(raw[i]) {
+					i++
+				}
+
+				attr.end = i
+			}
+		}
+
+		if attr.key != "" {
+			attrs = append(attrs, attr)
+		}
+
+		skipSpaces()
+	}
+
+	return attrs
+}
+
+// escapeAttribute escapes the value of an attribute for its quoting, an unquoted value is quoted if needed.
+func escapeAttribute(value string, quote byte) string {
+	value = strings.ReplaceAll(value, "&", "&amp;")
+
+	switch quote {
+	case '"':
+		return strings.ReplaceAll(value, `"`, "&quot;")
+	case '\'':
+		return strings.ReplaceAll(value, "'", "&#39;")
+	}
+
+	if value == "" || strings.ContainsAny(value, " \t\n\r\f\"'=<>`") {
+		return `"` + strings.ReplaceAll(value, `"`, "&quot;") + `"`
+	}
+
+	return value
+}
+
+// replaceAttributes returns the raw tag with only the values of the rewritten attributes replaced, the rest of the
+// tag (case, quoting, entities) is kept as is.
+func replaceAttributes(raw []byte, token html.Token, original []html.Attribute) []byte {
+	attrs := scanTagAttributes(raw)
+	if len(attrs) != len(token.Attr) {
+		return []byte(token.String())
+	}
+
+	var out bytes.Buffer
+
+	last := 0
+
+	for i, attr := range attrs {
+		if attr.key != token.Attr[i].Key {
+			return []byte(token.String())
+		}
+
+		if token.Attr[i].Val == original[i].Val {
+			continue
+		}
+
+		out.Write(raw[last:attr.start])
+
+		if attr.bare {
+			out.WriteByte('=')
+		}
+
+		out.WriteString(escapeAttribute(token.Attr[i].Val, attr.quote))
+		last = attr.end
+	}
+
+	out.Write(raw[last:])
+
+	return out.Bytes()
+}
+
+// rewriteHTML applies the html rules and the reverse prefix rules only on the URLs of an HTML document (links, sources,
+// forms, srcset, meta refresh and CSS url()), all the other parts of the document are kept as is.
+func (f *Filter) rewriteHTML(requestURL string, rules bodyRules, body string, contentType string) string {
+	if !isHTML(contentType) || (!hasReplaceRule(requestURL, rules.html) && rules.links == nil) {
+		return body
+	}
+
+	rewrite := func(link string) string {
+		if rules.links != nil {
+			link = rules.links(link)
+		}
+
+		return do(requestURL, link, rules.html, false)
+	}
+
+	var out bytes.Buffer
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	inStyle := false
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				f.log.Debugf("HTML parsing stopped, URLs not rewritten after this point: %v", z.Err())
+				out.Write(z.Raw())
+			}
+
+			break
+		}
+
+		// Raw content may be changed by the call to Token
+		raw := append([]byte{}, z.Raw()...)
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			inStyle = tt == html.StartTagToken && token.Data == "style"
+			original := append([]html.Attribute{}, token.Attr...)
+
+			if rewriteAttributes(&token, rewrite) {
+				out.Write(replaceAttributes(raw, token, original))
+
+				continue
+			}
+		case html.TextToken:
+			if inStyle {
+				out.WriteString(rewriteCSS(string(raw), rewrite))
+
+				continue
+			}
+		case html.EndTagToken:
+			inStyle = false
+		case html.CommentToken, html.DoctypeToken:
+		}
+
+		out.Write(raw)
+	}
+
+	f.log.Debug(fmt.Sprintf("Body after the HTML rewriting : %s", out.String()))
+
+	return out.String()
+}
+
+// hasReplaceRule returns true if at least one rule concerns the url.
+func hasReplaceRule(requestURL string, rules []replaceParameters) bool {
+	for _, r := range rules {
+		if isURLConcerned(requestURL, r.urls) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"golang.org/x/net/html"
+)
+
+func TestFilter_rewriteHTML(t *testing.T) {
+	rules := []replaceParameters{{from: "http://legacy:8080/", to: "/app/"}}
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		rules       []replaceParameters
+		body        string
+		want        string
+	}{
+		{
+			"links only",
+			"/",
+			"text/html",
+			rules,
+			`<p>Go to http://legacy:8080/ or <a href="http://legacy:8080/home" class=x>home</a></p>`,
+			`<p>Go to http://legacy:8080/ or <a href="/app/home" class=x>home</a></p>`,
+		},
+		{
+			"script untouched",
+			"/",
+			"text/html",
+			rules,
+			`<script src="http://legacy:8080/a.js">var u = "http://legacy:8080/api";</script>`,
+			`<script src="/app/a.js">var u = "http://legacy:8080/api";</script>`,
+		},
+		{
+			"form base and self closing",
+			"/",
+			"text/html; charset=utf-8",
+			rules,
+			`<BASE HREF="http://legacy:8080/"><form action="http://legacy:8080/post"></form><img src="http://legacy:8080/i.png"/>`,
+			`<BASE HREF="/app/"><form action="/app/post"></form><img src="/app/i.png"/>`,
+		},
+		{
+			"srcset",
+			"/",
+			"text/html",
+			rules,
+			`<img srcset="http://legacy:8080/a.png 1x,http://legacy:8080/b.png 2x">`,
+			`<img srcset="/app/a.png 1x,/app/b.png 2x">`,
+		},
+		{
+			"meta refresh",
+			"/",
+			"text/html",
+			rules,
+			`<meta http-equiv="Refresh" content="5; URL='http://legacy:8080/next'"><meta name="description" content="http://legacy:8080/">`,
+			`<meta http-equiv="Refresh" content="5; URL='/app/next'"><meta name="description" content="http://legacy:8080/">`,
+		},
+		{
+			"css",
+			"/",
+			"text/html",
+			rules,
+			`<div style="background: url('http://legacy:8080/bg.png')">http://legacy:8080/</div><style>body { background: url(http://legacy:8080/b.png) }</style>`,
+			`<div style="background: url('/app/bg.png')">http://legacy:8080/</div><style>body { background: url(/app/b.png) }</style>`,
+		},
+		{
+			"untouched attributes kept as is",
+			"/",
+			"text/html",
+			rules,
+			`<a download HREF='http://legacy:8080/f?a=1&amp;b="2"' title=&eacute;t&eacute; data-x = 1>f</a><img src=http://legacy:8080/i.png>`,
+			`<a download HREF='/app/f?a=1&amp;b="2"' title=&eacute;t&eacute; data-x = 1>f</a><img src=/app/i.png>`,
+		},
+		{
+			"unchanged tags kept as is",
+			"/",
+			"text/html",
+			rules,
+			"<!DOCTYPE html><!-- http://legacy:8080/ --><A HREF='/local' data-x=1>&eacute;</A>",
+			"<!DOCTYPE html><!-- http://legacy:8080/ --><A HREF='/local' data-x=1>&eacute;</A>",
+		},
+		{
+			"other url",
+			"/",
+			"text/html",
+			[]replaceParameters{{from: "http://legacy:8080/", to: "/app/", urls: []*regexp.Regexp{regexp.MustCompile("^/admin")}}},
+			`<a href="http://legacy:8080/home">`,
+			`<a href="http://legacy:8080/home">`,
+		},
+		{
+			"not html",
+			"/",
+			"text/css",
+			rules,
+			`body { background: url(http://legacy:8080/b.png) }`,
+			`body { background: url(http://legacy:8080/b.png) }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+			f := &Filter{
+				log: log,
+			}
+
+			if got := f.rewriteHTML(tt.url, bodyRules{html: tt.rules}, tt.body, tt.contentType); got != tt.want {
+				t.Errorf("Filter.rewriteHTML() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_scanTagAttributes(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string // Raw values of the attributes
+	}{
+		{`<a href="x" class=y>`, []string{"x", "y"}},
+		{`<img src='i.png'/>`, []string{"i.png"}},
+		{`<input disabled value = "v" >`, []string{"", "v"}},
+		{`<a =x href=/b/ title="">`, []string{"", "/b/", ""}},
+		{`<a href=>`, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			z := html.NewTokenizer(strings.NewReader(tt.raw))
+			z.Next()
+			token := z.Token()
+
+			attrs := scanTagAttributes([]byte(tt.raw))
+			if len(attrs) != len(token.Attr) || len(attrs) != len(tt.want) {
+				t.Fatalf("scanTagAttributes() = %+v, tokenizer %+v", attrs, token.Attr)
+			}
+
+			for i, attr := range attrs {
+				if attr.key != token.Attr[i].Key || tt.raw[attr.start:attr.end] != tt.want[i] {
+					t.Errorf("scanTagAttributes() attribute %d = %s %q", i, attr.key, tt.raw[attr.start:attr.end])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// Positions of the injected contents in the HTML pages.
+const (
+	injectHeadStart = "head-start"
+	injectHeadEnd   = "head-end"
+	injectBodyStart = "body-start"
+	injectBodyEnd   = "body-end"
+)
+
+// Tags marking the positions, the contents are injected after the opening tags and before the closing tags.
+var injectTags = map[string]string{ //nolint: gochecknoglobals
+	injectHeadStart: "head",
+	injectHeadEnd:   "head",
+	injectBodyStart: "body",
+	injectBodyEnd:   "body",
+}
+
+// Order of the positions in a page, used when several positions share the same offset.
+var injectOrder = []string{injectBodyEnd, injectBodyStart, injectHeadEnd, injectHeadStart} //nolint: gochecknoglobals
+
+// injection is a content inserted at a position of the HTML pages.
+type injection struct {
+	position string
+	content  string
+	file     string // Origin of the content, empty for an inline content
+	urls     []*regexp.Regexp
+	when     *conditions
+}
+
+// parseInjectConfig verifies the injection rules and loads the contents of the files.
+func parseInjectConfig(log logrus.FieldLogger, injects []Cinject, prefix []replaceParameters) []injection {
+	result := make([]injection, 0, len(injects))
+
+	for _, i := range injects {
+		if _, ok := injectTags[i.Position]; !ok {
+			log.Fatalf("Unknown inject position '%s' (head-start, head-end, body-start or body-end)", i.Position)
+		}
+
+		if (i.Content == "") == (i.File == "") {
+			log.Fatal("An inject rule must have either a content or a file")
+		}
+
+		content := i.Content
+
+		if i.File != "" {
+			b, err := os.ReadFile(i.File)
+			if err != nil {
+				log.Fatalf("Cannot read inject file: %v", err)
+			}
+
+			content = string(b)
+		}
+
+		result = append(result, injection{
+			position: i.Position,
+			content:  content,
+			file:     i.File,
+			urls:     parseURLsConfig(log, i.Urls, prefix),
+			when:     parseConditionsConfig(log, i.When),
+		})
+	}
+
+	return result
+}
+
+// inject inserts the contents of the injections concerning the url in the HTML page, the contents of a position are
+// inserted in the order of the configuration. A position is skipped if its tag is not in the page.
+func (f *Filter) inject(requestURL string, injections []injection, body string) string {
+	contents := map[string]string{}
+
+	for _, i := range injections {
+		if isURLConcerned(requestURL, i.urls) {
+			contents[i.position] += i.content
+		}
+	}
+
+	// The offsets are computed on the original page, the injected contents are never used as anchors
+	type insertion struct {
+		offset  int
+		content string
+	}
+
+	insertions := []insertion{}
+	anchors := findInjectAnchors(body)
+
+	for _, position := range injectOrder {
+		content, ok := contents[position]
+		if !ok {
+			continue
+		}
+
+		offset, ok := anchors[position]
+		if !ok {
+			f.log.Debug(fmt.Sprintf("No anchor for %s injection", position))
+
+			continue
+		}
+
+		insertions = append(insertions, insertion{offset: offset, content: content})
+	}
+
+	// From the end of the page to keep the offsets valid
+	sort.SliceStable(insertions, func(i, j int) bool { return insertions[i].offset > insertions[j].offset })
+
+	for _, i := range insertions {
+		body = body[:i.offset] + i.content + body[i.offset:]
+	}
+
+	return body
+}
+
+// findInjectAnchors returns the offsets of the positions in the HTML page, the tags in the comments, the scripts or
+// the attributes values are not anchors. A position is missing if its tag is not in the page.
+func findInjectAnchors(body string) map[string]int {
+	anchors := map[string]int{}
+	z := html.NewTokenizer(strings.NewReader(body))
+	offset := 0
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return anchors
+		}
+
+		start := offset
+		offset += len(z.Raw())
+
+		if tt != html.StartTagToken && tt != html.EndTagToken {
+			continue
+		}
+
+		name, _ := z.TagName()
+
+		for position, tag := range injectTags {
+			if _, found := anchors[position]; found || string(name) != tag {
+				continue
+			}
+
+			switch {
+			case tt == html.StartTagToken && (position == injectHeadStart || position == injectBodyStart):
+				anchors[position] = offset
+			case tt == html.EndTagToken && (position == injectHeadEnd || position == injectBodyEnd):
+				anchors[position] = start
+			}
+		}
+	}
+}
+
+// hasInjection returns true if at least one injection concerns the url.
+func hasInjection(requestURL string, injections []injection) bool {
+	for _, i := range injections {
+		if isURLConcerned(requestURL, i.urls) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectInjections returns the injections whose conditions are fulfilled.
+func selectInjections(injections []injection, ctx *ruleContext) []injection {
+	selected := make([]injection, 0, len(injections))
+
+	for _, i := range injections {
+		if i.when.match(ctx) {
+			selected = append(selected, i)
+		}
+	}
+
+	return selected
+}
+
+// expandInjections returns the injections with their templated contents expanded for this request.
+func (f *Filter) expandInjections(injections []injection, data *templateData) []injection {
+	if len(f.templates) == 0 {
+		return injections
+	}
+
+	expanded := make([]injection, len(injections))
+
+	for n, i := range injections {
+		expanded[n] = i
+		expanded[n].content = f.expand(i.content, data)
+	}
+
+	return expanded
+}
+
+// describe returns the origin of the content for the logs.
+func (i injection) describe() string {
+	if i.file != "" {
+		return "the content of " + i.file
+	}
+
+	return fmt.Sprintf("%q", strings.TrimSpace(i.content))
+}
@@ -0,0 +1,258 @@
+package filter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseInjectConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		injects     []Cinject
+		expectFatal bool
+		want        []injection
+	}{
+		{
+			"inline",
+			[]Cinject{{Position: "body-start", Content: "<div>dev</div>", Urls: []string{"/app/"}}},
+			false,
+			[]injection{{
+				position: injectBodyStart,
+				content:  "<div>dev</div>",
+				urls:     []*regexp.Regexp{regexp.MustCompile("^/app/")},
+			}},
+		},
+		{
+			"file",
+			[]Cinject{{Position: "body-end", File: "testdata/inject/banner.html", When: &Cconditions{Status: []int{200}}}},
+			false,
+			[]injection{{
+				position: injectBodyEnd,
+				content:  "<div class=\"banner\">Staging</div>\n",
+				file:     "testdata/inject/banner.html",
+				urls:     []*regexp.Regexp{},
+				when:     &conditions{status: []int{200}},
+			}},
+		},
+		{
+			"unknown position",
+			[]Cinject{{Position: "footer", Content: "x"}},
+			true,
+			nil,
+		},
+		{
+			"no content",
+			[]Cinject{{Position: "head-end"}},
+			true,
+			nil,
+		},
+		{
+			"content and file",
+			[]Cinject{{Position: "head-end", Content: "x", File: "testdata/inject/banner.html"}},
+			true,
+			nil,
+		},
+		{
+			"missing file",
+			[]Cinject{{Position: "head-end", File: "testdata/inject/missing.html"}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := parseInjectConfig(log, tt.injects, []replaceParameters{})
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseInjectConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInjectConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_inject(t *testing.T) {
+	page := `<html><HEAD lang="en"><title>t</title></head><body class="x"><p>text</p></body></html>`
+
+	tests := []struct {
+		name       string
+		requestURL string
+		injections []injection
+		body       string
+		want       string
+	}{
+		{
+			"all positions",
+			"/",
+			[]injection{
+				{position: injectBodyEnd, content: "[be]"},
+				{position: injectHeadStart, content: "[hs]"},
+				{position: injectBodyStart, content: "[bs]"},
+				{position: injectHeadEnd, content: "[he]"},
+			},
+			page,
+			`<html><HEAD lang="en">[hs]<title>t</title>[he]</head><body class="x">[bs]<p>text</p>[be]</body></html>`,
+		},
+		{
+			"configuration order for a position",
+			"/",
+			[]injection{
+				{position: injectBodyEnd, content: "[1]"},
+				{position: injectBodyEnd, content: "[2]"},
+			},
+			page,
+			`<html><HEAD lang="en"><title>t</title></head><body class="x"><p>text</p>[1][2]</body></html>`,
+		},
+		{
+			"empty head",
+			"/",
+			[]injection{
+				{position: injectHeadEnd, content: "[he]"},
+				{position: injectHeadStart, content: "[hs]"},
+			},
+			"<head></head>",
+			"<head>[hs][he]</head>",
+		},
+		{
+			"missing anchor",
+			"/",
+			[]injection{
+				{position: injectHeadStart, content: "[hs]"},
+				{position: injectBodyEnd, content: "[be]"},
+			},
+			"<p>fragment</p></body>",
+			"<p>fragment</p>[be]</body>",
+		},
+		{
+			"header tag is not an anchor",
+			"/",
+			[]injection{{position: injectHeadStart, content: "[hs]"}},
+			"<body><header>h</header></body>",
+			"<body><header>h</header></body>",
+		},
+		{
+			"closing body in a script",
+			"/",
+			[]injection{{position: injectBodyEnd, content: "[be]"}},
+			"<body><script>s = '</body>'</script></BODY >",
+			"<body><script>s = '</body>'</script>[be]</BODY >",
+		},
+		{
+			"tags in a comment and an attribute",
+			"/",
+			[]injection{
+				{position: injectHeadStart, content: "[hs]"},
+				{position: injectBodyEnd, content: "[be]"},
+			},
+			`<!-- <head> --><head title="<body>"></head><body></body><!-- </body> -->`,
+			`<!-- <head> --><head title="<body>">[hs]</head><body>[be]</body><!-- </body> -->`,
+		},
+		{
+			"injected content is not an anchor",
+			"/",
+			[]injection{
+				{position: injectHeadStart, content: "<body>"},
+				{position: injectBodyStart, content: "[bs]"},
+			},
+			"<head></head><body></body>",
+			"<head><body></head><body>[bs]</body>",
+		},
+		{
+			"url not concerned",
+			"/other/",
+			[]injection{
+				{position: injectBodyStart, content: "[app]", urls: []*regexp.Regexp{regexp.MustCompile("^/app/")}},
+				{position: injectBodyStart, content: "[all]"},
+			},
+			"<body></body>",
+			"<body>[all]</body>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			f := &Filter{log: log}
+
+			if got := f.inject(tt.requestURL, tt.injections, tt.body); got != tt.want {
+				t.Errorf("Filter.inject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateResponseInject(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	var compressed bytes.Buffer
+
+	w := gzip.NewWriter(&compressed)
+	_, _ = w.Write([]byte("<html><head></head><body>page</body></html>"))
+	_ = w.Close()
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/app/", nil)
+	req.Host = "dev.example.com"
+	r := http.Response{
+		Header: http.Header{
+			"Content-Type":     []string{"text/html; charset=utf-8"},
+			"Content-Encoding": []string{"gzip"},
+		},
+		StatusCode: http.StatusOK,
+		Request:    req,
+		Body:       io.NopCloser(&compressed),
+	}
+
+	ribbon := `<div>{{ .Request.Host }}</div>`
+	f := &Filter{
+		stream: true,
+		response: response{
+			Inject: []injection{
+				{position: injectHeadEnd, content: `<base href="/app/">`},
+				{position: injectBodyStart, content: ribbon},
+			},
+		},
+		contentTypes: []string{"text/html"},
+		templates:    map[string]*template.Template{ribbon: template.Must(template.New(ribbon).Parse(ribbon))},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	if r.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("Filter.UpdateResponse() Content-Length = %s, want %d", r.Header.Get("Content-Length"), len(body))
+	}
+
+	decoded, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Filter.UpdateResponse() body is not gzip encoded: %v", err)
+	}
+
+	got, _ := io.ReadAll(decoded)
+	want := `<html><head><base href="/app/"></head><body><div>dev.example.com</div>page</body></html>`
+
+	if string(got) != want {
+		t.Errorf("Filter.UpdateResponse() = %s, want %s", got, want)
+	}
+}
@@ -3,14 +3,21 @@ package filter
 import (
 	"net"
 	"net/http"
+
+	"github.com/marema31/villip/health"
 )
 
 // FilteredServer represents a reverse proxy.
 type FilteredServer interface {
 	IsConcerned(net.IP, http.Header) bool
+	Matches(*http.Request) bool
+	RouteSpecificity() int
 	Serve(http.ResponseWriter, *http.Request)
 	ServeTCP() error
 	IsConditional() bool
 	PrefixReplace(string) string
 	Kind() Type
+	Start()
+	Stop()
+	Statuses() map[string]health.StatusFunc
 }
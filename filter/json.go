@@ -0,0 +1,279 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+type jsonAction int
+
+const (
+	jsonSet     jsonAction = iota
+	jsonReplace jsonAction = iota
+	jsonDelete  jsonAction = iota
+	jsonStrings jsonAction = iota
+)
+
+type jsonParameters struct {
+	path    jsonPath
+	action  jsonAction
+	value   string            // JSON document used by set and replace, decoded for each use
+	replace replaceParameters // Used by strings
+	urls    []*regexp.Regexp
+	when    *conditions
+	source  string // Original JSONPath for logs
+}
+
+// jsonObject keeps the order of the keys of a JSON object.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+
+	o.values[key] = value
+}
+
+func (o *jsonObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+
+	delete(o.values, key)
+
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+
+			break
+		}
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeJSON decodes a JSON document keeping the order of the keys and the numbers as written.
+func decodeJSON(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := &jsonObject{keys: []string{}, values: map[string]interface{}{}}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			obj.set(key.(string), value)
+		}
+
+		_, err = dec.Token()
+
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, value)
+		}
+
+		_, err = dec.Token()
+
+		return arr, err
+	}
+
+	return token, nil
+}
+
+// encodeJSON serializes a document decoded by decodeJSON without escaping HTML characters.
+func encodeJSON(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := encodeJSON(buf, key); err != nil {
+				return err
+			}
+
+			buf.WriteByte(':')
+
+			if err := encodeJSON(buf, v.values[key]); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := encodeJSON(buf, element); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte(']')
+	default:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+
+		buf.Truncate(buf.Len() - 1) // Encode adds a newline
+	}
+
+	return nil
+}
+
+// replaceInStrings applies the replacement on all the string values under the node, keys are untouched.
+func replaceInStrings(url string, node interface{}, rep replaceParameters) interface{} {
+	switch v := node.(type) {
+	case string:
+		return do(url, v, []replaceParameters{rep}, false)
+	case *jsonObject:
+		for _, key := range v.keys {
+			v.values[key] = replaceInStrings(url, v.values[key], rep)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = replaceInStrings(url, v[i], rep)
+		}
+	}
+
+	return node
+}
+
+func (jp jsonParameters) edit(url string) jsonEdit {
+	return func(value interface{}, exists bool) (interface{}, jsonOperation) {
+		switch jp.action {
+		case jsonSet, jsonReplace:
+			if !exists && jp.action == jsonReplace {
+				return nil, opNone
+			}
+
+			// The value is decoded each time to never share nodes between documents
+			newValue, err := decodeJSON(jp.value)
+			if err != nil {
+				return nil, opNone
+			}
+
+			return newValue, opSet
+		case jsonDelete:
+			if !exists {
+				return nil, opNone
+			}
+
+			return nil, opDelete
+		case jsonStrings:
+			if !exists {
+				return nil, opNone
+			}
+
+			return replaceInStrings(url, value, jp.replace), opSet
+		}
+
+		return nil, opNone
+	}
+}
+
+// transformJSON applies the JSON rules concerning the url to a JSON body, the body is returned as is
+// if it is not a JSON document.
+func (f *Filter) transformJSON(requestURL string, rules []jsonParameters, body string, contentType string) string {
+	if !isJSON(contentType) || !hasJSONRule(requestURL, rules) {
+		return body
+	}
+
+	document, err := decodeJSON(body)
+	if err != nil {
+		f.log.Debugf("Body is not a valid JSON document, JSON rules not applied: %v", err)
+
+		return body
+	}
+
+	for _, jp := range rules {
+		if isURLConcerned(requestURL, jp.urls) {
+			document = jp.path.apply(document, jp.edit(requestURL))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, document); err != nil {
+		f.log.Errorf("Failed to encode JSON body: %v", err)
+
+		return body
+	}
+
+	f.log.Debug(fmt.Sprintf("Body after the JSON transformation : %s", buf.String()))
+
+	return buf.String()
+}
+
+// hasJSONRule returns true if at least one JSON rule concerns the url.
+func hasJSONRule(requestURL string, rules []jsonParameters) bool {
+	for _, jp := range rules {
+		if isURLConcerned(requestURL, jp.urls) {
+			return true
+		}
+	}
+
+	return false
+}
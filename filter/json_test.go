@@ -0,0 +1,243 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_isJSON(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"application/hal+json", true},
+		{"text/html", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := isJSON(tt.contentType); got != tt.want {
+				t.Errorf("isJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{"order kept", `{"z":1,"a":{"y":true,"b":null}}`, `{"z":1,"a":{"y":true,"b":null}}`, false},
+		{"numbers kept", `[1.50,1e3,12345678901234567890]`, `[1.50,1e3,12345678901234567890]`, false},
+		{"html not escaped", `{"a":"<b>&amp;</b>"}`, `{"a":"<b>&amp;</b>"}`, false},
+		{"whitespaces", "{ \"a\" :\n [ 1 , 2 ] }", `{"a":[1,2]}`, false},
+		{"invalid", `{"a":`, "", true},
+		{"trailing data", `{"a":1} {}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeJSON(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := encodeJSON(&buf, got); err != nil {
+				t.Fatalf("encodeJSON() error = %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("encodeJSON() = %s, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_transformJSON(t *testing.T) {
+	mustPath := func(p string) jsonPath {
+		path, err := parseJSONPath(p)
+		if err != nil {
+			t.Fatalf("parseJSONPath() error = %v", err)
+		}
+
+		return path
+	}
+
+	body := `{"url":"http://backend/api","http://backend/":"key","items":[{"href":"http://backend/1","id":1}]}`
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		rules       []jsonParameters
+		want        string
+	}{
+		{
+			"strings only in values",
+			"/api",
+			"application/json",
+			[]jsonParameters{
+				{
+					path:    mustPath("$"),
+					action:  jsonStrings,
+					replace: replaceParameters{from: "http://backend/", to: "https://public/"},
+				},
+			},
+			`{"url":"https://public/api","http://backend/":"key","items":[{"href":"https://public/1","id":1}]}`,
+		},
+		{
+			"strings regex",
+			"/api",
+			"application/json",
+			[]jsonParameters{
+				{
+					path:   mustPath("$..href"),
+					action: jsonStrings,
+					replace: replaceParameters{
+						from:  `http://backend/(\d+)`,
+						to:    "/items/$1",
+						regex: regexp.MustCompile(`http://backend/(\d+)`),
+					},
+				},
+			},
+			`{"url":"http://backend/api","http://backend/":"key","items":[{"href":"/items/1","id":1}]}`,
+		},
+		{
+			"set replace delete",
+			"/api",
+			"application/json; charset=utf-8",
+			[]jsonParameters{
+				{path: mustPath("$.url"), action: jsonSet, value: `{"public":true}`},
+				{path: mustPath("$.missing"), action: jsonReplace, value: `1`},
+				{path: mustPath("$.items[0].id"), action: jsonReplace, value: `"one"`},
+				{path: mustPath("$['http://backend/']"), action: jsonDelete},
+			},
+			`{"url":{"public":true},"items":[{"href":"http://backend/1","id":"one"}]}`,
+		},
+		{
+			"set on all elements",
+			"/api",
+			"application/json",
+			[]jsonParameters{
+				{path: mustPath("$.items[*].meta"), action: jsonSet, value: `{"a":1}`},
+				{path: mustPath("$.items[*].meta.a"), action: jsonReplace, value: `2`},
+			},
+			`{"url":"http://backend/api","http://backend/":"key","items":[{"href":"http://backend/1","id":1,"meta":{"a":2}}]}`,
+		},
+		{
+			"other url",
+			"/web",
+			"application/json",
+			[]jsonParameters{
+				{path: mustPath("$.url"), action: jsonDelete, urls: []*regexp.Regexp{regexp.MustCompile("^/api")}},
+			},
+			body,
+		},
+		{
+			"not json content type",
+			"/api",
+			"text/html",
+			[]jsonParameters{
+				{path: mustPath("$.url"), action: jsonDelete},
+			},
+			body,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+			f := &Filter{
+				log: log,
+			}
+
+			if got := f.transformJSON(tt.url, tt.rules, body, tt.contentType); got != tt.want {
+				t.Errorf("Filter.transformJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_transformJSONInvalid(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+	f := &Filter{
+		log: log,
+	}
+
+	rules := []jsonParameters{{path: jsonPath{}, action: jsonDelete}}
+
+	if got := f.transformJSON("/", rules, "not json", "application/json"); got != "not json" {
+		t.Errorf("Filter.transformJSON() = %s, want %s", got, "not json")
+	}
+
+	verifyLogged(
+		"Filter.transformJSON",
+		[]string{"Body is not a valid JSON document, JSON rules not applied: invalid character 'o' in literal null (expecting 'u')"},
+		hook,
+		t,
+	)
+}
+
+func TestFilter_UpdateResponseJSON(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8081/api/1", nil)
+
+	body := encodeString(t, "gzip", `{"url":"http://backend/api","secret":"123"}`)
+	r := http.Response{
+		Header: http.Header{
+			"Content-Type":     []string{"application/json"},
+			"Content-Encoding": []string{"gzip"},
+			"Content-Length":   []string{fmt.Sprint(len(body))},
+		},
+		StatusCode: http.StatusOK,
+		Request:    req,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	f := &Filter{
+		stream: true,
+		response: response{
+			JSON: []jsonParameters{{path: jsonPath{{kind: stepKey, key: "secret"}}, action: jsonDelete}},
+		},
+		contentTypes: []string{"application/json"},
+		log:          log,
+	}
+
+	if err := f.UpdateResponse(&r); err != nil {
+		t.Fatalf("Filter.UpdateResponse() error = %v", err)
+	}
+
+	compressed, _ := io.ReadAll(r.Body)
+	if r.Header.Get("Content-Length") != fmt.Sprint(len(compressed)) {
+		t.Errorf("Filter.UpdateResponse() Content-Length = %s, want %d", r.Header.Get("Content-Length"), len(compressed))
+	}
+
+	decoded, err := newDecoder("gzip", bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Filter.UpdateResponse() not gzipped: %v", err)
+	}
+
+	got, _ := io.ReadAll(decoded)
+	if string(got) != `{"url":"http://backend/api"}` {
+		t.Errorf("Filter.UpdateResponse() = %s, want %s", got, `{"url":"http://backend/api"}`)
+	}
+}
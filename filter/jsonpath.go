@@ -0,0 +1,233 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonStepKind int
+
+const (
+	stepKey       jsonStepKind = iota
+	stepIndex     jsonStepKind = iota
+	stepWildcard  jsonStepKind = iota
+	stepRecursive jsonStepKind = iota
+)
+
+type jsonPathStep struct {
+	kind  jsonStepKind
+	key   string
+	index int
+}
+
+// jsonPath is a parsed JSONPath expression, only the subset $ .key ['key'] [n] [*] .* and ..key is supported.
+type jsonPath []jsonPathStep
+
+type jsonOperation int
+
+const (
+	opNone   jsonOperation = iota
+	opSet    jsonOperation = iota
+	opDelete jsonOperation = iota
+)
+
+// jsonEdit is called for each node selected by the path, exists is false if the last key does not exist.
+type jsonEdit func(value interface{}, exists bool) (interface{}, jsonOperation)
+
+func parseJSONPath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath '%s' must start with $", path)
+	}
+
+	steps := jsonPath{}
+	s := path[1:]
+
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			name, rest := splitJSONPathName(s[2:])
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath '%s' has an empty name after ..", path)
+			}
+
+			steps = append(steps, jsonPathStep{kind: stepRecursive, key: name})
+			s = rest
+		case strings.HasPrefix(s, ".*"):
+			steps = append(steps, jsonPathStep{kind: stepWildcard})
+			s = s[2:]
+		case strings.HasPrefix(s, "."):
+			name, rest := splitJSONPathName(s[1:])
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath '%s' has an empty name", path)
+			}
+
+			steps = append(steps, jsonPathStep{kind: stepKey, key: name})
+			s = rest
+		case strings.HasPrefix(s, "["):
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath '%s' has an unclosed [", path)
+			}
+
+			step, err := parseJSONPathBracket(s[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath '%s': %w", path, err)
+			}
+
+			steps = append(steps, step)
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath '%s' is invalid near '%s'", path, s)
+		}
+	}
+
+	return steps, nil
+}
+
+func splitJSONPathName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+
+	return s[:end], s[end:]
+}
+
+func parseJSONPathBracket(selector string) (jsonPathStep, error) {
+	selector = strings.TrimSpace(selector)
+
+	if selector == "*" {
+		return jsonPathStep{kind: stepWildcard}, nil
+	}
+
+	if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+		return jsonPathStep{kind: stepKey, key: selector[1 : len(selector)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(selector)
+	if err != nil {
+		return jsonPathStep{}, fmt.Errorf("'%s' is not a valid selector", selector)
+	}
+
+	return jsonPathStep{kind: stepIndex, index: index}, nil
+}
+
+// apply calls edit on every node selected by the path and returns the modified document.
+func (p jsonPath) apply(node interface{}, edit jsonEdit) interface{} {
+	if len(p) == 0 {
+		if value, op := edit(node, true); op == opSet {
+			return value
+		}
+
+		return node
+	}
+
+	step, rest := p[0], p[1:]
+
+	switch step.kind {
+	case stepKey:
+		obj, ok := node.(*jsonObject)
+		if !ok {
+			return node
+		}
+
+		child, exists := obj.values[step.key]
+		if len(rest) != 0 {
+			if exists {
+				obj.set(step.key, rest.apply(child, edit))
+			}
+
+			return obj
+		}
+
+		switch value, op := edit(child, exists); op {
+		case opSet:
+			obj.set(step.key, value)
+		case opDelete:
+			obj.delete(step.key)
+		case opNone:
+		}
+
+		return obj
+	case stepIndex:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return node
+		}
+
+		i := step.index
+		if i < 0 {
+			i += len(arr)
+		}
+
+		if i < 0 || i >= len(arr) {
+			return arr
+		}
+
+		return applyToElement(arr, i, rest, edit)
+	case stepWildcard:
+		switch n := node.(type) {
+		case *jsonObject:
+			for _, key := range append([]string{}, n.keys...) {
+				n = jsonPath{{kind: stepKey, key: key}}.concat(rest).apply(n, edit).(*jsonObject)
+			}
+
+			return n
+		case []interface{}:
+			for i := len(n) - 1; i >= 0; i-- {
+				n = applyToElement(n, i, rest, edit)
+			}
+
+			return n
+		}
+
+		return node
+	case stepRecursive:
+		// Only the existing keys are selected, a set does not add the key to all the nested objects
+		existing := func(value interface{}, exists bool) (interface{}, jsonOperation) {
+			if !exists {
+				return value, opNone
+			}
+
+			return edit(value, exists)
+		}
+
+		switch n := node.(type) {
+		case *jsonObject:
+			for _, key := range n.keys {
+				n.values[key] = p.apply(n.values[key], edit)
+			}
+		case []interface{}:
+			for i := range n {
+				n[i] = p.apply(n[i], edit)
+			}
+		}
+
+		return jsonPath{{kind: stepKey, key: step.key}}.concat(rest).apply(node, existing)
+	}
+
+	return node
+}
+
+func (p jsonPath) concat(rest jsonPath) jsonPath {
+	return append(append(jsonPath{}, p...), rest...)
+}
+
+func applyToElement(arr []interface{}, i int, rest jsonPath, edit jsonEdit) []interface{} {
+	if len(rest) != 0 {
+		arr[i] = rest.apply(arr[i], edit)
+
+		return arr
+	}
+
+	switch value, op := edit(arr[i], true); op {
+	case opSet:
+		arr[i] = value
+	case opDelete:
+		arr = append(arr[:i], arr[i+1:]...)
+	case opNone:
+	}
+
+	return arr
+}
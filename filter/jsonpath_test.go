@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_parseJSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    jsonPath
+		wantErr bool
+	}{
+		{
+			"root",
+			"$",
+			jsonPath{},
+			false,
+		},
+		{
+			"dotted",
+			"$.data.url",
+			jsonPath{{kind: stepKey, key: "data"}, {kind: stepKey, key: "url"}},
+			false,
+		},
+		{
+			"brackets",
+			"$['data'][\"my.url\"][2][-1][*]",
+			jsonPath{
+				{kind: stepKey, key: "data"},
+				{kind: stepKey, key: "my.url"},
+				{kind: stepIndex, index: 2},
+				{kind: stepIndex, index: -1},
+				{kind: stepWildcard},
+			},
+			false,
+		},
+		{
+			"wildcard and recursive",
+			"$.items.*..href",
+			jsonPath{{kind: stepKey, key: "items"}, {kind: stepWildcard}, {kind: stepRecursive, key: "href"}},
+			false,
+		},
+		{
+			"no root",
+			"data.url",
+			nil,
+			true,
+		},
+		{
+			"empty name",
+			"$.data.",
+			nil,
+			true,
+		},
+		{
+			"unclosed bracket",
+			"$.data[2",
+			nil,
+			true,
+		},
+		{
+			"invalid selector",
+			"$.data[two]",
+			nil,
+			true,
+		},
+		{
+			"invalid character",
+			"$data",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSONPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONPath() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_jsonPath_apply(t *testing.T) {
+	setX := func(value interface{}, exists bool) (interface{}, jsonOperation) { return "x", opSet }
+	replaceX := func(value interface{}, exists bool) (interface{}, jsonOperation) {
+		if !exists {
+			return nil, opNone
+		}
+
+		return "x", opSet
+	}
+	remove := func(value interface{}, exists bool) (interface{}, jsonOperation) { return nil, opDelete }
+
+	document := `{"a":1,"b":[{"c":2,"d":3},{"c":4}],"e":{"c":5}}`
+
+	tests := []struct {
+		name string
+		path string
+		edit jsonEdit
+		want string
+	}{
+		{"set existing", "$.a", setX, `{"a":"x","b":[{"c":2,"d":3},{"c":4}],"e":{"c":5}}`},
+		{"set new", "$.e.f", setX, `{"a":1,"b":[{"c":2,"d":3},{"c":4}],"e":{"c":5,"f":"x"}}`},
+		{"set under missing", "$.z.f", setX, document},
+		{"replace missing", "$.e.f", replaceX, document},
+		{"index", "$.b[1].c", replaceX, `{"a":1,"b":[{"c":2,"d":3},{"c":"x"}],"e":{"c":5}}`},
+		{"negative index", "$.b[-2].d", replaceX, `{"a":1,"b":[{"c":2,"d":"x"},{"c":4}],"e":{"c":5}}`},
+		{"out of range", "$.b[5].c", replaceX, document},
+		{"wildcard", "$.b[*].c", replaceX, `{"a":1,"b":[{"c":"x","d":3},{"c":"x"}],"e":{"c":5}}`},
+		{"recursive", "$..c", replaceX, `{"a":1,"b":[{"c":"x","d":3},{"c":"x"}],"e":{"c":"x"}}`},
+		{"set recursive", "$..d", setX, `{"a":1,"b":[{"c":2,"d":"x"},{"c":4}],"e":{"c":5}}`},
+		{"delete key", "$.b[0].d", remove, `{"a":1,"b":[{"c":2},{"c":4}],"e":{"c":5}}`},
+		{"delete element", "$.b[0]", remove, `{"a":1,"b":[{"c":4}],"e":{"c":5}}`},
+		{"delete all elements", "$.b[*]", remove, `{"a":1,"b":[],"e":{"c":5}}`},
+		{"delete all keys", "$.*", remove, `{}`},
+		{"delete recursive", "$..c", remove, `{"a":1,"b":[{"d":3},{}],"e":{}}`},
+		{"type mismatch", "$.a.c", replaceX, document},
+		{"root", "$", setX, `"x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := parseJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("parseJSONPath() error = %v", err)
+			}
+
+			doc, err := decodeJSON(document)
+			if err != nil {
+				t.Fatalf("decodeJSON() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := encodeJSON(&buf, path.apply(doc, tt.edit)); err != nil {
+				t.Fatalf("encodeJSON() error = %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("jsonPath.apply() = %s, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package filter
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 func (f *Filter) startLog() {
@@ -11,16 +13,56 @@ func (f *Filter) startLog() {
 		f.log.Info("All requests")
 	}
 
+	if f.route != nil {
+		f.log.Info(fmt.Sprintf("Only for the requests with %s", f.route))
+	}
+
 	if f.kind != HTTP {
 		return
 	}
 
 	f.log.Info(fmt.Sprintf("For content-type %s", f.contentTypes))
+	f.printUpstreamsInLog()
+	f.printTransportInLog()
+
+	if f.stream {
+		f.log.Info("Response bodies will be streamed when possible")
+	}
+
+	if f.websocket == websocketFilter {
+		f.log.Info("WebSocket text messages will be filtered")
+	}
+
+	switch {
+	case f.flushInterval < 0:
+		f.log.Info("Responses will be flushed to the client after each write")
+	case f.flushInterval > 0:
+		f.log.Info(fmt.Sprintf("Responses will be flushed to the client every %s", f.flushInterval))
+	}
+
+	switch {
+	case f.autoRebase && f.publicURL != "":
+		f.log.Info(fmt.Sprintf("URLs of %s will be rebased on %s", f.url, f.publicURL))
+	case f.autoRebase:
+		f.log.Info(fmt.Sprintf("URLs of %s will be rebased on the host requested by the client", f.url))
+	}
 
+	f.printRewriteInLog()
 	f.printBodyReplaceInLog("request")
+	f.printDictionaryInLog("request")
+	f.printJSONInLog("request")
+	f.printTransformInLog("request")
 	f.printHeaderReplaceInLog("request")
+	f.printTransformersInLog("request")
 	f.printBodyReplaceInLog("response")
+	f.printDictionaryInLog("response")
+	f.printBodyReplaceInLog("html")
+	f.printJSONInLog("response")
+	f.printTransformInLog("response")
 	f.printHeaderReplaceInLog("response")
+	f.printInjectInLog()
+	f.printCookiesInLog()
+	f.printTransformersInLog("response")
 }
 
 func (f *Filter) printBodyReplaceInLog(action string) {
@@ -31,29 +73,69 @@ func (f *Filter) printBodyReplaceInLog(action string) {
 		rep = f.request.Replace
 	case "response":
 		rep = f.response.Replace
+	case "html":
+		rep = f.response.HTML
 	}
 
 	if len(rep) > 0 {
-		f.log.Info(fmt.Sprintf("And replace in %s body:", action))
+		if action == "html" {
+			f.log.Info("And replace in response HTML URLs:")
+		} else {
+			f.log.Info(fmt.Sprintf("And replace in %s body:", action))
+		}
 
 		for _, r := range rep {
-			f.log.Info(fmt.Sprintf("   %s  by  %s", r.from, r.to))
+			if r.regex != nil {
+				f.log.Info(fmt.Sprintf("   %s  by  %s (regex)", r.from, r.to))
+			} else {
+				f.log.Info(fmt.Sprintf("   %s  by  %s", r.from, r.to))
+			}
 
-			if len(r.urls) != 0 {
-				var us []string
+			f.printURLsInLog(r.urls)
 
-				for _, u := range r.urls {
-					us = append(us, u.String())
-				}
+			if r.when != nil {
+				f.log.Info(fmt.Sprintf("    when %s", r.when))
+			}
+		}
+	}
+}
 
-				f.log.Info(fmt.Sprintf("    for %v", us))
+func (f *Filter) printJSONInLog(action string) {
+	rules := []jsonParameters{}
+
+	switch action {
+	case "request":
+		rules = f.request.JSON
+	case "response":
+		rules = f.response.JSON
+	}
+
+	if len(rules) > 0 {
+		f.log.Info(fmt.Sprintf("And transform in %s JSON body:", action))
+
+		for _, j := range rules {
+			switch j.action {
+			case jsonSet:
+				f.log.Info(fmt.Sprintf("   set %s to %s", j.source, j.value))
+			case jsonReplace:
+				f.log.Info(fmt.Sprintf("   replace %s by %s", j.source, j.value))
+			case jsonDelete:
+				f.log.Info(fmt.Sprintf("   delete %s", j.source))
+			case jsonStrings:
+				f.log.Info(fmt.Sprintf("   in strings of %s replace %s  by  %s", j.source, j.replace.from, j.replace.to))
+			}
+
+			f.printURLsInLog(j.urls)
+
+			if j.when != nil {
+				f.log.Info(fmt.Sprintf("    when %s", j.when))
 			}
 		}
 	}
 }
 
 func (f *Filter) printHeaderReplaceInLog(action string) {
-	head := []Cheader{}
+	head := []headerRule{}
 
 	switch action {
 	case "request":
@@ -66,14 +148,243 @@ func (f *Filter) printHeaderReplaceInLog(action string) {
 		f.log.Info(fmt.Sprintf("And set/replace in %s Header:", action))
 
 		for _, h := range head {
-			m := fmt.Sprintf("    for header %s set/replace value by : %s", h.Name, h.Value)
-			if h.Force {
-				m += " (force = true -> in all the cases)"
-			} else {
-				m += " (force = false -> only if value is empty or header undefined)"
+			switch {
+			case h.delete:
+				f.log.Info(fmt.Sprintf("    delete header %s", h.name))
+			case h.from != "":
+				m := fmt.Sprintf("    for header %s replace %s by %s in values", h.name, h.from, h.to)
+				if h.regex != nil {
+					m += " (regex)"
+				}
+
+				if h.rename != "" {
+					m += fmt.Sprintf(" and rename it to %s", h.rename)
+				}
+
+				f.log.Info(m)
+			case h.rename != "":
+				f.log.Info(fmt.Sprintf("    rename header %s to %s", h.name, h.rename))
+			default:
+				m := fmt.Sprintf("    for header %s set/replace value by : %s", h.name, h.value)
+				if h.force {
+					m += " (force = true -> in all the cases)"
+				} else {
+					m += " (force = false -> only if value is empty or header undefined)"
+				}
+
+				f.log.Info(m)
 			}
 
-			f.log.Info(m)
+			f.printURLsInLog(h.urls)
+
+			if h.when != nil {
+				f.log.Info(fmt.Sprintf("    when %s", h.when))
+			}
+		}
+	}
+}
+
+func (f *Filter) printCookiesInLog() {
+	c := f.response.Cookies
+	if c == nil {
+		return
+	}
+
+	f.log.Info("And rewrite response cookies:")
+
+	for _, r := range c.name {
+		f.log.Info(fmt.Sprintf("    rename cookie %s to %s", r.from, r.to))
+	}
+
+	for _, r := range c.domain {
+		f.log.Info(fmt.Sprintf("    replace domain %s by %s", r.from, r.to))
+	}
+
+	if c.reversePrefix {
+		f.log.Info("    reverse the prefix rules on the path")
+	}
+
+	for _, r := range c.path {
+		f.log.Info(fmt.Sprintf("    replace path %s by %s", r.from, r.to))
+	}
+
+	if c.secure != "" {
+		f.log.Info(fmt.Sprintf("    %s Secure attribute", c.secure))
+	}
+
+	switch c.sameSite {
+	case "":
+	case cookieStrip:
+		f.log.Info("    strip SameSite attribute")
+	default:
+		f.log.Info(fmt.Sprintf("    set SameSite attribute to %s", cookieSameSite[c.sameSite]))
+	}
+}
+
+func (f *Filter) printURLsInLog(urls []*regexp.Regexp) {
+	if len(urls) == 0 {
+		return
+	}
+
+	var us []string
+
+	for _, u := range urls {
+		us = append(us, u.String())
+	}
+
+	f.log.Info(fmt.Sprintf("    for %v", us))
+}
+
+func (f *Filter) printDictionaryInLog(action string) {
+	dictionaries := []dictionary{}
+
+	switch action {
+	case "request":
+		dictionaries = f.request.Dictionary
+	case "response":
+		dictionaries = f.response.Dictionary
+	}
+
+	for _, d := range dictionaries {
+		f.log.Info(fmt.Sprintf("And replace in %s body the %d entries of %s", action, d.size, d.file))
+		f.printURLsInLog(d.urls)
+
+		if d.when != nil {
+			f.log.Info(fmt.Sprintf("    when %s", d.when))
+		}
+	}
+}
+
+func (f *Filter) printInjectInLog() {
+	for _, i := range f.response.Inject {
+		f.log.Info(fmt.Sprintf("And inject at %s of response HTML %s", i.position, i.describe()))
+		f.printURLsInLog(i.urls)
+
+		if i.when != nil {
+			f.log.Info(fmt.Sprintf("    when %s", i.when))
 		}
 	}
 }
+
+func (f *Filter) printTransformInLog(action string) {
+	commands := []command{}
+
+	switch action {
+	case "request":
+		commands = f.request.Transform
+	case "response":
+		commands = f.response.Transform
+	}
+
+	for _, c := range commands {
+		f.log.Info(fmt.Sprintf("And transform %s body with %s %v (timeout %s)", action, c.path, c.args, c.timeout))
+
+		if len(c.headers) > 0 {
+			f.log.Info(fmt.Sprintf("    with headers %v", c.headers))
+		}
+
+		f.printURLsInLog(c.urls)
+
+		if c.when != nil {
+			f.log.Info(fmt.Sprintf("    when %s", c.when))
+		}
+	}
+}
+
+func (f *Filter) printTransformersInLog(action string) {
+	transformers := []namedTransformer{}
+
+	switch action {
+	case "request":
+		transformers = f.request.Transformers
+	case "response":
+		transformers = f.response.Transformers
+	}
+
+	for _, t := range transformers {
+		f.log.Info(fmt.Sprintf("And call the %s transformer on the %s", t.name, action))
+	}
+}
+
+func (f *Filter) printRewriteInLog() {
+	if len(f.rewrite) == 0 {
+		return
+	}
+
+	f.log.Info("Rewrite the request URLs (first matching rule):")
+
+	for _, r := range f.rewrite {
+		for _, line := range r.describe() {
+			f.log.Info(line)
+		}
+	}
+}
+
+func (f *Filter) printUpstreamsInLog() {
+	if f.upstreams == nil {
+		return
+	}
+
+	switch {
+	case f.upstreams.strategy == balanceHash && f.upstreams.cookie != "" && f.upstreams.header != "":
+		f.log.Info(fmt.Sprintf("Balance the requests by hash of the %s cookie or of the %s header between:",
+			f.upstreams.cookie, f.upstreams.header))
+	case f.upstreams.strategy == balanceHash && f.upstreams.cookie != "":
+		f.log.Info(fmt.Sprintf("Balance the requests by hash of the %s cookie between:", f.upstreams.cookie))
+	case f.upstreams.strategy == balanceHash:
+		f.log.Info(fmt.Sprintf("Balance the requests by hash of the %s header between:", f.upstreams.header))
+	default:
+		f.log.Info(fmt.Sprintf("Balance the requests (%s) between:", f.upstreams.strategy))
+	}
+
+	for _, up := range f.upstreams.upstreams {
+		f.log.Info(fmt.Sprintf("    %s", up.url))
+	}
+
+	if hc := f.upstreams.check; hc != nil {
+		f.log.Info(fmt.Sprintf(
+			"Check %s every %s (timeout %s), eject after %d failures and bring back after %d successes",
+			hc.path, hc.interval, hc.timeout, hc.unhealthyThreshold, hc.healthyThreshold,
+		))
+	}
+}
+
+func (f *Filter) printTransportInLog() {
+	if f.transport == nil {
+		return
+	}
+
+	f.log.Info(fmt.Sprintf("Keep up to %d idle connections per upstream during %s",
+		f.transport.MaxIdleConnsPerHost, f.transport.IdleConnTimeout))
+
+	if f.transport.ResponseHeaderTimeout > 0 {
+		f.log.Info(fmt.Sprintf("Wait the response headers of the upstream during %s", f.transport.ResponseHeaderTimeout))
+	}
+
+	if f.transport.ForceAttemptHTTP2 {
+		f.log.Info("Use HTTP/2 with the TLS upstreams supporting it")
+	}
+
+	if f.timeout > 0 {
+		f.log.Info(fmt.Sprintf("Abort the requests to the upstream after %s", f.timeout))
+	}
+
+	if f.retry != nil {
+		f.log.Info(fmt.Sprintf("Try the idempotent requests up to %d times on connection errors or status %v (backoff %s to %s)",
+			f.retry.attempts, f.retry.status, f.retry.backoff, f.retry.maxBackoff))
+	}
+
+	if f.circuit != nil {
+		reasons := []string{}
+		if f.circuit.failures > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d consecutive failures", f.circuit.failures))
+		}
+
+		if f.circuit.failurePercent > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d%% of failures over %d requests", f.circuit.failurePercent, f.circuit.window))
+		}
+
+		f.log.Info(fmt.Sprintf("Open the circuit of an upstream after %s, answer %d during %s",
+			strings.Join(reasons, " or "), f.circuit.status, f.circuit.cooldown))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	logrustest "github.com/sirupsen/logrus/hooks/test"
@@ -71,7 +72,7 @@ func TestFilter_printBodyReplaceInLog(t *testing.T) {
 				request{},
 				response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				[]string{"text/html", "text/css", "application/javascript"},
 			},
@@ -83,7 +84,7 @@ func TestFilter_printBodyReplaceInLog(t *testing.T) {
 			fields{
 				request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				response{},
 				[]string{"text/html", "text/css", "application/javascript"},
@@ -106,7 +107,7 @@ func TestFilter_printBodyReplaceInLog(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				[]string{"text/html", "text/css", "application/javascript"},
 			},
@@ -127,7 +128,7 @@ func TestFilter_printBodyReplaceInLog(t *testing.T) {
 							},
 						},
 					},
-					Header: []Cheader{},
+					Header: []headerRule{},
 				},
 				response{},
 				[]string{"text/html", "text/css", "application/javascript"},
@@ -135,6 +136,44 @@ func TestFilter_printBodyReplaceInLog(t *testing.T) {
 			args{"request"},
 			[]string{"And replace in request body:", "   book  by  smartphone", "    for [/youngster /children]"},
 		},
+		{
+			"html",
+			fields{
+				request{},
+				response{
+					HTML: []replaceParameters{
+						{
+							from: "http://legacy:8080/",
+							to:   "/app/",
+							urls: []*regexp.Regexp{},
+						},
+					},
+				},
+				[]string{"text/html", "text/css", "application/javascript"},
+			},
+			args{"html"},
+			[]string{"And replace in response HTML URLs:", "   http://legacy:8080/  by  /app/"},
+		},
+		{
+			"regex",
+			fields{
+				request{},
+				response{
+					Replace: []replaceParameters{
+						{
+							from:  `legacy-(\w+)\.corp`,
+							to:    "/svc/$1",
+							urls:  []*regexp.Regexp{},
+							regex: regexp.MustCompile(`legacy-(\w+)\.corp`),
+						},
+					},
+					Header: []headerRule{},
+				},
+				[]string{"text/html", "text/css", "application/javascript"},
+			},
+			args{"response"},
+			[]string{"And replace in response body:", "   legacy-(\\w+)\\.corp  by  /svc/$1 (regex)"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -173,7 +212,7 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 				request{},
 				response{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				[]string{"text/html", "text/css", "application/javascript"},
 			},
@@ -185,7 +224,7 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 			fields{
 				request{
 					Replace: []replaceParameters{},
-					Header:  []Cheader{},
+					Header:  []headerRule{},
 				},
 				response{},
 				[]string{"text/html", "text/css", "application/javascript"},
@@ -199,16 +238,16 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 				request{},
 				response{
 					Replace: []replaceParameters{},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-TEST",
-							Value: "valid",
-							Force: false,
+							name:  "X-TEST",
+							value: "valid",
+							force: false,
 						},
 						{
-							Name:  "X-Author",
-							Value: "bob",
-							Force: true,
+							name:  "X-Author",
+							value: "bob",
+							force: true,
 						},
 					},
 				},
@@ -226,16 +265,16 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 			fields{
 				request{
 					Replace: []replaceParameters{},
-					Header: []Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "dev",
-							Force: false,
+							name:  "X-ENV",
+							value: "dev",
+							force: false,
 						},
 						{
-							Name:  "X-Version",
-							Value: "1.2",
-							Force: true,
+							name:  "X-Version",
+							value: "1.2",
+							force: true,
 						},
 					},
 				},
@@ -249,6 +288,50 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 				"    for header X-Version set/replace value by : 1.2 (force = true -> in all the cases)",
 			},
 		},
+		{
+			"rewrite delete rename",
+			fields{
+				request{},
+				response{
+					Header: []headerRule{
+						{name: "X-Powered-By", delete: true},
+						{name: "Link", from: "http://legacy:8080/", to: "/app/"},
+						{name: "Location", from: `^http://legacy(:\d+)?/`, to: "/", regex: regexp.MustCompile(`^http://legacy(:\d+)?/`), rename: "X-Location"},
+						{name: "X-Old", rename: "X-New"},
+					},
+				},
+				[]string{"text/html"},
+			},
+			args{"response"},
+			[]string{
+				"And set/replace in response Header:",
+				"    delete header X-Powered-By",
+				"    for header Link replace http://legacy:8080/ by /app/ in values",
+				"    for header Location replace ^http://legacy(:\\d+)?/ by / in values (regex) and rename it to X-Location",
+				"    rename header X-Old to X-New",
+			},
+		},
+		{
+			"conditions",
+			fields{
+				request{},
+				response{
+					Header: []headerRule{
+						{name: "Cache-Control", value: "no-store", force: true, when: &conditions{methods: []string{"POST"}}},
+						{name: "X-Powered-By", delete: true, urls: []*regexp.Regexp{regexp.MustCompile("^/api/")}},
+					},
+				},
+				[]string{"text/html"},
+			},
+			args{"response"},
+			[]string{
+				"And set/replace in response Header:",
+				"    for header Cache-Control set/replace value by : no-store (force = true -> in all the cases)",
+				"    when method in [POST]",
+				"    delete header X-Powered-By",
+				"    for [^/api/]",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -268,3 +351,191 @@ func TestFilter_printHeaderReplaceInLog(t *testing.T) {
 		})
 	}
 }
+
+func TestFilter_printCookiesInLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookies *cookieRules
+		wantLog []string
+	}{
+		{
+			"no cookies",
+			nil,
+			[]string{},
+		},
+		{
+			"all rules",
+			&cookieRules{
+				name:          []replaceParameters{{from: "JSESSIONID", to: "LEGACYSESSION"}},
+				domain:        []replaceParameters{{from: "legacy.internal", to: "example.com"}},
+				path:          []replaceParameters{{from: "/app/", to: "/"}},
+				reversePrefix: true,
+				secure:        cookieAdd,
+				sameSite:      "lax",
+			},
+			[]string{
+				"And rewrite response cookies:",
+				"    rename cookie JSESSIONID to LEGACYSESSION",
+				"    replace domain legacy.internal by example.com",
+				"    reverse the prefix rules on the path",
+				"    replace path /app/ by /",
+				"    add Secure attribute",
+				"    set SameSite attribute to Lax",
+			},
+		},
+		{
+			"strip",
+			&cookieRules{secure: cookieStrip, sameSite: cookieStrip},
+			[]string{
+				"And rewrite response cookies:",
+				"    strip Secure attribute",
+				"    strip SameSite attribute",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+
+			f := &Filter{
+				log:      log,
+				response: response{Cookies: tt.cookies},
+			}
+
+			f.printCookiesInLog()
+
+			verifyLogged("Filter.printCookiesInLog", tt.wantLog, hook, t)
+		})
+	}
+}
+
+func TestFilter_printDictionaryInLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		request  []dictionary
+		response []dictionary
+		wantLog  []string
+	}{
+		{
+			"no dictionary",
+			"response",
+			nil,
+			nil,
+			[]string{},
+		},
+		{
+			"response",
+			"response",
+			[]dictionary{{file: "request.csv", size: 1}},
+			[]dictionary{
+				{
+					file: "urls.csv",
+					size: 3,
+					urls: []*regexp.Regexp{regexp.MustCompile("^/blog/")},
+					when: &conditions{status: []int{200}},
+				},
+				{file: "words.yaml", size: 2},
+			},
+			[]string{
+				"And replace in response body the 3 entries of urls.csv",
+				"    for [^/blog/]",
+				"    when status in [200]",
+				"And replace in response body the 2 entries of words.yaml",
+			},
+		},
+		{
+			"request",
+			"request",
+			[]dictionary{{file: "request.csv", size: 1}},
+			nil,
+			[]string{"And replace in request body the 1 entries of request.csv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+
+			f := &Filter{
+				log:      log,
+				request:  request{Dictionary: tt.request},
+				response: response{Dictionary: tt.response},
+			}
+
+			f.printDictionaryInLog(tt.action)
+
+			verifyLogged("Filter.printDictionaryInLog", tt.wantLog, hook, t)
+		})
+	}
+}
+
+func TestFilter_printInjectInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log: log,
+		response: response{Inject: []injection{
+			{position: injectBodyStart, content: "<div>dev</div>\n", urls: []*regexp.Regexp{regexp.MustCompile("^/app/")}},
+			{position: injectBodyEnd, content: "<script></script>", file: "analytics.html", when: &conditions{status: []int{200}}},
+		}},
+	}
+
+	f.printInjectInLog()
+
+	verifyLogged("Filter.printInjectInLog", []string{
+		`And inject at body-start of response HTML "<div>dev</div>"`,
+		"    for [^/app/]",
+		"And inject at body-end of response HTML the content of analytics.html",
+		"    when status in [200]",
+	}, hook, t)
+}
+
+func TestFilter_printTransformInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log: log,
+		request: request{Transform: []command{
+			{path: "/usr/bin/pdf-links", args: []string{"--public"}, timeout: 5 * time.Second, headers: []string{"X-Tenant"}},
+		}},
+		response: response{Transform: []command{
+			{
+				path:    "/usr/bin/jsfix",
+				timeout: time.Second,
+				urls:    []*regexp.Regexp{regexp.MustCompile("^/js/")},
+				when:    &conditions{contentTypes: []string{"javascript"}},
+			},
+		}},
+	}
+
+	f.printTransformInLog("request")
+	f.printTransformInLog("response")
+
+	verifyLogged("Filter.printTransformInLog", []string{
+		"And transform request body with /usr/bin/pdf-links [--public] (timeout 5s)",
+		"    with headers [X-Tenant]",
+		"And transform response body with /usr/bin/jsfix [] (timeout 1s)",
+		"    for [^/js/]",
+		"    when content-type in [javascript]",
+	}, hook, t)
+}
+
+func TestFilter_printTransformersInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log:      log,
+		request:  request{Transformers: []namedTransformer{{name: "sign"}}},
+		response: response{Transformers: []namedTransformer{{name: "pdf"}, {name: "minify"}}},
+	}
+
+	f.printTransformersInLog("request")
+	f.printTransformersInLog("response")
+
+	verifyLogged("Filter.printTransformersInLog", []string{
+		"And call the sign transformer on the request",
+		"And call the pdf transformer on the response",
+		"And call the minify transformer on the response",
+	}, hook, t)
+}
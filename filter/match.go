@@ -0,0 +1,159 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// route selects the requests sent to a filter sharing its port with other filters.
+type route struct {
+	prefixes []string         // A prefix ending with * matches any path starting with it, the others whole segments
+	regexes  []*regexp.Regexp // Used instead of the prefixes for the regex match
+	methods  []string
+}
+
+// parseRouteConfig verifies the paths and methods of the match section, it returns nil if the filter has none.
+func parseRouteConfig(log logrus.FieldLogger, c Croute) *route {
+	if len(c.Paths) == 0 && len(c.Methods) == 0 {
+		if c.Regex {
+			log.Fatal("The regex match needs paths")
+		}
+
+		return nil
+	}
+
+	r := &route{}
+
+	for _, p := range c.Paths {
+		switch {
+		case c.Regex:
+			r.regexes = append(r.regexes, parseRegexConfig(log, p))
+		case !strings.HasPrefix(p, "/"):
+			log.Fatalf("The matched path %s must start with /", p)
+		default:
+			r.prefixes = append(r.prefixes, p)
+		}
+	}
+
+	for _, m := range c.Methods {
+		if m == "" || strings.ContainsAny(m, " /") {
+			log.Fatalf("%s is not a valid HTTP method", m)
+		}
+
+		r.methods = append(r.methods, strings.ToUpper(m))
+	}
+
+	return r
+}
+
+// matchPrefix returns true if the path starts with the prefix, on a segment boundary unless the prefix ends with *.
+func matchPrefix(prefix string, path string) bool {
+	if p, ok := strings.CutSuffix(prefix, "*"); ok {
+		return strings.HasPrefix(path, p)
+	}
+
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+func (r *route) matchPath(path string) bool {
+	if len(r.prefixes) == 0 && len(r.regexes) == 0 {
+		return true
+	}
+
+	for _, prefix := range r.prefixes {
+		if matchPrefix(prefix, path) {
+			return true
+		}
+	}
+
+	for _, re := range r.regexes {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// specificity returns the length of the longest literal prefix of the paths.
+func (r *route) specificity() int {
+	longest := 0
+
+	for _, prefix := range r.prefixes {
+		if l := len(strings.TrimSuffix(prefix, "*")); l > longest {
+			longest = l
+		}
+	}
+
+	for _, re := range r.regexes {
+		// The anchor hides the literal prefix of the expression
+		unanchored, err := regexp.Compile(strings.TrimPrefix(re.String(), "^"))
+		if err != nil {
+			continue
+		}
+
+		if literal, _ := unanchored.LiteralPrefix(); len(literal) > longest {
+			longest = len(literal)
+		}
+	}
+
+	return longest
+}
+
+// String describes the route for the logs.
+func (r *route) String() string {
+	parts := []string{}
+
+	if len(r.prefixes) > 0 {
+		parts = append(parts, fmt.Sprintf("path starting with %v", r.prefixes))
+	}
+
+	if len(r.regexes) > 0 {
+		parts = append(parts, fmt.Sprintf("path matching %v", r.regexes))
+	}
+
+	if len(r.methods) > 0 {
+		parts = append(parts, fmt.Sprintf("method in %v", r.methods))
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// Matches returns true if the path and the method of the request correspond to the match section of the filter.
+func (f *Filter) Matches(req *http.Request) bool {
+	if f.route == nil {
+		return true
+	}
+
+	if len(f.route.methods) > 0 && !containsFold(f.route.methods, req.Method) {
+		f.log.WithField("method", req.Method).Debug("method not matched by this filter")
+
+		return false
+	}
+
+	if !f.route.matchPath(req.URL.Path) {
+		f.log.WithField("path", req.URL.Path).Debug("path not matched by this filter")
+
+		return false
+	}
+
+	return true
+}
+
+// RouteSpecificity returns the length of the longest literal prefix of the matched paths, 0 if the filter matches all
+// the paths. The filters with the same priority are tried from the most specific to the least one.
+func (f *Filter) RouteSpecificity() int {
+	if f.route == nil {
+		return 0
+	}
+
+	return f.route.specificity()
+}
@@ -0,0 +1,150 @@
+package filter
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseRouteConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Croute
+		want      *route
+		wantFatal bool
+	}{
+		{"no match", Croute{}, nil, false},
+		{
+			"prefixes and methods",
+			Croute{Paths: []string{"/api/", "/static*"}, Methods: []string{"get", "POST"}},
+			&route{prefixes: []string{"/api/", "/static*"}, methods: []string{"GET", "POST"}},
+			false,
+		},
+		{
+			"regex",
+			Croute{Paths: []string{"^/api/v[0-9]+/"}, Regex: true},
+			&route{regexes: []*regexp.Regexp{regexp.MustCompile("^/api/v[0-9]+/")}},
+			false,
+		},
+		{"regex without paths", Croute{Regex: true}, nil, true},
+		{"relative path", Croute{Paths: []string{"api/"}}, nil, true},
+		{"wrong regex", Croute{Paths: []string{"/api/(v1"}, Regex: true}, nil, true},
+		{"wrong method", Croute{Methods: []string{"GET POST"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseRouteConfig(log, tt.config)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Fatalf("parseRouteConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if !tt.wantFatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRouteConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   bool
+	}{
+		{"/api", "/api", true},
+		{"/api", "/api/books", true},
+		{"/api", "/apis", false},
+		{"/api/", "/api/books", true},
+		{"/api/", "/api", false},
+		{"/api*", "/apis", true},
+		{"/api/*", "/api/books", true},
+		{"/", "/books", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.path, func(t *testing.T) {
+			if got := matchPrefix(tt.prefix, tt.path); got != tt.want {
+				t.Errorf("matchPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		route  *route
+		method string
+		path   string
+		want   bool
+	}{
+		{"no route", nil, "DELETE", "/books", true},
+		{"prefix", &route{prefixes: []string{"/static/", "/api"}}, "GET", "/api/books", true},
+		{"other prefix", &route{prefixes: []string{"/static/", "/api"}}, "GET", "/apis/books", false},
+		{"regex", &route{regexes: []*regexp.Regexp{regexp.MustCompile("^/api/v[0-9]+/")}}, "GET", "/api/v2/books", true},
+		{"other regex", &route{regexes: []*regexp.Regexp{regexp.MustCompile("^/api/v[0-9]+/")}}, "GET", "/api/books", false},
+		{"method", &route{methods: []string{"GET", "HEAD"}}, "get", "/books", true},
+		{"other method", &route{methods: []string{"GET", "HEAD"}}, "POST", "/books", false},
+		{"path and method", &route{prefixes: []string{"/api/"}, methods: []string{"POST"}}, "POST", "/api/books", true},
+		{"path but not method", &route{prefixes: []string{"/api/"}, methods: []string{"POST"}}, "GET", "/api/books", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			f := &Filter{log: log, route: tt.route}
+
+			if got := f.Matches(httptest.NewRequest(tt.method, "http://localhost:8080"+tt.path, nil)); got != tt.want {
+				t.Errorf("Filter.Matches() = %v, want %v", got, tt.want)
+			}
+
+			if got := f.IsConditional(); got != (tt.route != nil) {
+				t.Errorf("Filter.IsConditional() = %v, want %v", got, tt.route != nil)
+			}
+		})
+	}
+}
+
+func TestFilter_RouteSpecificity(t *testing.T) {
+	tests := []struct {
+		name  string
+		route *route
+		want  int
+	}{
+		{"no route", nil, 0},
+		{"methods only", &route{methods: []string{"GET"}}, 0},
+		{"longest prefix", &route{prefixes: []string{"/api/", "/api/v2/*"}}, 8},
+		{"regex", &route{regexes: []*regexp.Regexp{regexp.MustCompile("^/api/v[0-9]+/")}}, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Filter{route: tt.route}
+
+			if got := f.RouteSpecificity(); got != tt.want {
+				t.Errorf("Filter.RouteSpecificity() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_printRouteInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log:   log,
+		kind:  TCP,
+		route: &route{prefixes: []string{"/api/"}, methods: []string{"GET", "POST"}},
+	}
+	f.startLog()
+
+	verifyLogged("Filter.startLog", []string{
+		"All requests",
+		"Only for the requests with path starting with [/api/] and method in [GET POST]",
+	}, hook, t)
+}
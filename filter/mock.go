@@ -5,7 +5,10 @@ import (
 	"net"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/marema31/villip/health"
 )
 
 // Mock provides a way to mimic a Filter object.
@@ -13,6 +16,8 @@ type Mock struct {
 	Position    int
 	Concerned   bool
 	Conditional bool
+	Path        string // Prefix of the paths matched by the mock, all if empty
+	Started     bool
 	reqBody     string
 	reqHeader   http.Header
 	resBody     string
@@ -51,6 +56,16 @@ func (m *Mock) IsConcerned(ip net.IP, h http.Header) bool {
 	return m.Concerned
 }
 
+// Matches mimics the Matches from Filter.
+func (m *Mock) Matches(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, m.Path)
+}
+
+// RouteSpecificity mimics the RouteSpecificity from Filter.
+func (m *Mock) RouteSpecificity() int {
+	return len(m.Path)
+}
+
 // IsConditional mimics the IsConditional from Filter.
 func (m *Mock) IsConditional() bool {
 	return m.Conditional
@@ -85,6 +100,21 @@ func (m *Mock) PrefixReplace(URL string) string {
 	return URL
 }
 
+// Start mimics the Start from Filter.
+func (m *Mock) Start() {
+	m.Started = true
+}
+
+// Stop mimics the Stop from Filter.
+func (m *Mock) Stop() {
+	m.Started = false
+}
+
+// Statuses mimics the Statuses from Filter, the mock gives its position.
+func (m *Mock) Statuses() map[string]health.StatusFunc {
+	return map[string]health.StatusFunc{"/mock": func() interface{} { return m.Position }}
+}
+
 // ServeTCP mimics the ServeTCP from Filter.
 func (m *Mock) ServeTCP() error {
 	return nil
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Response headers containing URLs rewritten by the automatic rebasing.
+var rebasedResponseHeaders = []string{ //nolint: gochecknoglobals
+	"Location",
+	"Content-Location",
+	"Refresh",
+	"Link",
+	"Content-Security-Policy",
+	"Access-Control-Allow-Origin",
+}
+
+// Request headers containing URLs rewritten by the automatic rebasing.
+var rebasedRequestHeaders = []string{"Origin", "Referer"} //nolint: gochecknoglobals
+
+// rebaseVariants returns the rules replacing the from base URL by the to base URL in all the forms they can take:
+// absolute, scheme-relative, JSON-escaped and URL-encoded.
+func rebaseVariants(from string, to string) []replaceParameters {
+	from = strings.TrimSuffix(from, "/")
+	to = strings.TrimSuffix(to, "/")
+
+	if from == to {
+		return nil
+	}
+
+	_, fromHost, _ := strings.Cut(from, "://")
+	_, toHost, _ := strings.Cut(to, "://")
+
+	jsonEscape := func(s string) string { return strings.ReplaceAll(s, "/", `\/`) }
+	lowerEscape := func(s string) string {
+		return strings.NewReplacer("%3A", "%3a", "%2F", "%2f").Replace(url.QueryEscape(s))
+	}
+
+	return []replaceParameters{
+		{from: from, to: to},
+		{from: "//" + fromHost, to: "//" + toHost},
+		{from: jsonEscape(from), to: jsonEscape(to)},
+		{from: jsonEscape("//" + fromHost), to: jsonEscape("//" + toHost)},
+		{from: url.QueryEscape(from), to: url.QueryEscape(to)},
+		{from: lowerEscape(from), to: lowerEscape(to)},
+	}
+}
+
+// publicBase returns the base URL used by the client, the configured one or the one deduced from the request.
+func (f *Filter) publicBase(r *http.Request) string {
+	if f.publicURL != "" {
+		return f.publicURL
+	}
+
+	data := newTemplateData(r)
+	if data.Request.Host == "" {
+		return ""
+	}
+
+	return data.Request.Scheme + "://" + data.Request.Host
+}
+
+// rebaseRules returns the rules rewriting the upstream URLs to the public ones for a response (or the opposite for
+// a request), nil if the automatic rebasing is disabled.
+func (f *Filter) rebaseRules(r *http.Request, response bool) []replaceParameters {
+	if !f.autoRebase {
+		return nil
+	}
+
+	public := f.publicBase(r)
+	if public == "" {
+		return nil
+	}
+
+	if response {
+		var rules []replaceParameters
+		for _, upstream := range f.upstreamURLs() {
+			rules = append(rules, rebaseVariants(upstream, public)...)
+		}
+
+		return rules
+	}
+
+	return rebaseVariants(public, f.upstreamURL(r))
+}
+
+// rebaseHeaders applies the rebasing rules on the values of the headers.
+func rebaseHeaders(log logrus.FieldLogger, parsedHeader http.Header, names []string, rules []replaceParameters) {
+	for _, name := range names {
+		for i, value := range parsedHeader[name] {
+			if rebased := do("", value, rules, false); rebased != value {
+				parsedHeader[name][i] = rebased
+				log.Debug(fmt.Sprintf("Rebase header %s from %s to %s", name, value, rebased))
+			}
+		}
+	}
+}
+
+// concatRules returns a new slice containing the rules of a followed by the rules of b.
+func concatRules(a []replaceParameters, b []replaceParameters) []replaceParameters {
+	if len(b) == 0 {
+		return a
+	}
+
+	result := make([]replaceParameters, 0, len(a)+len(b))
+
+	return append(append(result, a...), b...)
+}
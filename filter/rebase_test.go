@@ -0,0 +1,257 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_rebaseVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []replaceParameters
+	}{
+		{
+			"all variants",
+			"http://legacy:8080/",
+			"https://public.example.com",
+			[]replaceParameters{
+				{from: "http://legacy:8080", to: "https://public.example.com"},
+				{from: "//legacy:8080", to: "//public.example.com"},
+				{from: `http:\/\/legacy:8080`, to: `https:\/\/public.example.com`},
+				{from: `\/\/legacy:8080`, to: `\/\/public.example.com`},
+				{from: "http%3A%2F%2Flegacy%3A8080", to: "https%3A%2F%2Fpublic.example.com"},
+				{from: "http%3a%2f%2flegacy%3a8080", to: "https%3a%2f%2fpublic.example.com"},
+			},
+		},
+		{
+			"same base",
+			"http://legacy:8080/",
+			"http://legacy:8080",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebaseVariants(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rebaseVariants() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_rebaseRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		autoRebase bool
+		publicURL  string
+		header     http.Header
+		response   bool
+		want       []replaceParameters
+	}{
+		{
+			"disabled",
+			false,
+			"",
+			http.Header{},
+			true,
+			nil,
+		},
+		{
+			"configured public url",
+			true,
+			"https://public.example.com",
+			http.Header{},
+			true,
+			rebaseVariants("http://legacy:8080", "https://public.example.com"),
+		},
+		{
+			"host of the request",
+			true,
+			"",
+			http.Header{},
+			true,
+			rebaseVariants("http://legacy:8080", "http://villip:8081"),
+		},
+		{
+			"forwarded host and proto",
+			true,
+			"",
+			http.Header{"X-Forwarded-Host": []string{"public.example.com, villip:8081"}, "X-Forwarded-Proto": []string{"https"}},
+			true,
+			rebaseVariants("http://legacy:8080", "https://public.example.com"),
+		},
+		{
+			"request",
+			true,
+			"https://public.example.com",
+			http.Header{},
+			false,
+			rebaseVariants("https://public.example.com", "http://legacy:8080"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://villip:8081/index", nil)
+			req.Header = tt.header
+			req = withOriginalRequest(req)
+
+			f := &Filter{
+				url:        "http://legacy:8080",
+				autoRebase: tt.autoRebase,
+				publicURL:  tt.publicURL,
+				log:        logrus.New(),
+			}
+
+			if got := f.rebaseRules(req, tt.response); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter.rebaseRules() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rebaseHeaders(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	header := http.Header{
+		"Location": []string{"http://legacy:8080/login"},
+		"Link":     []string{"<//legacy:8080/a.css>; rel=preload", "<http://cdn/b.js>; rel=preload"},
+		"Server":   []string{"http://legacy:8080"},
+	}
+
+	rebaseHeaders(log, header, rebasedResponseHeaders, rebaseVariants("http://legacy:8080", "https://public"))
+
+	want := http.Header{
+		"Location": []string{"https://public/login"},
+		"Link":     []string{"<//public/a.css>; rel=preload", "<http://cdn/b.js>; rel=preload"},
+		"Server":   []string{"http://legacy:8080"},
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("rebaseHeaders() = %v, want %v", header, want)
+	}
+
+	verifyLogged(
+		"rebaseHeaders",
+		[]string{
+			"Rebase header Location from http://legacy:8080/login to https://public/login",
+			"Rebase header Link from <//legacy:8080/a.css>; rel=preload to <//public/a.css>; rel=preload",
+		},
+		hook,
+		t,
+	)
+}
+
+func Test_concatRules(t *testing.T) {
+	a := make([]replaceParameters, 1, 2)
+	a[0] = replaceParameters{from: "a", to: "b"}
+	b := []replaceParameters{{from: "c", to: "d"}}
+
+	got := concatRules(a, b)
+	if !reflect.DeepEqual(got, []replaceParameters{{from: "a", to: "b"}, {from: "c", to: "d"}}) {
+		t.Errorf("concatRules() = %#v", got)
+	}
+
+	// The spare capacity of a must not be used
+	if a[:2][1].from != "" {
+		t.Errorf("concatRules() modified its first argument")
+	}
+
+	if got := concatRules(a, nil); !reflect.DeepEqual(got, a) {
+		t.Errorf("concatRules() = %#v, want %#v", got, a)
+	}
+}
+
+func TestFilter_UpdateResponseRebase(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{"buffered", false},
+		{"streamed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+
+			req, _ := http.NewRequest("GET", "http://legacy:8080/index", nil)
+
+			body := encodeString(t, "gzip", `<a href="http://legacy:8080/a">a</a><script>var u = "http:\/\/legacy:8080\/api";</script>`)
+			r := http.Response{
+				Header: http.Header{
+					"Content-Type":     []string{"text/html"},
+					"Content-Encoding": []string{"gzip"},
+					"Content-Length":   []string{fmt.Sprint(len(body))},
+					"Location":         []string{"http://legacy:8080/login"},
+				},
+				StatusCode: http.StatusOK,
+				Request:    req,
+				Body:       io.NopCloser(bytes.NewReader(body)),
+			}
+
+			f := &Filter{
+				url:          "http://legacy:8080",
+				autoRebase:   true,
+				publicURL:    "https://public.example.com",
+				stream:       tt.stream,
+				response:     response{Replace: []replaceParameters{{from: "http://legacy:8080/a", to: "/b"}}},
+				contentTypes: []string{"text/html"},
+				log:          log,
+			}
+
+			if err := f.UpdateResponse(&r); err != nil {
+				t.Fatalf("Filter.UpdateResponse() error = %v", err)
+			}
+
+			decoded, err := newDecoder("gzip", r.Body)
+			if err != nil {
+				t.Fatalf("Filter.UpdateResponse() not gzipped: %v", err)
+			}
+
+			got, _ := io.ReadAll(decoded)
+			want := `<a href="/b">a</a><script>var u = "https:\/\/public.example.com\/api";</script>`
+
+			if string(got) != want {
+				t.Errorf("Filter.UpdateResponse() = %s, want %s", got, want)
+			}
+
+			if r.Header.Get("Location") != "https://public.example.com/login" {
+				t.Errorf("Filter.UpdateResponse() Location = %s", r.Header.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateRequestRebase(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	req, _ := http.NewRequest("POST", "http://villip:8081/form", bytes.NewBufferString("next=https%3A%2F%2Fpublic.example.com%2Fhome"))
+	req.Header.Set("Origin", "https://public.example.com")
+	req = withOriginalRequest(req)
+
+	f := &Filter{
+		url:        "http://legacy:8080",
+		autoRebase: true,
+		publicURL:  "https://public.example.com",
+		log:        log,
+	}
+
+	f.UpdateRequest(req)
+
+	got, _ := io.ReadAll(req.Body)
+	if string(got) != "next=http%3A%2F%2Flegacy%3A8080%2Fhome" {
+		t.Errorf("Filter.UpdateRequest() body = %s", got)
+	}
+
+	if req.Header.Get("Origin") != "http://legacy:8080" {
+		t.Errorf("Filter.UpdateRequest() Origin = %s", req.Header.Get("Origin"))
+	}
+}
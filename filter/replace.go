@@ -2,10 +2,10 @@ package filter
 
 import (
 	"bytes"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 
 	uuid "github.com/satori/go.uuid"
@@ -15,29 +15,39 @@ import (
 // Mockable function.
 var _do = do //nolint: gochecknoglobals
 
-func do(url string, s string, rep []replaceParameters, prefix bool) string {
-	for _, r := range rep {
-		if len(r.urls) != 0 {
-			found := false
+// isURLConcerned returns true if the url match one of the regular expressions or if there is none.
+func isURLConcerned(url string, urls []*regexp.Regexp) bool {
+	if len(urls) == 0 {
+		return true
+	}
 
-			for _, reg := range r.urls {
-				if reg.MatchString(url) {
-					found = true
+	for _, reg := range urls {
+		if reg.MatchString(url) {
+			return true
+		}
+	}
 
-					break
-				}
-			}
+	return false
+}
 
-			if !found {
-				continue
-			}
+func do(url string, s string, rep []replaceParameters, prefix bool) string {
+	for _, r := range rep {
+		if !isURLConcerned(url, r.urls) {
+			continue
 		}
 
-		if prefix {
+		switch {
+		case r.regex != nil && prefix:
+			if loc := r.regex.FindStringSubmatchIndex(s); loc != nil && loc[0] == 0 {
+				s = string(r.regex.ExpandString(nil, r.to, s, loc)) + s[loc[1]:]
+			}
+		case r.regex != nil:
+			s = r.regex.ReplaceAllString(s, r.to)
+		case prefix:
 			if strings.HasPrefix(s, r.from) {
 				s = r.to + s[len(r.from):]
 			}
-		} else {
+		default:
 			s = strings.ReplaceAll(s, r.from, r.to)
 		}
 	}
@@ -45,38 +55,59 @@ func do(url string, s string, rep []replaceParameters, prefix bool) string {
 	return s
 }
 
-func (f *Filter) headerReplace(log logrus.FieldLogger, parsedHeader http.Header, headerConfig []Cheader) {
+func (f *Filter) headerReplace(log logrus.FieldLogger, parsedHeader http.Header, headerConfig []headerRule) {
 	log.Debug("Checking if need to replace header")
 
 	for _, h := range headerConfig {
-		if h.Add {
-			if parsedHeader.Get(h.Name) == "" {
-				parsedHeader[h.Name] = []string{h.Value}
+		switch {
+		case h.delete:
+			deleteHeader(parsedHeader, h.name)
+			log.Debug(fmt.Sprintf("Delete header %s", h.name))
+
+			continue
+		case h.from != "":
+			rewriteHeader(log, parsedHeader, h)
+
+			if h.rename == "" {
+				continue
+			}
+		}
+
+		if h.rename != "" {
+			renameHeader(parsedHeader, h.name, h.rename)
+			log.Debug(fmt.Sprintf("Rename header %s to %s", h.name, h.rename))
+
+			continue
+		}
+
+		if h.add {
+			if parsedHeader.Get(h.name) == "" {
+				parsedHeader[h.name] = []string{h.value}
 			} else {
-				parsedHeader[h.Name] = append(parsedHeader[h.Name], h.Value)
+				parsedHeader[h.name] = append(parsedHeader[h.name], h.value)
 			}
 
-			log.Debug(fmt.Sprintf("Adding to header %s with value :  %s", h.Name, h.Value))
+			log.Debug(fmt.Sprintf("Adding to header %s with value :  %s", h.name, h.value))
 
 			continue
 		}
 
-		if parsedHeader.Get(h.Name) == "" || h.Force {
-			// parsedHeader.Set(h.Name, h.Value) // Use CanonicalMIMEHeaderKey that modify the key
-			value := h.Value
-			if h.UUID {
+		if parsedHeader.Get(h.name) == "" || h.force {
+			// parsedHeader.Set(h.name, h.value) // Use CanonicalMIMEHeaderKey that modify the key
+			value := h.value
+			if h.uuid {
 				value = uuid.NewV4().String()
 			}
 
-			parsedHeader[h.Name] = []string{value}
-			log.Debug(fmt.Sprintf("Set header %s with value :  %s", h.Name, value))
+			parsedHeader[h.name] = []string{value}
+			log.Debug(fmt.Sprintf("Set header %s with value :  %s", h.name, value))
 		}
 	}
 }
 
 func (f *Filter) readAndReplaceBody(
 	requestURL string,
-	rep []replaceParameters,
+	rules bodyRules,
 	bod io.ReadCloser,
 	parsedHeader http.Header,
 ) (int, io.ReadCloser, string, string, error) {
@@ -88,48 +119,74 @@ func (f *Filter) readAndReplaceBody(
 		err           error
 	)
 
-	switch parsedHeader.Get("Content-Encoding") {
-	case "gzip":
-		body, err = gzip.NewReader(bod)
-		if err != nil {
-			f.log.Errorf("Impossible to decompress: %v", err)
+	encoding := parsedHeader.Get("Content-Encoding")
 
-			return 0, nil, "", "", err
-		}
-		//		defer body.Close()
-	default:
-		body = bod
+	decoded, err := newDecoder(encoding, bod)
+	if err != nil {
+		f.log.Errorf("Impossible to decompress: %v", err)
+
+		return 0, nil, "", "", err
 	}
 
-	b, err := io.ReadAll(body)
+	b, err := io.ReadAll(decoded)
 	if err != nil {
 		return 0, nil, "", "", err
 	}
 
 	originalBody = string(b)
 
+	if !isKnownEncoding(encoding) {
+		f.log.WithField("encoding", encoding).Debug("Unsupported content encoding, body not filtered")
+
+		return len(b), io.NopCloser(bytes.NewReader(b)), originalBody, originalBody, nil
+	}
+
+	contentType := parsedHeader.Get("Content-Type")
+
+	enc, charsetName := bodyCharset(contentType, b)
+	if enc != nil {
+		f.log.WithField("charset", charsetName).Debug("Converting body to UTF-8")
+
+		if originalBody, err = enc.NewDecoder().String(originalBody); err != nil {
+			return 0, nil, "", "", err
+		}
+	}
+
 	f.log.WithField("requestURL", requestURL).Debug(fmt.Sprintf("Body before the replacement : %s", originalBody))
 
-	modifiedBody = _do(requestURL, originalBody, rep, false)
+	modifiedBody = _do(requestURL, originalBody, rules.replace, false)
+	modifiedBody = f.applyDictionaries(requestURL, rules.dictionaries, modifiedBody)
 
 	f.log.Debug(fmt.Sprintf("Body after the replacement : %s", modifiedBody))
 
-	switch parsedHeader.Get("Content-Encoding") {
-	case "gzip":
-		w, err := f.compress(modifiedBody)
-		if err != nil {
+	modifiedBody = f.rewriteHTML(requestURL, rules, modifiedBody, contentType)
+	modifiedBody = f.transformJSON(requestURL, rules.json, modifiedBody, contentType)
+	modifiedBody = f.transform(requestURL, rules.commands, modifiedBody, parsedHeader)
+
+	if len(rules.rebase) > 0 {
+		modifiedBody = _do(requestURL, modifiedBody, rules.rebase, false)
+		f.log.Debug(fmt.Sprintf("Body after the rebasing : %s", modifiedBody))
+	}
+
+	if len(rules.inject) > 0 && isHTML(contentType) {
+		modifiedBody = f.inject(requestURL, rules.inject, modifiedBody)
+	}
+
+	encodedBody := modifiedBody
+	if enc != nil {
+		if encodedBody, err = charsetEncoder(enc, contentType).String(modifiedBody); err != nil {
 			return 0, nil, "", "", err
 		}
+	}
 
-		body = io.NopCloser(w)
-		contentLength = w.Len()
-
-	default:
-		buf := bytes.NewBufferString(modifiedBody)
-		body = io.NopCloser(buf)
-		contentLength = buf.Len()
+	w, err := f.compress(encoding, encodedBody)
+	if err != nil {
+		return 0, nil, "", "", err
 	}
 
+	body = io.NopCloser(w)
+	contentLength = w.Len()
+
 	return contentLength, body, originalBody, modifiedBody, nil
 }
 
@@ -140,3 +197,56 @@ func (f *Filter) PrefixReplace(URL string) string {
 
 	return newURL
 }
+
+// headerValues returns the values of the header, looking for the name as is first and then for its canonical form.
+func headerValues(parsedHeader http.Header, name string) (string, []string) {
+	if values, ok := parsedHeader[name]; ok {
+		return name, values
+	}
+
+	canonical := http.CanonicalHeaderKey(name)
+
+	return canonical, parsedHeader[canonical]
+}
+
+// deleteHeader removes the header whatever the case used for its name.
+func deleteHeader(parsedHeader http.Header, name string) {
+	delete(parsedHeader, name)
+	parsedHeader.Del(name)
+}
+
+// rewriteHeader replaces the from value of the rule by the to value in all the values of the header.
+func rewriteHeader(log logrus.FieldLogger, parsedHeader http.Header, h headerRule) {
+	key, values := headerValues(parsedHeader, h.name)
+
+	for i, value := range values {
+		var rewritten string
+		if h.regex != nil {
+			rewritten = h.regex.ReplaceAllString(value, h.to)
+		} else {
+			rewritten = strings.ReplaceAll(value, h.from, h.to)
+		}
+
+		if rewritten != value {
+			values[i] = rewritten
+			log.Debug(fmt.Sprintf("Rewrite header %s from %s to %s", key, value, rewritten))
+		}
+	}
+}
+
+// renameHeader moves all the values of the header to the new name, they are appended to the values already present.
+func renameHeader(parsedHeader http.Header, name string, rename string) {
+	_, values := headerValues(parsedHeader, name)
+	if len(values) == 0 {
+		return
+	}
+
+	deleteHeader(parsedHeader, name)
+
+	key, existing := headerValues(parsedHeader, rename)
+	if len(existing) == 0 {
+		key = rename
+	}
+
+	parsedHeader[key] = append(existing, values...)
+}
@@ -210,6 +210,76 @@ func Test_do(t *testing.T) {
 			},
 			"/child/admin",
 		},
+		{
+			"regex replaced",
+			args{
+				"/youngster/admin",
+				"see http://legacy-books.corp/list and https://legacy-films.corp/",
+				[]replaceParameters{
+					{
+						from:  `https?://legacy-(\w+)\.corp`,
+						to:    "/svc/$1",
+						urls:  []*regexp.Regexp{},
+						regex: regexp.MustCompile(`https?://legacy-(\w+)\.corp`),
+					},
+				},
+				false,
+			},
+			"see /svc/books/list and /svc/films/",
+		},
+		{
+			"regex not replaced url",
+			args{
+				"/parent",
+				"see http://legacy-books.corp/list",
+				[]replaceParameters{
+					{
+						from: `https?://legacy-(\w+)\.corp`,
+						to:   "/svc/$1",
+						urls: []*regexp.Regexp{
+							regexp.MustCompile("^/youngster"),
+						},
+						regex: regexp.MustCompile(`https?://legacy-(\w+)\.corp`),
+					},
+				},
+				false,
+			},
+			"see http://legacy-books.corp/list",
+		},
+		{
+			"regex prefix replaced",
+			args{
+				"/v2/youngster/admin",
+				"/v2/youngster/admin",
+				[]replaceParameters{
+					{
+						from:  `/v(\d+)/`,
+						to:    "/api/$1/",
+						urls:  []*regexp.Regexp{},
+						regex: regexp.MustCompile(`/v(\d+)/`),
+					},
+				},
+				true,
+			},
+			"/api/2/youngster/admin",
+		},
+		{
+			"regex prefix not at beginning",
+			args{
+				"/youngster/v2/admin",
+				"/youngster/v2/admin",
+				[]replaceParameters{
+					{
+						from:  `/v(\d+)/`,
+						to:    "/api/$1/",
+						urls:  []*regexp.Regexp{},
+						regex: regexp.MustCompile(`/v(\d+)/`),
+					},
+				},
+				true,
+			},
+			"/youngster/v2/admin",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -223,7 +293,7 @@ func Test_do(t *testing.T) {
 func TestFilter_headerReplace(t *testing.T) {
 	type args struct {
 		parsedHeader http.Header
-		headerConfig []Cheader
+		headerConfig []headerRule
 	}
 	tests := []struct {
 		name    string
@@ -235,7 +305,7 @@ func TestFilter_headerReplace(t *testing.T) {
 			"empty",
 			args{
 				http.Header{},
-				[]Cheader{},
+				[]headerRule{},
 			},
 			http.Header{},
 			[]string{"Checking if need to replace header"},
@@ -247,7 +317,7 @@ func TestFilter_headerReplace(t *testing.T) {
 					"X-ENV":     []string{"dev"},
 					"X-Authors": []string{"alice", "bob"},
 				},
-				[]Cheader{},
+				[]headerRule{},
 			},
 			http.Header{
 				"X-ENV":     []string{"dev"},
@@ -262,11 +332,11 @@ func TestFilter_headerReplace(t *testing.T) {
 					"X-ENV":     []string{"dev"},
 					"X-Authors": []string{"alice", "bob"},
 				},
-				[]Cheader{
+				[]headerRule{
 					{
-						Name:  "X-Authors",
-						Value: "Charly",
-						Force: false,
+						name:  "X-Authors",
+						value: "Charly",
+						force: false,
 					},
 				},
 			},
@@ -283,24 +353,24 @@ func TestFilter_headerReplace(t *testing.T) {
 					"X-ENV":     []string{"dev"},
 					"X-Authors": []string{"alice", "bob"},
 				},
-				[]Cheader{
+				[]headerRule{
 					{
-						Name:  "X-Authors",
-						Value: "Charly",
-						Force: true,
+						name:  "X-Authors",
+						value: "Charly",
+						force: true,
 					},
 					{
-						Name:  "X-VERSION",
-						Value: "1.0",
+						name:  "X-VERSION",
+						value: "1.0",
 					},
 					{
-						Name:  "X-TIME",
-						Value: "123456",
-						Force: false,
+						name:  "X-TIME",
+						value: "123456",
+						force: false,
 					},
 					{
-						Name:  "X-ENV",
-						Value: "prod",
+						name:  "X-ENV",
+						value: "prod",
 					},
 				},
 			},
@@ -325,26 +395,26 @@ func TestFilter_headerReplace(t *testing.T) {
 					"X-ENV":     []string{"dev"},
 					"X-Authors": []string{"alice", "bob"},
 				},
-				[]Cheader{
+				[]headerRule{
 					{
-						Name:  "X-Authors",
-						Value: "Charly",
-						Add:   true,
+						name:  "X-Authors",
+						value: "Charly",
+						add:   true,
 					},
 					{
-						Name:  "X-VERSION",
-						Value: "1.0",
-						Add:   true,
+						name:  "X-VERSION",
+						value: "1.0",
+						add:   true,
 					},
 					{
-						Name:  "X-TIME",
-						Value: "123456",
-						Force: false,
+						name:  "X-TIME",
+						value: "123456",
+						force: false,
 					},
 					{
-						Name:  "X-ENV",
-						Value: "prod",
-						Add:   false,
+						name:  "X-ENV",
+						value: "prod",
+						add:   false,
 					},
 				},
 			},
@@ -362,6 +432,80 @@ func TestFilter_headerReplace(t *testing.T) {
 				"Set header X-ENV with value :  prod",
 			},
 		},
+		{
+			"delete",
+			args{
+				http.Header{
+					"X-Powered-By": []string{"PHP/5.6"},
+					"Server":       []string{"legacy"},
+				},
+				[]headerRule{
+					{name: "x-powered-by", delete: true},
+					{name: "X-Missing", delete: true},
+				},
+			},
+			http.Header{
+				"Server": []string{"legacy"},
+			},
+			[]string{
+				"Checking if need to replace header",
+				"Delete header x-powered-by",
+				"Delete header X-Missing",
+			},
+		},
+		{
+			"rewrite values",
+			args{
+				http.Header{
+					"Content-Security-Policy":     []string{"default-src 'self' http://legacy:8080"},
+					"Link":                        []string{"<http://legacy:8080/a.css>; rel=preload", "<http://cdn/b.js>; rel=preload"},
+					"Access-Control-Allow-Origin": []string{"http://legacy:8080"},
+				},
+				[]headerRule{
+					{name: "Content-Security-Policy", from: "http://legacy:8080", to: "https://public"},
+					{name: "Link", from: "<http://legacy:8080/", to: "</app/"},
+					{name: "Access-Control-Allow-Origin", from: `^http://legacy:(\d+)$`, to: "https://public:$1", regex: regexp.MustCompile(`^http://legacy:(\d+)$`)},
+					{name: "Refresh", from: "http://legacy:8080", to: "https://public"},
+				},
+			},
+			http.Header{
+				"Content-Security-Policy":     []string{"default-src 'self' https://public"},
+				"Link":                        []string{"</app/a.css>; rel=preload", "<http://cdn/b.js>; rel=preload"},
+				"Access-Control-Allow-Origin": []string{"https://public:8080"},
+			},
+			[]string{
+				"Checking if need to replace header",
+				"Rewrite header Content-Security-Policy from default-src 'self' http://legacy:8080 to default-src 'self' https://public",
+				"Rewrite header Link from <http://legacy:8080/a.css>; rel=preload to </app/a.css>; rel=preload",
+				"Rewrite header Access-Control-Allow-Origin from http://legacy:8080 to https://public:8080",
+			},
+		},
+		{
+			"rename",
+			args{
+				http.Header{
+					"X-Old":      []string{"a", "b"},
+					"X-Existing": []string{"c"},
+					"X-Url":      []string{"http://legacy/"},
+				},
+				[]headerRule{
+					{name: "X-Old", rename: "X-New"},
+					{name: "x-url", rename: "X-Existing", from: "legacy", to: "public"},
+					{name: "X-Missing", rename: "X-Other"},
+				},
+			},
+			http.Header{
+				"X-New":      []string{"a", "b"},
+				"X-Existing": []string{"c", "http://public/"},
+			},
+			[]string{
+				"Checking if need to replace header",
+				"Rename header X-Old to X-New",
+				"Rewrite header X-Url from http://legacy/ to http://public/",
+				"Rename header x-url to X-Existing",
+				"Rename header X-Missing to X-Other",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -459,6 +603,51 @@ func TestFilter_readAndReplaceBody(t *testing.T) {
 			false,
 			[]string{"Body before the replacement : hello world", "Body after the replacement : dlrow olleh. hello earth"},
 		},
+		{
+			"brotli",
+			args{
+				ioutil.NopCloser(bytes.NewReader(encodeString(t, "br", "hello world"))),
+				"dlrow olleh. hello earth",
+				http.Header{
+					"Content-Encoding": []string{"br"},
+				},
+			},
+			0,
+			nil,
+			"hello world",
+			false,
+			[]string{"Body before the replacement : hello world", "Body after the replacement : dlrow olleh. hello earth"},
+		},
+		{
+			"zstd",
+			args{
+				ioutil.NopCloser(bytes.NewReader(encodeString(t, "zstd", "hello world"))),
+				"dlrow olleh. hello earth",
+				http.Header{
+					"Content-Encoding": []string{"zstd"},
+				},
+			},
+			0,
+			nil,
+			"hello world",
+			false,
+			[]string{"Body before the replacement : hello world", "Body after the replacement : dlrow olleh. hello earth"},
+		},
+		{
+			"unknown encoding",
+			args{
+				ioutil.NopCloser(strings.NewReader("hello world")),
+				"hello world",
+				http.Header{
+					"Content-Encoding": []string{"compress"},
+				},
+			},
+			11,
+			nil,
+			"hello world",
+			false,
+			[]string{"Unsupported content encoding, body not filtered"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -474,18 +663,34 @@ func TestFilter_readAndReplaceBody(t *testing.T) {
 			}
 			defer func() { _do = oldDo }()
 
-			got, got1, got2, got3, err := f.readAndReplaceBody("", []replaceParameters{}, tt.args.bod, tt.args.parsedHeader)
+			got, got1, got2, got3, err := f.readAndReplaceBody("", bodyRules{}, tt.args.bod, tt.args.parsedHeader)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Filter.readAndReplaceBody() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if got != tt.want {
-				t.Errorf("Filter.readAndReplaceBody() got = %v, want %v", got, tt.want)
-			}
-			if !reflect.DeepEqual(got1, tt.want1) {
-				t.Errorf("Filter.readAndReplaceBody() got1 = %v, want %#v", got1, tt.want1)
+			if encoding := tt.args.parsedHeader.Get("Content-Encoding"); encoding != "" && got1 != nil {
+				// The compressed bytes depend on the compressor version, compare the content instead
+				compressed, _ := io.ReadAll(got1)
+				if got != len(compressed) {
+					t.Errorf("Filter.readAndReplaceBody() got = %v, want %v", got, len(compressed))
+				}
+				decoded, err := newDecoder(encoding, bytes.NewReader(compressed))
+				if err != nil {
+					t.Fatalf("Filter.readAndReplaceBody() got1 is not %s encoded: %v", encoding, err)
+				}
+				content, _ := io.ReadAll(decoded)
+				if string(content) != tt.args.newbod {
+					t.Errorf("Filter.readAndReplaceBody() got1 = %v, want %v", string(content), tt.args.newbod)
+				}
+			} else {
+				if got != tt.want {
+					t.Errorf("Filter.readAndReplaceBody() got = %v, want %v", got, tt.want)
+				}
+				if !reflect.DeepEqual(got1, tt.want1) {
+					t.Errorf("Filter.readAndReplaceBody() got1 = %v, want %#v", got1, tt.want1)
+				}
 			}
 			if got2 != tt.want2 {
 				t.Errorf("Filter.readAndReplaceBody() got2 = %v, want %v", got2, tt.want2)
@@ -3,6 +3,7 @@ package filter
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -21,9 +22,12 @@ func (f *Filter) UpdateRequest(r *http.Request) {
 
 	requestLog := f.log.WithFields(logrus.Fields{"url": r.URL.String(), "action": "request", "source": r.RemoteAddr})
 
-	requestURL := strings.TrimPrefix(r.URL.String(), f.url)
+	requestURL := strings.TrimPrefix(r.URL.String(), f.upstreamURL(r))
+	tmplData := newTemplateData(r)
+	rebase := f.rebaseRules(r, false)
+	ruleCtx := newRuleContext(r, nil)
 
-	u, _ := url.Parse(f.url)
+	u, _ := url.Parse(f.upstreamURL(r))
 	r.URL.Host = u.Host
 	r.Host = u.Host
 	r.URL.Scheme = u.Scheme
@@ -35,18 +39,41 @@ func (f *Filter) UpdateRequest(r *http.Request) {
 
 	requestLog.Debug(fmt.Sprintf("Request received\n%s", string(bytes.ReplaceAll(data, []byte{13, 10}, []byte{10}))))
 
-	// in request sometimes there is no body
-	if r.Body != nil {
+	// in request sometimes there is no body, the body of an upgrade request is left untouched
+	if r.Body != nil && !isWebSocketUpgrade(r) {
+		raw, err := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+
+		if err != nil {
+			// The upstream request fails on the same error and the client receives a Bad Gateway
+			requestLog.Errorf("Impossible to read the request body: %v", err)
+
+			r.Body = io.NopCloser(&errReader{err: err})
+
+			return
+		}
+
 		contentLength, r.Body, originalBody, modifiedBody, err =
-			f.readAndReplaceBody(requestURL, f.request.Replace, r.Body, r.Header)
+			f.readAndReplaceBody(requestURL, bodyRules{
+				replace: f.expandReplace(requestURL, selectReplace(f.request.Replace, ruleCtx), tmplData),
+				json:    selectJSON(f.request.JSON, ruleCtx),
+				rebase:  rebase,
+
+				dictionaries: selectDictionaries(f.request.Dictionary, ruleCtx),
+				commands:     selectCommands(f.request.Transform, ruleCtx),
+			}, io.NopCloser(bytes.NewReader(raw)), r.Header)
 
 		if err != nil {
-			requestLog.Fatal(err)
+			// A body sent by the client that cannot be decoded is forwarded as is
+			requestLog.Warnf("Request body not filtered: %v", err)
+
+			contentLength, r.Body = len(raw), io.NopCloser(bytes.NewReader(raw))
+			originalBody, modifiedBody = string(raw), string(raw)
 		}
 
 		requestID := ""
 		if f.dumpFolder != "" || len(f.dumpURLs) != 0 {
-			requestID = f.dumpHTTPMessage(requestID, "", requestURL, r.Header, originalBody)
+			requestID = f.dumpHTTPMessage(requestID, "", requestURL, dumpedRequestHeader(r), originalBody)
 			r.Header.Set("X-VILLIP-Request-ID", requestID)
 		}
 
@@ -57,11 +84,41 @@ func (f *Filter) UpdateRequest(r *http.Request) {
 		r.ContentLength = int64(contentLength)
 
 		if requestID != "" {
-			f.dumpHTTPMessage(requestID, "", requestURL, r.Header, modifiedBody)
+			f.dumpHTTPMessage(requestID, "", requestURL, dumpedRequestHeader(r), modifiedBody)
 		}
 	}
 
+	if f.response.Cookies != nil && len(f.response.Cookies.name) > 0 {
+		f.rewriteCookieHeader(requestLog, r, requestURL)
+	}
+
 	if len(f.request.Header) > 0 {
-		f.headerReplace(requestLog, r.Header, f.request.Header)
+		f.headerReplace(requestLog, r.Header, f.expandHeaders(requestURL, selectHeaders(requestURL, f.request.Header, ruleCtx), tmplData))
 	}
+
+	rebaseHeaders(requestLog, r.Header, rebasedRequestHeaders, rebase)
+	f.transformRequest(requestLog, r)
+}
+
+// dumpedRequestHeader returns the header of the request as dumped, with the URL requested by the client if it has
+// been rewritten.
+func dumpedRequestHeader(r *http.Request) http.Header {
+	from := rewrittenFrom(r)
+	if from == "" {
+		return r.Header
+	}
+
+	header := r.Header.Clone()
+	header.Set("X-VILLIP-Rewritten-From", from)
+
+	return header
+}
+
+// errReader returns the same error on every read.
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
 }
@@ -97,13 +97,13 @@ func TestFilter_UpdateRequest(t *testing.T) {
 			"replace content",
 			fields{
 				request{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{},
+					Header: []headerRule{},
 				},
 				[]*regexp.Regexp{regexp.MustCompile("/youngster")},
 			},
@@ -142,16 +142,16 @@ func TestFilter_UpdateRequest(t *testing.T) {
 			"replace header",
 			fields{
 				request{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -220,3 +220,83 @@ func TestFilter_UpdateRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestFilter_UpdateRequestHeaderUrls(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"admin", "http://localhost:8081/admin/users", "secret"},
+		{"other", "http://localhost:8081/public/users", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+
+			req, _ := http.NewRequest("GET", tt.url, nil)
+
+			f := &Filter{
+				url: "http://localhost:8081",
+				request: request{
+					Header: []headerRule{
+						{name: "Authorization", value: "secret", urls: []*regexp.Regexp{regexp.MustCompile("^/admin/")}},
+					},
+				},
+				log: log,
+			}
+
+			f.UpdateRequest(req)
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Filter.UpdateRequest() Authorization = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateRequestUndecodableBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     string
+	}{
+		{"empty deflate", "deflate", ""},
+		{"empty gzip", "gzip", ""},
+		{"corrupted gzip", "gzip", "not compressed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			f := &Filter{
+				url: "http://localhost:8081",
+				request: request{
+					Replace: []replaceParameters{{from: "compressed", to: "replaced"}},
+				},
+				log: log,
+			}
+
+			r, _ := http.NewRequest("POST", "http://localhost:8081/upload", strings.NewReader(tt.body))
+			r.Header.Set("Content-Encoding", tt.encoding)
+
+			f.UpdateRequest(r)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) {
+				t.Errorf("Filter.UpdateRequest() stopped the proxy on an undecodable body")
+			}
+			if !HadErrorLevel(hook, logrus.WarnLevel) {
+				t.Errorf("Filter.UpdateRequest() did not warn about the undecodable body")
+			}
+
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != tt.body {
+				t.Errorf("Filter.UpdateRequest() got = %v, want %v", string(body), tt.body)
+			}
+			if r.ContentLength != int64(len(tt.body)) {
+				t.Errorf("Filter.UpdateRequest() ContentLength = %d, want %d", r.ContentLength, len(tt.body))
+			}
+		})
+	}
+}
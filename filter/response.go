@@ -2,7 +2,6 @@ package filter
 
 import (
 	"bytes"
-	"compress/gzip"
 	"fmt"
 	"net/http"
 	"strings"
@@ -27,17 +26,76 @@ func (f *Filter) UpdateResponse(r *http.Response) error {
 			"source": r.Request.RemoteAddr,
 		})
 	// The Request in the Response is the last URL the client tried to access.
-	requestURL := strings.TrimPrefix(r.Request.URL.String(), f.url)
+	requestURL := strings.TrimPrefix(r.Request.URL.String(), f.upstreamURL(r.Request))
+	tmplData := newTemplateData(r.Request)
 
-	if !f.force && !f.toFilter(requestLog, r) {
-		return nil
+	// Cookies and redirections are rewritten whatever the content type or the status to keep the sessions working
+	if f.response.Cookies != nil || f.hasReversePrefix() {
+		f.rewriteSetCookies(requestLog, r, requestURL)
 	}
 
-	if r.Body != nil {
+	if f.hasReversePrefix() {
+		f.reverseResponseHeaders(requestLog, r)
+	}
+
+	rebase := f.rebaseRules(r.Request, true)
+
+	// The body of a switching protocols response is the upgraded connection
+	if r.StatusCode == http.StatusSwitchingProtocols || (!f.force && !f.toFilter(requestLog, r)) {
+		rebaseHeaders(requestLog, r.Header, rebasedResponseHeaders, rebase)
+
+		return f.transformResponse(requestLog, r)
+	}
+
+	ruleCtx := newRuleContext(r.Request, r)
+	rules := bodyRules{
+		replace: f.expandReplace(requestURL, selectReplace(f.response.Replace, ruleCtx), tmplData),
+		json:    selectJSON(f.response.JSON, ruleCtx),
+		html:    f.expandReplace(requestURL, selectReplace(f.response.HTML, ruleCtx), tmplData),
+		links:   f.reverseLinks(r.Request),
+		rebase:  rebase,
+
+		dictionaries: selectDictionaries(f.response.Dictionary, ruleCtx),
+		inject:       f.expandInjections(selectInjections(f.response.Inject, ruleCtx), tmplData),
+		commands:     selectCommands(f.response.Transform, ruleCtx),
+	}
+
+	if mode := streamMode(r.Header.Get("Content-Type")); r.Body != nil && mode != "" {
+		requestLog.Debug("filtering the stream")
+
+		if ignored := ignoredStreamRules(requestURL, rules); len(ignored) > 0 {
+			requestLog.WithField("rules", ignored).Debug("rules not applied on the streamed events")
+		}
+
+		r.Body, err = f.streamEvents(requestURL, rules, mode, r.Body, r.Header)
+		if err != nil {
+			return err
+		}
+
+		f.location(requestLog, r, requestURL)
+
+		// The length is unknown, the reverse proxy flushes each unit to the client as soon as it is filtered
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+	} else if r.Body != nil && f.isResponseStreamable(requestURL, r.Header.Get("Content-Type"), rules) {
+		requestLog.Debug("filtering")
+
+		rep := concatRules(rules.replace, rules.rebase)
+
+		r.Body, err = f.streamAndReplaceBody(requestURL, rep, r.Body, r.Header)
+		if err != nil {
+			return err
+		}
+
+		f.location(requestLog, r, requestURL)
+		// The length is unknown, the response will use chunked transfer encoding
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+	} else if r.Body != nil {
 		requestLog.Debug("filtering")
 
 		contentLength, r.Body, originalBody, modifiedBody, err =
-			f.readAndReplaceBody(requestURL, f.response.Replace, r.Body, r.Header)
+			f.readAndReplaceBody(requestURL, rules, r.Body, r.Header)
 
 		if err != nil {
 			return err
@@ -67,10 +125,33 @@ func (f *Filter) UpdateResponse(r *http.Response) error {
 	}
 
 	if len(f.response.Header) > 0 {
-		f.headerReplace(requestLog, r.Header, f.response.Header)
+		f.headerReplace(requestLog, r.Header, f.expandHeaders(requestURL, selectHeaders(requestURL, f.response.Header, ruleCtx), tmplData))
+	}
+
+	rebaseHeaders(requestLog, r.Header, rebasedResponseHeaders, rebase)
+
+	return f.transformResponse(requestLog, r)
+}
+
+// isResponseStreamable returns true if the response body can be filtered without buffering it.
+func (f *Filter) isResponseStreamable(requestURL string, contentType string, rules bodyRules) bool {
+	if isJSON(contentType) && hasJSONRule(requestURL, rules.json) {
+		return false
 	}
 
-	return nil
+	if isHTML(contentType) && (rules.links != nil || hasReplaceRule(requestURL, rules.html)) {
+		return false
+	}
+
+	if isHTML(contentType) && hasInjection(requestURL, rules.inject) {
+		return false
+	}
+
+	if hasDictionary(requestURL, rules.dictionaries) || hasCommand(requestURL, rules.commands) {
+		return false
+	}
+
+	return f.stream && f.dumpFolder == "" && len(f.dumpURLs) == 0 && isStreamable(requestURL, rules.replace)
 }
 
 func (f *Filter) toFilter(log logrus.FieldLogger, r *http.Response) bool {
@@ -99,17 +180,16 @@ func (f *Filter) toFilter(log logrus.FieldLogger, r *http.Response) bool {
 	return false
 }
 
-func (f *Filter) compress(s string) (*bytes.Buffer, error) {
+// compress encodes the body with the given content encoding, the body is copied as is for identity.
+func (f *Filter) compress(encoding string, s string) (*bytes.Buffer, error) {
 	var w bytes.Buffer
 
-	compressed := gzip.NewWriter(&w)
-
-	_, err := compressed.Write([]byte(s))
+	compressed, err := newEncoder(encoding, &w)
 	if err != nil {
 		return nil, err
 	}
 
-	err = compressed.Flush()
+	_, err = compressed.Write([]byte(s))
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +207,8 @@ func (f *Filter) location(requestLog logrus.FieldLogger, r *http.Response, reque
 
 	if location != "" {
 		origLocation := location
-		location = do(requestURL, location, f.response.Replace, false)
+		rep := selectReplace(f.response.Replace, newRuleContext(r.Request, r))
+		location = do(requestURL, location, f.expandReplace(requestURL, rep, newTemplateData(r.Request)), false)
 
 		requestLog.
 			WithFields(logrus.Fields{"location": origLocation, "rewrited_location": location}).
@@ -38,16 +38,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -114,16 +114,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -155,16 +155,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				true,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -202,16 +202,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -249,16 +249,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -306,16 +306,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -367,16 +367,16 @@ func TestFilter_UpdateResponse(t *testing.T) {
 			fields{
 				false,
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -611,14 +611,14 @@ func TestFilter_location(t *testing.T) {
 			"change location",
 			fields{
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "example",
 							to:   "test",
 							urls: []*regexp.Regexp{},
 						},
 					},
-					[]Cheader{},
+					Header: []headerRule{},
 				},
 			},
 			args{
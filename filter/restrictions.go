@@ -88,5 +88,5 @@ func (f *Filter) isAccepted(parsedHeader http.Header) bool {
 
 // IsConditional returns true if the filter has conditions.
 func (f *Filter) IsConditional() bool {
-	return len(f.restricted) != 0 || len(f.token) != 0
+	return len(f.restricted) != 0 || len(f.token) != 0 || f.route != nil
 }
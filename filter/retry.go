@@ -0,0 +1,217 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Default values of the retries.
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 2 * time.Second
+)
+
+// Maximum size of a response body read before retrying to keep the connection reusable.
+const retryDiscardLimit = 64 * 1024
+
+// Methods of the requests that can be sent again without side effect (RFC 9110).
+var idempotentMethods = map[string]bool{ //nolint: gochecknoglobals
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retryPolicy is the configuration of the retries of the requests to the upstream.
+type retryPolicy struct {
+	attempts   int // Including the first one
+	backoff    time.Duration
+	maxBackoff time.Duration
+	status     []int // Status of the responses retried in addition to the connection errors
+}
+
+// parseRetryConfig verifies the retries configuration, it returns nil if the requests must not be retried.
+func parseRetryConfig(log logrus.FieldLogger, c Cretry) *retryPolicy {
+	if c.Attempts < 0 {
+		log.Fatalf("%d is not a valid number of attempts", c.Attempts)
+	}
+
+	if c.Attempts <= 1 {
+		if c.Backoff != "" || c.MaxBackoff != "" || len(c.Status) > 0 {
+			log.Fatal("The retry configuration needs at least 2 attempts")
+		}
+
+		return nil
+	}
+
+	p := &retryPolicy{
+		attempts:   c.Attempts,
+		backoff:    defaultRetryBackoff,
+		maxBackoff: defaultRetryMaxBackoff,
+		status:     c.Status,
+	}
+
+	var err error
+
+	if c.Backoff != "" {
+		if p.backoff, err = time.ParseDuration(c.Backoff); err != nil || p.backoff < 0 {
+			log.Fatalf("%s is not a valid retry backoff", c.Backoff)
+		}
+	}
+
+	if c.MaxBackoff != "" {
+		if p.maxBackoff, err = time.ParseDuration(c.MaxBackoff); err != nil || p.maxBackoff < 0 {
+			log.Fatalf("%s is not a valid retry maximum backoff", c.MaxBackoff)
+		}
+	}
+
+	for _, s := range c.Status {
+		if s < 100 || s > 599 {
+			log.Fatalf("%d is not a valid retry status", s)
+		}
+	}
+
+	return p
+}
+
+// delay returns the wait before the attempt, doubled at each retry.
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+
+	for i := 2; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > p.maxBackoff {
+		return p.maxBackoff
+	}
+
+	return d
+}
+
+// retryStatus returns true if a response with this status must be retried.
+func (p *retryPolicy) retryStatus(status int) bool {
+	for _, s := range p.status {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryTransport sends again the idempotent requests failing with a connection error or a configured status.
+type retryTransport struct {
+	http.RoundTripper
+	policy *retryPolicy
+	log    logrus.FieldLogger
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotentMethods[req.Method] {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	// The body, already modified by the rules of the request, is kept to be sent again
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log := t.log.WithFields(logrus.Fields{"url": req.URL.String(), "action": "retry", "source": req.RemoteAddr})
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if body != nil {
+			r = req.Clone(req.Context())
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		}
+
+		res, err := t.RoundTripper.RoundTrip(r)
+
+		var reason string
+
+		switch {
+		case err != nil && req.Context().Err() == nil && !errors.Is(err, errCircuitOpen):
+			reason = err.Error()
+		case err == nil && t.policy.retryStatus(res.StatusCode):
+			reason = fmt.Sprintf("status %d", res.StatusCode)
+		}
+
+		switch {
+		case reason == "":
+			if attempt > 1 {
+				log.WithField("attempt", attempt).Infof("Upstream request succeeded at attempt %d of %d", attempt, t.policy.attempts)
+			}
+
+			return res, err
+		case attempt >= t.policy.attempts:
+			log.WithField("attempt", attempt).Errorf("Upstream request failed after %d attempts (%s)", attempt, reason)
+
+			return res, err
+		}
+
+		if res != nil {
+			_, _ = io.CopyN(io.Discard, res.Body, retryDiscardLimit)
+			res.Body.Close()
+		}
+
+		delay := t.policy.delay(attempt + 1)
+		log.WithField("attempt", attempt).
+			Warnf("Upstream request failed at attempt %d of %d (%s), retrying in %s", attempt, t.policy.attempts, reason, delay)
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext waits for the duration, it returns an error if the context is canceled before.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// proxyError answers to the client when the upstream cannot be reached, with a 504 Gateway Timeout if the timeout of
+// the filter is exceeded.
+func (f *Filter) proxyError(res http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, errCircuitOpen) {
+		f.log.WithFields(logrus.Fields{"url": req.URL.String(), "source": req.RemoteAddr}).Debug("Circuit open, failing fast")
+		f.circuitOpenError(res)
+
+		return
+	}
+
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	f.log.WithFields(logrus.Fields{"url": req.URL.String(), "source": req.RemoteAddr}).Errorf("Proxy error: %v", err)
+	res.WriteHeader(status)
+}
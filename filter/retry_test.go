@@ -0,0 +1,296 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseRetryConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Cretry
+		want      *retryPolicy
+		wantFatal bool
+	}{
+		{"no retry", Cretry{}, nil, false},
+		{"single attempt", Cretry{Attempts: 1}, nil, false},
+		{
+			"defaults",
+			Cretry{Attempts: 3},
+			&retryPolicy{attempts: 3, backoff: 100 * time.Millisecond, maxBackoff: 2 * time.Second},
+			false,
+		},
+		{
+			"configured",
+			Cretry{Attempts: 5, Backoff: "10ms", MaxBackoff: "1s", Status: []int{502, 503}},
+			&retryPolicy{attempts: 5, backoff: 10 * time.Millisecond, maxBackoff: time.Second, status: []int{502, 503}},
+			false,
+		},
+		{"negative attempts", Cretry{Attempts: -1}, nil, true},
+		{"status without attempts", Cretry{Status: []int{503}}, nil, true},
+		{"wrong backoff", Cretry{Attempts: 2, Backoff: "10"}, nil, true},
+		{"wrong maximum backoff", Cretry{Attempts: 2, MaxBackoff: "-1s"}, nil, true},
+		{"wrong status", Cretry{Attempts: 2, Status: []int{50}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseRetryConfig(log, tt.config)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Fatalf("parseRetryConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if !tt.wantFatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRetryConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_delay(t *testing.T) {
+	p := &retryPolicy{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.delay(i + 2); got != w {
+			t.Errorf("retryPolicy.delay(%d) = %s, want %s", i+2, got, w)
+		}
+	}
+}
+
+// roundTripFunc answers to the requests with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (rt roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt(r)
+}
+
+var errConnectionRefused = errors.New("connection refused")
+
+func Test_retryTransport_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		results    []int // Status of the successive attempts, 0 for a connection error
+		wantStatus int
+		wantErr    bool
+		wantLog    []string
+	}{
+		{
+			"success",
+			http.MethodGet,
+			[]int{200},
+			200,
+			false,
+			[]string{},
+		},
+		{
+			"connection error",
+			http.MethodPut,
+			[]int{0, 200},
+			200,
+			false,
+			[]string{
+				"Upstream request failed at attempt 1 of 3 (connection refused), retrying in 1ms",
+				"Upstream request succeeded at attempt 2 of 3",
+			},
+		},
+		{
+			"retried status",
+			http.MethodGet,
+			[]int{503, 503, 503},
+			503,
+			false,
+			[]string{
+				"Upstream request failed at attempt 1 of 3 (status 503), retrying in 1ms",
+				"Upstream request failed at attempt 2 of 3 (status 503), retrying in 2ms",
+				"Upstream request failed after 3 attempts (status 503)",
+			},
+		},
+		{
+			"other status",
+			http.MethodGet,
+			[]int{500},
+			500,
+			false,
+			[]string{},
+		},
+		{
+			"not idempotent",
+			http.MethodPost,
+			[]int{0},
+			0,
+			true,
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+
+			bodies := []string{}
+			attempt := 0
+
+			rt := &retryTransport{
+				RoundTripper: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(r.Body)
+					bodies = append(bodies, string(body))
+
+					status := tt.results[attempt]
+					attempt++
+
+					if status == 0 {
+						return nil, errConnectionRefused
+					}
+
+					return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("answer"))}, nil
+				}),
+				policy: &retryPolicy{attempts: 3, backoff: time.Millisecond, maxBackoff: time.Second, status: []int{503}},
+				log:    log,
+			}
+
+			req := httptest.NewRequest(tt.method, "http://app1/books", strings.NewReader("a novel"))
+
+			res, err := rt.RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retryTransport.RoundTrip() error = %v", err)
+			}
+
+			if res != nil && res.StatusCode != tt.wantStatus {
+				t.Errorf("retryTransport.RoundTrip() status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+
+			// The body is sent again at each attempt
+			for _, body := range bodies {
+				if body != "a novel" {
+					t.Errorf("retryTransport.RoundTrip() sent the bodies %q", bodies)
+				}
+			}
+
+			if attempt != len(tt.results) {
+				t.Errorf("retryTransport.RoundTrip() made %d attempts, want %d", attempt, len(tt.results))
+			}
+
+			verifyLogged("retryTransport.RoundTrip", tt.wantLog, hook, t)
+		})
+	}
+}
+
+func Test_retryTransport_RoundTripCanceled(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	attempts := 0
+	rt := &retryTransport{
+		RoundTripper: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+
+			return nil, errConnectionRefused
+		}),
+		policy: &retryPolicy{attempts: 3, backoff: time.Hour, maxBackoff: time.Hour},
+		log:    log,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "http://app1/books", nil).WithContext(ctx)
+
+	if _, err := rt.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) || attempts != 1 {
+		t.Errorf("retryTransport.RoundTrip() = %v after %d attempts", err, attempts)
+	}
+}
+
+func TestFilter_ServeRetry(t *testing.T) {
+	var calls atomic.Int64
+
+	received := make(chan string, 2)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("stored"))
+	}))
+	defer backend.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url:     backend.URL,
+		log:     log,
+		request: request{Replace: []replaceParameters{{from: "book", to: "novel"}}},
+		retry:   &retryPolicy{attempts: 2, backoff: time.Millisecond, maxBackoff: time.Millisecond, status: []int{503}},
+	}
+
+	w := httptest.NewRecorder()
+	f.Serve(w, httptest.NewRequest(http.MethodPut, "http://localhost:8080/books/1", strings.NewReader("a book")))
+
+	if w.Code != http.StatusOK || w.Body.String() != "stored" {
+		t.Errorf("Filter.Serve() = %d %q", w.Code, w.Body.String())
+	}
+
+	// The body modified by the request rules is sent again
+	for i := 0; i < 2; i++ {
+		if got := <-received; got != "a novel" {
+			t.Errorf("Filter.Serve() attempt %d body = %q", i+1, got)
+		}
+	}
+}
+
+func TestFilter_ServeTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		timeout    time.Duration
+		wantStatus int
+	}{
+		{"timeout", backend.URL, 50 * time.Millisecond, http.StatusGatewayTimeout},
+		{"connection error", "http://127.0.0.1:1", time.Second, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+
+			f := &Filter{url: tt.url, log: log, timeout: tt.timeout}
+
+			w := httptest.NewRecorder()
+			f.Serve(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/books", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Filter.Serve() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if !HadErrorLevel(hook, logrus.ErrorLevel) {
+				t.Errorf("Filter.Serve() did not log the proxy error")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Response headers containing an URL to the upstream.
+var reverseHeaders = []string{"Location", "Content-Location"} //nolint: gochecknoglobals
+
+// reversePrefix applies on s the inverse of the selected prefix rules that have been used to rewrite the original path
+// of the request, the regular expression rules cannot be inverted and are ignored.
+func (f *Filter) reversePrefix(originalPath string, s string, selected func(p replaceParameters) bool) string {
+	for _, p := range f.prefix {
+		if p.regex != nil || !selected(p) || !isURLConcerned(originalPath, p.urls) || !strings.HasPrefix(originalPath, p.from) {
+			continue
+		}
+
+		if strings.HasPrefix(s, p.to) {
+			return p.from + s[len(p.to):]
+		}
+	}
+
+	return s
+}
+
+func isReversed(p replaceParameters) bool {
+	return p.reverse
+}
+
+func isLinkReversed(p replaceParameters) bool {
+	return p.reverseLinks
+}
+
+// hasReversePrefix returns true if at least one prefix rule must be reversed.
+func (f *Filter) hasReversePrefix() bool {
+	for _, p := range f.prefix {
+		if p.reverse {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reverseURL applies the selected reverse prefix rules on an absolute path or on an URL pointing to the upstream,
+// the other URLs are kept as is.
+func (f *Filter) reverseURL(originalPath string, link string, selected func(p replaceParameters) bool) string {
+	origin := ""
+
+	for _, upstreamURL := range f.upstreamURLs() {
+		if u, err := url.Parse(upstreamURL); err == nil && u.Host != "" {
+			upstream := u.Scheme + "://" + u.Host
+			if strings.HasPrefix(link, upstream) && (len(link) == len(upstream) || link[len(upstream)] == '/') {
+				origin = upstream
+
+				break
+			}
+		}
+	}
+
+	path := link[len(origin):]
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return link
+	}
+
+	return origin + f.reversePrefix(originalPath, path, selected)
+}
+
+// reverseLinks returns the function reversing the links of the bodies of the response, nil if no prefix rule concerns it.
+func (f *Filter) reverseLinks(r *http.Request) func(string) string {
+	originalPath := originalRequestPath(r)
+
+	for _, p := range f.prefix {
+		if p.reverseLinks && isURLConcerned(originalPath, p.urls) && strings.HasPrefix(originalPath, p.from) {
+			return func(link string) string {
+				return f.reverseURL(originalPath, link, isLinkReversed)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reverseResponseHeaders applies the reverse prefix rules on the Location, Content-Location and Refresh headers.
+func (f *Filter) reverseResponseHeaders(log logrus.FieldLogger, r *http.Response) {
+	originalPath := originalRequestPath(r.Request)
+
+	for _, name := range reverseHeaders {
+		value := r.Header.Get(name)
+		if value == "" {
+			continue
+		}
+
+		if reversed := f.reverseURL(originalPath, value, isReversed); reversed != value {
+			r.Header.Set(name, reversed)
+			log.Debug(fmt.Sprintf("Reverse header %s from %s to %s", name, value, reversed))
+		}
+	}
+
+	value := r.Header.Get("Refresh")
+	if parts := refreshURL.FindStringSubmatch(value); parts != nil {
+		if reversed := parts[1] + f.reverseURL(originalPath, parts[2], isReversed) + parts[3]; reversed != value {
+			r.Header.Set("Refresh", reversed)
+			log.Debug(fmt.Sprintf("Reverse header Refresh from %s to %s", value, reversed))
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package filter
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestFilter_reversePrefix(t *testing.T) {
+	prefix := []replaceParameters{
+		{from: "/dev/", to: "/", urls: []*regexp.Regexp{regexp.MustCompile("^/dev/app")}, reverse: true},
+		{from: "/quick", to: "/urgent"},
+		{from: "/(v[0-9])/", to: "/", regex: regexp.MustCompile("/(v[0-9])/")},
+	}
+
+	tests := []struct {
+		name         string
+		originalPath string
+		s            string
+		want         string
+	}{
+		{"root", "/dev/app/login", "/", "/dev/"},
+		{"sub path", "/dev/app/login", "/app", "/dev/app"},
+		{"url not concerned", "/dev/other", "/", "/"},
+		{"prefix not used", "/app/login", "/", "/"},
+		{"second rule", "/quick/login", "/urgent/", "/quick/"},
+		{"not reversible", "/quick/login", "/other/", "/other/"},
+		{"regex ignored", "/v1/login", "/", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Filter{
+				prefix: prefix,
+				log:    logrus.New(),
+			}
+			all := func(p replaceParameters) bool { return true }
+			if got := f.reversePrefix(tt.originalPath, tt.s, all); got != tt.want {
+				t.Errorf("Filter.reversePrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_reversePrefixSelected(t *testing.T) {
+	f := &Filter{
+		prefix: []replaceParameters{
+			{from: "/dev/", to: "/", reverse: true},
+			{from: "/quick", to: "/urgent"},
+		},
+		log: logrus.New(),
+	}
+
+	if got := f.reversePrefix("/quick/login", "/urgent/", isReversed); got != "/urgent/" {
+		t.Errorf("Filter.reversePrefix() = %v, want %v", got, "/urgent/")
+	}
+
+	if got := f.reversePrefix("/dev/login", "/", isReversed); got != "/dev/" {
+		t.Errorf("Filter.reversePrefix() = %v, want %v", got, "/dev/")
+	}
+}
+
+func TestFilter_reverseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"absolute path", "/login", "/dev/login"},
+		{"upstream url", "http://legacy:8080/login", "http://legacy:8080/dev/login"},
+		{"upstream root", "http://legacy:8080", "http://legacy:8080"},
+		{"other host", "http://legacy:8081/login", "http://legacy:8081/login"},
+		{"scheme relative", "//cdn/login", "//cdn/login"},
+		{"relative", "login", "login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Filter{
+				url:    "http://legacy:8080",
+				prefix: []replaceParameters{{from: "/dev/", to: "/", reverse: true}},
+				log:    logrus.New(),
+			}
+			if got := f.reverseURL("/dev/index", tt.link, isReversed); got != tt.want {
+				t.Errorf("Filter.reverseURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_reverseResponseHeaders(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	req, _ := http.NewRequest("GET", "http://legacy:8080/index", nil)
+	req.URL.Path = "/dev/index"
+	req = withOriginalRequest(req)
+
+	r := &http.Response{
+		Header: http.Header{
+			"Location":         []string{"http://legacy:8080/login"},
+			"Content-Location": []string{"/index.fr.html"},
+			"Refresh":          []string{"5; url=/next"},
+		},
+		Request: req,
+	}
+
+	f := &Filter{
+		url:    "http://legacy:8080",
+		prefix: []replaceParameters{{from: "/dev/", to: "/", reverse: true}},
+		log:    log,
+	}
+
+	f.reverseResponseHeaders(log, r)
+
+	want := http.Header{
+		"Location":         []string{"http://legacy:8080/dev/login"},
+		"Content-Location": []string{"/dev/index.fr.html"},
+		"Refresh":          []string{"5; url=/dev/next"},
+	}
+	if !reflect.DeepEqual(r.Header, want) {
+		t.Errorf("Filter.reverseResponseHeaders() = %v, want %v", r.Header, want)
+	}
+
+	verifyLogged(
+		"Filter.reverseResponseHeaders",
+		[]string{
+			"Reverse header Location from http://legacy:8080/login to http://legacy:8080/dev/login",
+			"Reverse header Content-Location from /index.fr.html to /dev/index.fr.html",
+			"Reverse header Refresh from 5; url=/next to 5; url=/dev/next",
+		},
+		hook,
+		t,
+	)
+}
+
+func TestFilter_reverseLinks(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://legacy:8080/index", nil)
+	req.URL.Path = "/dev/index"
+	req = withOriginalRequest(req)
+
+	f := &Filter{
+		url: "http://legacy:8080",
+		prefix: []replaceParameters{
+			{from: "/dev/", to: "/", reverse: true, reverseLinks: true},
+			{from: "/prod/", to: "/", reverse: true},
+		},
+		log: logrus.New(),
+	}
+
+	links := f.reverseLinks(req)
+	if links == nil {
+		t.Fatalf("Filter.reverseLinks() = nil")
+	}
+
+	rules := bodyRules{links: links, html: []replaceParameters{{from: "http://legacy:8080/", to: "/"}}}
+	body := `<a href="/home">home</a><img src="http://legacy:8080/i.png"><a href="https://other/">x</a>`
+	want := `<a href="/dev/home">home</a><img src="/dev/i.png"><a href="https://other/">x</a>`
+
+	if got := f.rewriteHTML("/index", rules, body, "text/html"); got != want {
+		t.Errorf("Filter.rewriteHTML() = %v, want %v", got, want)
+	}
+
+	req.URL.Path = "/prod/index"
+	if links := f.reverseLinks(withOriginalRequest(req)); links != nil {
+		t.Errorf("Filter.reverseLinks() not nil for a prefix without reverseLinks")
+	}
+}
@@ -0,0 +1,289 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rewriteRule rewrites the path and the query of the requests matching its path and query conditions.
+type rewriteRule struct {
+	from        *regexp.Regexp   // nil for all the paths
+	query       []valueCondition // The named groups of the values can be used like the ones of from
+	to          string           // New path, the path is kept if empty
+	addQuery    map[string]string
+	removeQuery []string
+	renameQuery map[string]string
+}
+
+// parseRewriteConfig verifies the URL rewriting rules and compiles their regular expressions.
+func parseRewriteConfig(log logrus.FieldLogger, rules []Crewrite) []rewriteRule {
+	result := make([]rewriteRule, 0, len(rules))
+
+	for _, r := range rules {
+		if r.From == "" && len(r.Query) == 0 {
+			log.Fatal("A rewrite rule must have a from or a query condition")
+		}
+
+		if r.To == "" && len(r.AddQuery) == 0 && len(r.RemoveQuery) == 0 && len(r.RenameQuery) == 0 {
+			log.Fatalf("The rewrite rule %s does not change anything", r.From)
+		}
+
+		if strings.Contains(r.To, "?") {
+			log.Fatalf("The path %s of a rewrite rule cannot contain a query, use addQuery", r.To)
+		}
+
+		rule := rewriteRule{
+			to:          r.To,
+			addQuery:    r.AddQuery,
+			removeQuery: r.RemoveQuery,
+			renameQuery: r.RenameQuery,
+		}
+
+		if r.From != "" {
+			rule.from = parseRegexConfig(log, r.From)
+		}
+
+		if len(r.Query) > 0 {
+			rule.query = parseConditionsConfig(log, &Cconditions{Query: r.Query}).query
+		}
+
+		result = append(result, rule)
+	}
+
+	return result
+}
+
+// match returns the values of the groups of the rule if it concerns the URL.
+func (r rewriteRule) match(u *url.URL) (func(string) string, bool) {
+	var pathMatch []int
+
+	if r.from != nil {
+		if pathMatch = r.from.FindStringSubmatchIndex(u.Path); pathMatch == nil {
+			return nil, false
+		}
+	}
+
+	query := u.Query()
+	groups := map[string]string{}
+
+	for _, q := range r.query {
+		values, ok := query[q.name]
+		if !ok {
+			return nil, false
+		}
+
+		if q.value == nil {
+			continue
+		}
+
+		found := false
+
+		for _, v := range values {
+			if m := q.value.FindStringSubmatch(v); m != nil {
+				for i, name := range q.value.SubexpNames() {
+					if name != "" {
+						groups[name] = m[i]
+					}
+				}
+
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	// The path is replaced before the query parameters are expanded
+	path := u.Path
+	expand := func(template string) string {
+		var b strings.Builder
+
+		for {
+			i := strings.IndexByte(template, '$')
+			if i < 0 {
+				break
+			}
+
+			b.WriteString(template[:i])
+			template = template[i:]
+
+			name, rest, ok := groupReference(template)
+			if !ok {
+				// $$ is a $, like a $ which does not refer to a group
+				template = strings.TrimPrefix(template[1:], "$")
+
+				b.WriteByte('$')
+
+				continue
+			}
+
+			template = rest
+
+			// The groups of the query are used first, their values are not expanded again
+			if value, found := groups[name]; found {
+				b.WriteString(value)
+			} else if pathMatch != nil {
+				b.Write(r.from.ExpandString(nil, "${"+name+"}", path, pathMatch))
+			}
+		}
+
+		b.WriteString(template)
+
+		return b.String()
+	}
+
+	return expand, true
+}
+
+// groupReference returns the name of the group referred by the $name or ${name} at the start of template and the
+// rest of the template, with the same rules as regexp.Expand.
+func groupReference(template string) (string, string, bool) {
+	if len(template) < 2 || template[0] != '$' {
+		return "", "", false
+	}
+
+	brace := template[1] == '{'
+
+	rest := template[1:]
+	if brace {
+		rest = rest[1:]
+	}
+
+	end := strings.IndexFunc(rest, func(c rune) bool {
+		return c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	if end < 0 {
+		end = len(rest)
+	}
+
+	if end == 0 {
+		return "", "", false
+	}
+
+	name := rest[:end]
+	rest = rest[end:]
+
+	if brace {
+		if !strings.HasPrefix(rest, "}") {
+			return "", "", false
+		}
+
+		rest = rest[1:]
+	}
+
+	return name, rest, true
+}
+
+// apply rewrites the path and the query of the URL.
+func (r rewriteRule) apply(u *url.URL, expand func(string) string) {
+	if r.to != "" {
+		u.Path = expand(r.to)
+		u.RawPath = ""
+	}
+
+	if len(r.addQuery) == 0 && len(r.removeQuery) == 0 && len(r.renameQuery) == 0 {
+		return
+	}
+
+	query := u.Query()
+
+	for from, to := range r.renameQuery {
+		if values, ok := query[from]; ok {
+			query[to] = append(query[to], values...)
+			delete(query, from)
+		}
+	}
+
+	for _, name := range r.removeQuery {
+		query.Del(name)
+	}
+
+	for name, value := range r.addQuery {
+		query.Set(name, expand(value))
+	}
+
+	u.RawQuery = query.Encode()
+}
+
+// rewriteURL applies the first rewriting rule concerning the URL of the request, the URL requested by the client is
+// kept in the request context for the dumps.
+func (f *Filter) rewriteURL(req *http.Request) *http.Request {
+	for _, r := range f.rewrite {
+		expand, ok := r.match(req.URL)
+		if !ok {
+			continue
+		}
+
+		original := req.URL.RequestURI()
+		r.apply(req.URL, expand)
+		f.log.Debugf("Rewriting URL %s => %s", original, req.URL.RequestURI())
+
+		return req.WithContext(context.WithValue(req.Context(), rewrittenFromKey, original))
+	}
+
+	return req
+}
+
+// rewrittenFrom returns the URL requested by the client if it has been rewritten, an empty string otherwise.
+func rewrittenFrom(r *http.Request) string {
+	from, _ := r.Context().Value(rewrittenFromKey).(string)
+
+	return from
+}
+
+// describe returns the rule for the logs.
+func (r rewriteRule) describe() []string {
+	lines := []string{}
+
+	from := "all the paths"
+	if r.from != nil {
+		from = r.from.String()
+	}
+
+	if r.to != "" {
+		lines = append(lines, fmt.Sprintf("    %s to %s", from, r.to))
+	} else {
+		lines = append(lines, fmt.Sprintf("    %s", from))
+	}
+
+	if len(r.query) > 0 {
+		lines = append(lines, "        when "+strings.Join(describeValues("query", r.query), " and "))
+	}
+
+	for _, from := range sortedKeys(r.renameQuery) {
+		lines = append(lines, fmt.Sprintf("        rename query parameter %s to %s", from, r.renameQuery[from]))
+	}
+
+	for _, name := range r.removeQuery {
+		lines = append(lines, fmt.Sprintf("        remove query parameter %s", name))
+	}
+
+	for _, name := range sortedKeys(r.addQuery) {
+		lines = append(lines, fmt.Sprintf("        set query parameter %s to %s", name, r.addQuery[name]))
+	}
+
+	return lines
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,282 @@
+package filter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseRewriteConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     []Crewrite
+		want      []rewriteRule
+		wantFatal bool
+	}{
+		{
+			"path",
+			[]Crewrite{{From: "^/blog/(.*)$", To: "/articles/$1"}},
+			[]rewriteRule{{from: regexp.MustCompile("^/blog/(.*)$"), to: "/articles/$1"}},
+			false,
+		},
+		{
+			"query",
+			[]Crewrite{{
+				Query:       []Cmatch{{Name: "id", Value: `^(?P<id>\d+)$`}},
+				To:          "/api/reports/${id}",
+				RemoveQuery: []string{"id"},
+			}},
+			[]rewriteRule{{
+				query:       []valueCondition{{name: "id", value: regexp.MustCompile(`^(?P<id>\d+)$`)}},
+				to:          "/api/reports/${id}",
+				removeQuery: []string{"id"},
+			}},
+			false,
+		},
+		{
+			"no condition",
+			[]Crewrite{{To: "/home"}},
+			[]rewriteRule{{to: "/home"}},
+			true,
+		},
+		{
+			"no change",
+			[]Crewrite{{From: "^/home$"}},
+			[]rewriteRule{{from: regexp.MustCompile("^/home$")}},
+			true,
+		},
+		{
+			"query in the path",
+			[]Crewrite{{From: "^/home$", To: "/index?lang=en"}},
+			[]rewriteRule{{from: regexp.MustCompile("^/home$"), to: "/index?lang=en"}},
+			true,
+		},
+		{
+			"invalid regexp",
+			[]Crewrite{{From: "^/home($", To: "/index"}},
+			[]rewriteRule{{to: "/index"}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseRewriteConfig(log, tt.rules)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Errorf("parseRewriteConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if !tt.wantFatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRewriteConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_rewriteURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []Crewrite
+		url      string
+		want     string
+		wantFrom string
+	}{
+		{
+			"query to path",
+			[]Crewrite{{
+				From:        "^/old/report.php$",
+				Query:       []Cmatch{{Name: "id", Value: `^(?P<id>\d+)$`}},
+				To:          "/api/reports/${id}",
+				RemoveQuery: []string{"id"},
+			}},
+			"/old/report.php?id=5&format=pdf",
+			"/api/reports/5?format=pdf",
+			"/old/report.php?id=5&format=pdf",
+		},
+		{
+			"query not matching",
+			[]Crewrite{{
+				From:  "^/old/report.php$",
+				Query: []Cmatch{{Name: "id", Value: `^(?P<id>\d+)$`}},
+				To:    "/api/reports/${id}",
+			}},
+			"/old/report.php?id=last",
+			"/old/report.php?id=last",
+			"",
+		},
+		{
+			"path groups",
+			[]Crewrite{{From: "^/blog/([0-9]+)/(.*)$", To: "/articles/$2", AddQuery: map[string]string{"year": "$1"}}},
+			"/blog/2020/hello",
+			"/articles/hello?year=2020",
+			"/blog/2020/hello",
+		},
+		{
+			"query parameters",
+			[]Crewrite{{
+				From:        "^/search$",
+				RenameQuery: map[string]string{"q": "query"},
+				RemoveQuery: []string{"debug"},
+				AddQuery:    map[string]string{"lang": "en"},
+			}},
+			"/search?q=book&debug=1&lang=fr",
+			"/search?lang=en&query=book",
+			"/search?q=book&debug=1&lang=fr",
+		},
+		{
+			"query groups without braces",
+			[]Crewrite{{
+				From:        "^/(?P<page>[a-z]+).php$",
+				Query:       []Cmatch{{Name: "id", Value: `^(?P<id>\d+)$`}},
+				To:          "/$page/$id",
+				RemoveQuery: []string{"id"},
+				AddQuery:    map[string]string{"ref": "$id-${page}$$"},
+			}},
+			"/report.php?id=5",
+			"/report/5?ref=5-report%24",
+			"/report.php?id=5",
+		},
+		{
+			"first rule wins",
+			[]Crewrite{{From: "^/a", To: "/first"}, {From: "^/a", To: "/second"}},
+			"/a",
+			"/first",
+			"/a",
+		},
+		{
+			"no match",
+			[]Crewrite{{From: "^/a", To: "/first"}},
+			"/b?x=1",
+			"/b?x=1",
+			"",
+		},
+		{
+			"dollar in a query value",
+			[]Crewrite{{Query: []Cmatch{{Name: "price", Value: `^(?P<price>.*)$`}}, To: "/prices/${price}"}},
+			"/shop?price=%241",
+			"/prices/$1?price=%241",
+			"/shop?price=%241",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+
+			f := &Filter{log: log, rewrite: parseRewriteConfig(log, tt.rules)}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080"+tt.url, nil)
+			req = f.rewriteURL(req)
+
+			if got := req.URL.RequestURI(); got != tt.want {
+				t.Errorf("Filter.rewriteURL() = %v, want %v", got, tt.want)
+			}
+
+			if got := rewrittenFrom(req); got != tt.wantFrom {
+				t.Errorf("rewrittenFrom() = %v, want %v", got, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestFilter_ServeRewrite(t *testing.T) {
+	received := make(chan string, 1)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.RequestURI()
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url: backend.URL,
+		log: log,
+		rewrite: parseRewriteConfig(log, []Crewrite{{
+			From:        "^/old/report.php$",
+			Query:       []Cmatch{{Name: "id", Value: `^(?P<id>\d+)$`}},
+			To:          "/api/reports/${id}",
+			RemoveQuery: []string{"id"},
+		}}),
+		prefix: []replaceParameters{{from: "/api/", to: "/v2/"}},
+	}
+
+	front := httptest.NewServer(http.HandlerFunc(f.Serve))
+	defer front.Close()
+
+	res, err := http.Get(front.URL + "/old/report.php?id=5")
+	if err != nil {
+		t.Fatalf("Get error = %v", err)
+	}
+
+	_, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+
+	// The prefix rules apply to the rewritten path
+	if got := <-received; got != "/v2/reports/5" {
+		t.Errorf("Filter.Serve() upstream URL = %v, want /v2/reports/5", got)
+	}
+}
+
+func Test_dumpedRequestHeader(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{log: log, rewrite: parseRewriteConfig(log, []Crewrite{{From: "^/a$", To: "/b"}})}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/a?x=1", nil)
+	req.Header.Set("X-Env", "dev")
+
+	if got := dumpedRequestHeader(req); !reflect.DeepEqual(got, http.Header{"X-Env": {"dev"}}) {
+		t.Errorf("dumpedRequestHeader() = %v for a request not rewritten", got)
+	}
+
+	req = f.rewriteURL(req)
+
+	want := http.Header{"X-Env": {"dev"}, "X-Villip-Rewritten-From": {"/a?x=1"}}
+	if got := dumpedRequestHeader(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("dumpedRequestHeader() = %v, want %v", got, want)
+	}
+
+	// The header sent to the upstream is not modified
+	if _, ok := req.Header["X-Villip-Rewritten-From"]; ok {
+		t.Errorf("dumpedRequestHeader() modified the request header %v", req.Header)
+	}
+}
+
+func TestFilter_printRewriteInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log: log,
+		rewrite: parseRewriteConfig(log, []Crewrite{
+			{From: "^/blog/(.*)$", To: "/articles/$1"},
+			{
+				Query:       []Cmatch{{Name: "id", Value: `^\d+$`}, {Name: "v"}},
+				RenameQuery: map[string]string{"q": "query"},
+				RemoveQuery: []string{"debug"},
+				AddQuery:    map[string]string{"lang": "en"},
+			},
+		}),
+	}
+
+	f.printRewriteInLog()
+
+	verifyLogged("Filter.printRewriteInLog", []string{
+		"Rewrite the request URLs (first matching rule):",
+		"    ^/blog/(.*)$ to /articles/$1",
+		"    all the paths",
+		`        when query id matches ^\d+$ and query v present`,
+		"        rename query parameter q to query",
+		"        remove query parameter debug",
+		"        set query parameter lang to en",
+	}, hook, t)
+}
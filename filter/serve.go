@@ -1,48 +1,73 @@
 package filter
 
 import (
-	"crypto/tls"
+	"context"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
-	"time"
 )
 
 // Serve starts a filtering http proxy.
 func (f *Filter) Serve(res http.ResponseWriter, req *http.Request) {
-	u, _ := url.Parse(f.url)
+	target := f.url
 
-	proxy := httputil.NewSingleHostReverseProxy(u)
-	if len(f.response.Replace) > 0 || len(f.response.Header) > 0 || f.dumpFolder != "" || len(f.dumpURLs) != 0 {
-		proxy.ModifyResponse = f.UpdateResponse
+	if f.upstreams != nil {
+		up := f.upstreams.pick(req)
+		if up == nil && f.circuit != nil && f.upstreams.anyHealthy() {
+			f.log.WithField("url", req.URL.String()).Debug("Circuits of all the upstreams open, failing fast")
+			f.circuitOpenError(res)
+
+			return
+		}
+
+		if up == nil {
+			f.log.WithField("url", req.URL.String()).Error("No healthy upstream for the request")
+			http.Error(res, "No healthy upstream", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		up.active.Add(1)
+		defer up.active.Add(-1)
+
+		target = up.url
+		req = req.WithContext(context.WithValue(req.Context(), upstreamKey, up.url))
 	}
 
-	if len(f.request.Replace) > 0 || len(f.request.Header) > 0 || f.dumpFolder != "" || len(f.dumpURLs) != 0 {
-		proxy.Director = f.UpdateRequest
+	u, _ := url.Parse(target)
+
+	proxy := f.proxy
+	if proxy == nil {
+		// Filter not created from a configuration, the connections are not reused
+		transport := newTransport(defaultDialTimeout, defaultIdleConnTimeout, f.insecure)
+		defer transport.CloseIdleConnections()
+
+		proxy = f.newReverseProxy(transport)
 	}
 
+	req = withOriginalRequest(req)
+
 	// Update the headers to allow for SSL redirection
 	req.URL.Host = u.Host
 	req.URL.Scheme = u.Scheme
 	req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
 	req.Host = u.Host
 
-	transport := http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
-		TLSHandshakeTimeout: 10 * time.Second,
+	if f.websocket == websocketFilter && isWebSocketUpgrade(req) {
+		// The messages cannot be filtered if they are compressed
+		req.Header.Del("Sec-WebSocket-Extensions")
 	}
 
-	if f.insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint: gosec
+	// The WebSocket connections last longer than a request
+	if f.timeout > 0 && !isWebSocketUpgrade(req) {
+		ctx, cancel := context.WithTimeout(req.Context(), f.timeout)
+		defer cancel()
 
-		f.log.Debug("Not checking SSL certificates")
+		req = req.WithContext(ctx)
 	}
 
-	proxy.Transport = &transport
-
 	f.log.Debug("proxying")
 
+	req = f.rewriteURL(req)
 	req.URL.Path = f.PrefixReplace(req.URL.Path)
 	proxy.ServeHTTP(res, req)
-	transport.CloseIdleConnections()
 }
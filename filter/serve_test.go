@@ -100,16 +100,16 @@ func TestFilter_Serve(t *testing.T) {
 			fields{
 				true,
 				request{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "book",
 							to:   "smartphone",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
@@ -147,16 +147,16 @@ func TestFilter_Serve(t *testing.T) {
 				true,
 				request{},
 				response{
-					[]replaceParameters{
+					Replace: []replaceParameters{
 						{
 							from: "boardgames",
 							to:   "videogames",
 						},
 					},
-					[]Cheader{
+					Header: []headerRule{
 						{
-							Name:  "X-ENV",
-							Value: "prod",
+							name:  "X-ENV",
+							value: "prod",
 						},
 					},
 				},
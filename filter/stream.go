@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+
+	"golang.org/x/text/transform"
+)
+
+// Size of the chunks read from the upstream body while streaming.
+const streamChunkSize = 32 * 1024
+
+// replaceReader applies a literal replacement on the fly. Only the last
+// len(from)-1 bytes, that could be the beginning of a match, are kept between
+// two reads so the result is the same as a strings.ReplaceAll on the whole body.
+type replaceReader struct {
+	src  io.Reader
+	from []byte
+	to   []byte
+	in   []byte
+	buf  []byte // Reused by each read of src
+	out  bytes.Buffer
+	err  error
+}
+
+func newReplaceReader(src io.Reader, from string, to string) *replaceReader {
+	return &replaceReader{src: src, from: []byte(from), to: []byte(to), in: make([]byte, 0, streamChunkSize)}
+}
+
+func (rr *replaceReader) Read(p []byte) (int, error) {
+	for rr.out.Len() == 0 {
+		if rr.err != nil {
+			return 0, rr.err
+		}
+
+		if rr.buf == nil {
+			rr.buf = make([]byte, streamChunkSize)
+		}
+
+		n, err := rr.src.Read(rr.buf)
+		rr.in = append(rr.in, rr.buf[:n]...)
+
+		if err != nil {
+			rr.err = err
+		}
+
+		rr.process(rr.err != nil)
+	}
+
+	return rr.out.Read(p)
+}
+
+func (rr *replaceReader) process(final bool) {
+	buf := rr.in
+
+	for {
+		i := bytes.Index(buf, rr.from)
+		if i < 0 {
+			break
+		}
+
+		rr.out.Write(buf[:i])
+		rr.out.Write(rr.to)
+		buf = buf[i+len(rr.from):]
+	}
+
+	keep := 0
+	if !final {
+		keep = min(len(rr.from)-1, len(buf))
+	}
+
+	rr.out.Write(buf[:len(buf)-keep])
+	rr.in = rr.in[:copy(rr.in, buf[len(buf)-keep:])]
+}
+
+// streamBody is the body returned to the reverse proxy, closing it releases
+// the upstream body and the encoding goroutine.
+type streamBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (sb *streamBody) Close() error {
+	var err error
+
+	for _, c := range sb.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// isStreamable returns true if all the rules concerning the URL can be applied on a stream.
+func isStreamable(requestURL string, rep []replaceParameters) bool {
+	for _, r := range rep {
+		if !isURLConcerned(requestURL, r.urls) {
+			continue
+		}
+
+		if r.regex != nil || r.from == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *Filter) streamAndReplaceBody(
+	requestURL string,
+	rep []replaceParameters,
+	bod io.ReadCloser,
+	parsedHeader http.Header,
+) (io.ReadCloser, error) {
+	closers := []io.Closer{bod}
+	encoding := parsedHeader.Get("Content-Encoding")
+
+	if !isKnownEncoding(encoding) {
+		f.log.WithField("encoding", encoding).Debug("Unsupported content encoding, body not filtered")
+
+		return bod, nil
+	}
+
+	decoded, err := newDecoder(encoding, bod)
+	if err != nil {
+		f.log.Errorf("Impossible to decompress: %v", err)
+
+		return nil, err
+	}
+
+	if c, ok := decoded.(io.Closer); ok && decoded != io.Reader(bod) {
+		closers = append([]io.Closer{c}, closers...)
+	}
+
+	contentType := parsedHeader.Get("Content-Type")
+	head := bufio.NewReaderSize(decoded, charsetPrescanSize)
+	peeked, _ := head.Peek(charsetPrescanSize)
+
+	var body io.Reader = head
+
+	enc, charsetName := bodyCharset(contentType, peeked)
+	if enc != nil {
+		f.log.WithField("charset", charsetName).Debug("Converting body to UTF-8")
+
+		body = transform.NewReader(body, enc.NewDecoder())
+	}
+
+	for _, r := range rep {
+		if isURLConcerned(requestURL, r.urls) {
+			body = newReplaceReader(body, r.from, r.to)
+		}
+	}
+
+	if enc != nil {
+		body = transform.NewReader(body, charsetEncoder(enc, contentType))
+	}
+
+	f.log.WithField("requestURL", requestURL).Debug("Streaming the body replacement")
+
+	if decoded == io.Reader(bod) {
+		return &streamBody{Reader: body, closers: closers}, nil
+	}
+
+	pr, pw := io.Pipe()
+
+	go func(src io.Reader) {
+		compressed, err := newEncoder(encoding, pw)
+		if err == nil {
+			_, err = io.Copy(compressed, src)
+		}
+
+		if err == nil {
+			err = compressed.Close()
+		}
+
+		pw.CloseWithError(err)
+	}(body)
+
+	closers = append([]io.Closer{pr}, closers...)
+
+	return &streamBody{Reader: pr, closers: closers}, nil
+}
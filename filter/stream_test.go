@@ -0,0 +1,351 @@
+package filter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_replaceReader(t *testing.T) {
+	type rule struct {
+		from string
+		to   string
+	}
+	tests := []struct {
+		name  string
+		body  string
+		rules []rule
+	}{
+		{
+			"no match",
+			"take your book,\ntry to dance\n sing often",
+			[]rule{{"videogame", "boardgame"}},
+		},
+		{
+			"simple",
+			"take your book,\ntry to dance\n sing often",
+			[]rule{{"book", "smartphone"}},
+		},
+		{
+			"match at the end",
+			"take your book",
+			[]rule{{"book", "smartphone"}},
+		},
+		{
+			"consecutive matches",
+			"aaaaaaa",
+			[]rule{{"aa", "b"}},
+		},
+		{
+			"partial match at the end",
+			"take your boo",
+			[]rule{{"book", "smartphone"}},
+		},
+		{
+			"chained",
+			"take your book,\ntry to dance\n sing often",
+			[]rule{{"book", "smartphone"}, {"smart", "dumb"}, {"sing", "chat"}},
+		},
+		{
+			"empty body",
+			"",
+			[]rule{{"book", "smartphone"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.body
+			for _, r := range tt.rules {
+				want = strings.ReplaceAll(want, r.from, r.to)
+			}
+
+			var body io.Reader = iotest.OneByteReader(strings.NewReader(tt.body))
+			for _, r := range tt.rules {
+				body = newReplaceReader(body, r.from, r.to)
+			}
+
+			got, err := io.ReadAll(iotest.HalfReader(body))
+			if err != nil {
+				t.Fatalf("replaceReader.Read() error = %v", err)
+			}
+
+			if string(got) != want {
+				t.Errorf("replaceReader.Read() = %#v, want %#v", string(got), want)
+			}
+		})
+	}
+}
+
+func Test_isStreamable(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		rep  []replaceParameters
+		want bool
+	}{
+		{
+			"no rules",
+			"/youngster",
+			[]replaceParameters{},
+			true,
+		},
+		{
+			"literal",
+			"/youngster",
+			[]replaceParameters{{from: "book", to: "smartphone"}},
+			true,
+		},
+		{
+			"empty from",
+			"/youngster",
+			[]replaceParameters{{from: "", to: "smartphone"}},
+			false,
+		},
+		{
+			"regex",
+			"/youngster",
+			[]replaceParameters{{from: "bo+k", to: "smartphone", regex: regexp.MustCompile("bo+k")}},
+			false,
+		},
+		{
+			"regex for other url",
+			"/youngster",
+			[]replaceParameters{
+				{
+					from:  "bo+k",
+					to:    "smartphone",
+					urls:  []*regexp.Regexp{regexp.MustCompile("^/boomer")},
+					regex: regexp.MustCompile("bo+k"),
+				},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStreamable(tt.url, tt.rep); got != tt.want {
+				t.Errorf("isStreamable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func gzipString(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("gzip error = %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip error = %v", err)
+	}
+
+	return b.Bytes()
+}
+
+func TestFilter_streamAndReplaceBody(t *testing.T) {
+	rep := []replaceParameters{
+		{from: "book", to: "smartphone"},
+		{from: "dance", to: "chat", urls: []*regexp.Regexp{regexp.MustCompile("^/boomer")}},
+	}
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		wantErr  bool
+		want     string
+	}{
+		{
+			"plain",
+			[]byte("take your book,\ntry to dance"),
+			"",
+			false,
+			"take your smartphone,\ntry to dance",
+		},
+		{
+			"gzip",
+			gzipString(t, "take your book,\ntry to dance"),
+			"gzip",
+			false,
+			"take your smartphone,\ntry to dance",
+		},
+		{
+			"brotli",
+			encodeString(t, "br", "take your book,\ntry to dance"),
+			"br",
+			false,
+			"take your smartphone,\ntry to dance",
+		},
+		{
+			"zstd",
+			encodeString(t, "zstd", "take your book,\ntry to dance"),
+			"zstd",
+			false,
+			"take your smartphone,\ntry to dance",
+		},
+		{
+			"unknown encoding",
+			[]byte("take your book"),
+			"compress",
+			false,
+			"take your book",
+		},
+		{
+			"wrong gzip",
+			[]byte("take your book"),
+			"gzip",
+			true,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+			f := &Filter{
+				log: log,
+			}
+
+			header := http.Header{}
+			if tt.encoding != "" {
+				header.Set("Content-Encoding", tt.encoding)
+			}
+
+			got, err := f.streamAndReplaceBody("/youngster", rep, io.NopCloser(bytes.NewReader(tt.body)), header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Filter.streamAndReplaceBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			defer got.Close()
+
+			body, err := newDecoder(tt.encoding, got)
+			if err != nil {
+				t.Fatalf("Filter.streamAndReplaceBody() not %s encoded: %v", tt.encoding, err)
+			}
+
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Filter.streamAndReplaceBody() read error = %v", err)
+			}
+
+			if string(b) != tt.want {
+				t.Errorf("Filter.streamAndReplaceBody() = %#v, want %#v", string(b), tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateResponseStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		rep         []replaceParameters
+		header      http.Header
+		body        []byte
+		wantBody    string
+		wantHeaders http.Header
+	}{
+		{
+			"streamed",
+			[]replaceParameters{{from: "book", to: "smartphone"}},
+			http.Header{
+				"Content-Type":   []string{"text/html"},
+				"Content-Length": []string{"28"},
+				"Location":       []string{"/book"},
+			},
+			[]byte("take your book,\ntry to dance"),
+			"take your smartphone,\ntry to dance",
+			http.Header{
+				"Content-Type": []string{"text/html"},
+				"Location":     []string{"/smartphone"},
+			},
+		},
+		{
+			"streamed gzip",
+			[]replaceParameters{{from: "book", to: "smartphone"}},
+			http.Header{
+				"Content-Type":     []string{"text/html"},
+				"Content-Encoding": []string{"gzip"},
+			},
+			gzipString(t, "take your book,\ntry to dance"),
+			"take your smartphone,\ntry to dance",
+			http.Header{
+				"Content-Type":     []string{"text/html"},
+				"Content-Encoding": []string{"gzip"},
+			},
+		},
+		{
+			"regex buffered",
+			[]replaceParameters{{from: "bo+k", to: "smartphone", regex: regexp.MustCompile("bo+k")}},
+			http.Header{
+				"Content-Type": []string{"text/html"},
+			},
+			[]byte("take your book,\ntry to dance"),
+			"take your smartphone,\ntry to dance",
+			http.Header{
+				"Content-Type":   []string{"text/html"},
+				"Content-Length": []string{"34"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			log.SetLevel(logrus.DebugLevel)
+
+			req, _ := http.NewRequest("GET", "http://localhost:8081/youngster/1", nil)
+
+			r := http.Response{
+				Header:        tt.header,
+				StatusCode:    http.StatusOK,
+				Request:       req,
+				Body:          io.NopCloser(bytes.NewReader(tt.body)),
+				ContentLength: int64(len(tt.body)),
+			}
+
+			f := &Filter{
+				stream:       true,
+				response:     response{Replace: tt.rep},
+				contentTypes: []string{"text/html"},
+				log:          log,
+			}
+
+			if err := f.UpdateResponse(&r); err != nil {
+				t.Fatalf("Filter.UpdateResponse() error = %v", err)
+			}
+
+			var body io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Fatalf("Filter.UpdateResponse() not gzipped: %v", err)
+				}
+
+				body = gz
+			}
+
+			b, _ := io.ReadAll(body)
+			if string(b) != tt.wantBody {
+				t.Errorf("Filter.UpdateResponse() got = %#v, want %#v", string(b), tt.wantBody)
+			}
+
+			if !reflect.DeepEqual(r.Header, tt.wantHeaders) {
+				t.Errorf("Filter.UpdateResponse() headers = %#v, want %#v", r.Header, tt.wantHeaders)
+			}
+		})
+	}
+}
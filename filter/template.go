@@ -0,0 +1,286 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+// Keys of the request context containing the host and the path used by the client before they are rewritten for the upstream.
+const (
+	originalHostKey contextKey = iota
+	originalPathKey
+	rewrittenFromKey // URL requested by the client, only if a rewrite rule has been applied
+	upstreamKey      // URL of the upstream chosen for the request, only if the filter has several upstreams
+)
+
+var templateFuncs = template.FuncMap{ //nolint: gochecknoglobals
+	"env":       os.Getenv,
+	"now":       time.Now,
+	"unixMilli": func(t time.Time) int64 { return t.UnixMilli() },
+}
+
+// templateRequest is the request as seen by the templates.
+type templateRequest struct {
+	Host   string
+	Method string
+	Path   string
+	Query  string
+	Scheme string
+	header http.Header
+	escape bool // The values are used in the replacement of a regular expression
+}
+
+// Header returns the first value of the request header.
+func (tr templateRequest) Header(name string) string {
+	if tr.escape {
+		return escapeDollar(tr.header.Get(name))
+	}
+
+	return tr.header.Get(name)
+}
+
+// templateData is the data available in the templates of replacement and header values.
+type templateData struct {
+	Request  templateRequest
+	ClientIP string
+	// Groups of the URL regular expression of the rule that matched the request
+	URLMatch []string
+	URLNamed map[string]string
+}
+
+// escapeDollar protects the $ of a value inserted in the replacement of a regular expression, a value sent by the
+// client must not refer to the groups of the expression.
+func escapeDollar(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// escaped returns a copy of the data with all the values of the request escaped by escapeDollar.
+func (d *templateData) escaped() *templateData {
+	e := *d
+	e.Request.Host = escapeDollar(d.Request.Host)
+	e.Request.Method = escapeDollar(d.Request.Method)
+	e.Request.Path = escapeDollar(d.Request.Path)
+	e.Request.Query = escapeDollar(d.Request.Query)
+	e.Request.Scheme = escapeDollar(d.Request.Scheme)
+	e.Request.escape = true
+	e.ClientIP = escapeDollar(d.ClientIP)
+
+	e.URLMatch = make([]string, len(d.URLMatch))
+	for i, m := range d.URLMatch {
+		e.URLMatch[i] = escapeDollar(m)
+	}
+
+	e.URLNamed = make(map[string]string, len(d.URLNamed))
+	for name, m := range d.URLNamed {
+		e.URLNamed[name] = escapeDollar(m)
+	}
+
+	return &e
+}
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// parseTemplates compiles all the templated values of the configuration, it returns nil if there is none.
+func parseTemplates(log logrus.FieldLogger, c Config) map[string]*template.Template {
+	var templates map[string]*template.Template
+
+	values := []string{}
+
+	for _, rep := range [][]Creplacement{c.Replace, c.Request.Replace, c.Response.Replace, c.Response.HTML} {
+		for _, r := range rep {
+			values = append(values, r.To)
+		}
+	}
+
+	for _, headers := range [][]Cheader{c.Request.Header, c.Response.Header} {
+		for _, h := range headers {
+			values = append(values, h.Value, h.To)
+		}
+	}
+
+	for _, i := range c.Response.Inject {
+		values = append(values, i.Content)
+	}
+
+	for _, value := range values {
+		if !isTemplate(value) {
+			continue
+		}
+
+		t, err := template.New(value).Funcs(templateFuncs).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			log.Fatalf("Failed to parse '%s' template: %v", value, err)
+		}
+
+		if templates == nil {
+			templates = make(map[string]*template.Template)
+		}
+
+		templates[value] = t
+	}
+
+	return templates
+}
+
+func newTemplateData(r *http.Request) *templateData {
+	data := &templateData{URLMatch: []string{}, URLNamed: map[string]string{}}
+
+	if r == nil {
+		return data
+	}
+
+	data.Request = templateRequest{
+		Host:   r.Host,
+		Method: r.Method,
+		Scheme: "http",
+		header: r.Header,
+	}
+
+	if host, ok := r.Context().Value(originalHostKey).(string); ok {
+		data.Request.Host = host
+	}
+
+	if r.URL != nil {
+		data.Request.Path = r.URL.Path
+		data.Request.Query = r.URL.RawQuery
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		data.Request.Scheme = proto
+	} else if r.TLS != nil {
+		data.Request.Scheme = "https"
+	}
+
+	data.ClientIP = r.RemoteAddr
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		data.ClientIP = ip
+	}
+
+	return data
+}
+
+// withOriginalRequest saves the host and the path requested by the client in the request context,
+// the host given by a proxy in front of villip with X-Forwarded-Host is preferred.
+func withOriginalRequest(req *http.Request) *http.Request {
+	host := req.Host
+	if forwarded, _, _ := strings.Cut(req.Header.Get("X-Forwarded-Host"), ","); strings.TrimSpace(forwarded) != "" {
+		host = strings.TrimSpace(forwarded)
+	}
+
+	ctx := context.WithValue(req.Context(), originalHostKey, host)
+
+	return req.WithContext(context.WithValue(ctx, originalPathKey, req.URL.Path))
+}
+
+// originalRequestPath returns the path requested by the client before the prefix rules.
+func originalRequestPath(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	if path, ok := r.Context().Value(originalPathKey).(string); ok {
+		return path
+	}
+
+	if r.URL == nil {
+		return ""
+	}
+
+	return r.URL.Path
+}
+
+// expand executes the template corresponding to the value, the value is returned as is if it is not a template.
+func (f *Filter) expand(value string, data *templateData) string {
+	t, ok := f.templates[value]
+	if !ok {
+		return value
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		f.log.Errorf("Failed to execute '%s' template: %v", value, err)
+
+		return value
+	}
+
+	return buf.String()
+}
+
+// expandReplace returns the rules with their templated replacement values expanded for this request.
+func (f *Filter) expandReplace(requestURL string, rep []replaceParameters, data *templateData) []replaceParameters {
+	if len(f.templates) == 0 {
+		return rep
+	}
+
+	expanded := make([]replaceParameters, len(rep))
+
+	for i, r := range rep {
+		expanded[i] = r
+
+		if _, ok := f.templates[r.to]; !ok {
+			continue
+		}
+
+		expanded[i].to = f.expand(r.to, ruleData(requestURL, r.urls, data, r.regex != nil))
+	}
+
+	return expanded
+}
+
+// ruleData returns the data of the templates of a rule with the groups of its urls expression matching the request,
+// the values are escaped if the template is the replacement of a regular expression.
+func ruleData(requestURL string, urls []*regexp.Regexp, data *templateData, regex bool) *templateData {
+	d := *data
+	d.URLMatch = []string{}
+	d.URLNamed = map[string]string{}
+
+	for _, reg := range urls {
+		if m := reg.FindStringSubmatch(requestURL); m != nil {
+			d.URLMatch = m
+
+			for j, name := range reg.SubexpNames() {
+				if name != "" {
+					d.URLNamed[name] = m[j]
+				}
+			}
+
+			break
+		}
+	}
+
+	if regex {
+		return d.escaped()
+	}
+
+	return &d
+}
+
+// expandHeaders returns the header rules with their templated values expanded for this request.
+func (f *Filter) expandHeaders(requestURL string, headers []headerRule, data *templateData) []headerRule {
+	if len(f.templates) == 0 {
+		return headers
+	}
+
+	expanded := make([]headerRule, len(headers))
+
+	for i, h := range headers {
+		expanded[i] = h
+		expanded[i].value = f.expand(h.value, ruleData(requestURL, h.urls, data, false))
+		expanded[i].to = f.expand(h.to, ruleData(requestURL, h.urls, data, h.regex != nil))
+	}
+
+	return expanded
+}
@@ -0,0 +1,248 @@
+package filter
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseTemplates(t *testing.T) {
+	tests := []struct {
+		name        string
+		c           Config
+		expectFatal bool
+		want        []string
+	}{
+		{
+			"no template",
+			Config{
+				Response: Caction{Replace: []Creplacement{{From: "a", To: "b"}}},
+			},
+			false,
+			nil,
+		},
+		{
+			"templates",
+			Config{
+				Replace: []Creplacement{{From: "a", To: "{{ .Request.Host }}"}},
+				Request: Caction{
+					Header: []Cheader{{Name: "X-Tenant", Value: `{{ .Request.Header "X-Tenant" }}`}},
+				},
+				Response: Caction{
+					HTML: []Creplacement{{From: "a", To: "b"}},
+				},
+			},
+			false,
+			[]string{"{{ .Request.Host }}", `{{ .Request.Header "X-Tenant" }}`},
+		},
+		{
+			"wrong template",
+			Config{
+				Response: Caction{Header: []Cheader{{Name: "X-Tenant", Value: "{{ .Request.Host "}}},
+			},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := parseTemplates(log, tt.c)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseTemplates() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if fatal {
+				return
+			}
+
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("parseTemplates() = %v, want nil", got)
+				}
+
+				return
+			}
+
+			if len(got) != len(tt.want) {
+				t.Errorf("parseTemplates() got %d templates, want %d", len(got), len(tt.want))
+			}
+
+			for _, w := range tt.want {
+				if _, ok := got[w]; !ok {
+					t.Errorf("parseTemplates() missing %s", w)
+				}
+			}
+		})
+	}
+}
+
+func Test_newTemplateData(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://upstream:8080/api/list?page=2", nil)
+	req.RemoteAddr = "192.168.1.12:34567"
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req = req.WithContext(context.WithValue(req.Context(), originalHostKey, "public.example.com"))
+
+	got := newTemplateData(req)
+
+	want := templateRequest{
+		Host:   "public.example.com",
+		Method: "POST",
+		Path:   "/api/list",
+		Query:  "page=2",
+		Scheme: "https",
+		header: req.Header,
+	}
+
+	if !reflect.DeepEqual(got.Request, want) {
+		t.Errorf("newTemplateData() = %#v, want %#v", got.Request, want)
+	}
+
+	if got.ClientIP != "192.168.1.12" {
+		t.Errorf("newTemplateData() ClientIP = %s, want 192.168.1.12", got.ClientIP)
+	}
+
+	if got.Request.Header("X-Tenant") != "acme" {
+		t.Errorf("templateRequest.Header() = %s, want acme", got.Request.Header("X-Tenant"))
+	}
+}
+
+func TestFilter_expandReplace(t *testing.T) {
+	os.Setenv("VILLIP_TEST_PUBLIC_URL", "https://public.example.com")
+	defer os.Unsetenv("VILLIP_TEST_PUBLIC_URL")
+
+	rep := []replaceParameters{
+		{from: "http://legacy", to: "a static value"},
+		{from: "http://legacy", to: `{{ env "VILLIP_TEST_PUBLIC_URL" }}`},
+		{
+			from: "http://legacy",
+			to:   `{{ .Request.Scheme }}://{{ .Request.Host }}/{{ index .URLMatch 1 }}/{{ .URLNamed.id }}`,
+			urls: []*regexp.Regexp{regexp.MustCompile("^/boomer"), regexp.MustCompile(`^/(\w+)/(?P<id>\d+)`)},
+		},
+		{from: "http://legacy", to: `{{ .Unknown }}`},
+	}
+	log, hook := logrustest.NewNullLogger()
+	templates := parseTemplates(log, Config{Replace: []Creplacement{{To: rep[1].to}, {To: rep[2].to}, {To: rep[3].to}}})
+	f := &Filter{log: log, templates: templates}
+
+	req, _ := http.NewRequest("GET", "http://upstream:8080/youngster/12", nil)
+	req.Host = "public.example.com"
+
+	got := f.expandReplace("/youngster/12", rep, newTemplateData(req))
+
+	want := []string{"a static value", "https://public.example.com", "http://public.example.com/youngster/12", rep[3].to}
+	for i, w := range want {
+		if got[i].to != w {
+			t.Errorf("Filter.expandReplace()[%d] = %s, want %s", i, got[i].to, w)
+		}
+	}
+
+	if rep[1].to != `{{ env "VILLIP_TEST_PUBLIC_URL" }}` {
+		t.Errorf("Filter.expandReplace() modified the original rules")
+	}
+
+	if !HadErrorLevel(hook, logrus.ErrorLevel) {
+		t.Errorf("Filter.expandReplace() should log an error for a failing template")
+	}
+}
+
+func TestFilter_expandHeaders(t *testing.T) {
+	headers := []headerRule{
+		{name: "X-Static", value: "static"},
+		{name: "X-Client", value: "{{ .ClientIP }}"},
+		{name: "X-Time", value: "{{ now | unixMilli }}"},
+	}
+	log, _ := logrustest.NewNullLogger()
+	templates := parseTemplates(log, Config{Response: Caction{Header: []Cheader{
+		{Value: headers[1].value},
+		{Value: headers[2].value},
+	}}})
+	f := &Filter{log: log, templates: templates}
+
+	req, _ := http.NewRequest("GET", "http://upstream:8080/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	before := time.Now().UnixMilli()
+	got := f.expandHeaders("/", headers, newTemplateData(req))
+
+	if got[0].value != "static" || got[1].value != "10.0.0.1" {
+		t.Errorf("Filter.expandHeaders() = %#v", got)
+	}
+
+	if ms, err := strconv.ParseInt(got[2].value, 10, 64); err != nil || ms < before {
+		t.Errorf("Filter.expandHeaders() time = %s, want a timestamp after %d", got[2].value, before)
+	}
+
+	if headers[1].value != "{{ .ClientIP }}" {
+		t.Errorf("Filter.expandHeaders() modified the original headers")
+	}
+}
+
+func TestFilter_expandReplaceRegex(t *testing.T) {
+	rep := []replaceParameters{
+		{from: "http://legacy/(\\w+)", to: "{{ .Request.Host }}/$1", regex: regexp.MustCompile("http://legacy/(\\w+)")},
+		{from: "http://legacy", to: "{{ .Request.Host }}"},
+	}
+	log, _ := logrustest.NewNullLogger()
+	templates := parseTemplates(log, Config{Replace: []Creplacement{{To: rep[0].to}, {To: rep[1].to}}})
+	f := &Filter{log: log, templates: templates}
+
+	req, _ := http.NewRequest("GET", "http://upstream:8080/", nil)
+	req.Host = "evil$1.example.com"
+
+	got := f.expandReplace("/", rep, newTemplateData(req))
+
+	// The $ sent by the client does not refer to the groups of the expression, the ones of the rule still do
+	if s := do("/", "http://legacy/books", got[:1], false); s != "evil$1.example.com/books" {
+		t.Errorf("Filter.expandReplace() regex replacement = %s", s)
+	}
+
+	if got[1].to != "evil$1.example.com" {
+		t.Errorf("Filter.expandReplace() literal replacement = %s", got[1].to)
+	}
+}
+
+func TestFilter_expandHeadersURLMatch(t *testing.T) {
+	headers := []headerRule{
+		{name: "X-Book", value: "{{ .URLNamed.id }}", urls: []*regexp.Regexp{regexp.MustCompile(`^/books/(?P<id>\d+)`)}},
+		{
+			name:  "Location",
+			from:  "^/(.*)$",
+			to:    "/{{ .Request.Header \"X-Tenant\" }}/$1",
+			regex: regexp.MustCompile("^/(.*)$"),
+		},
+	}
+	log, _ := logrustest.NewNullLogger()
+	templates := parseTemplates(log, Config{Response: Caction{Header: []Cheader{
+		{Value: headers[0].value},
+		{To: headers[1].to},
+	}}})
+	f := &Filter{log: log, templates: templates}
+
+	req, _ := http.NewRequest("GET", "http://upstream:8080/books/12", nil)
+	req.Header.Set("X-Tenant", "acme$1")
+
+	got := f.expandHeaders("/books/12", headers, newTemplateData(req))
+
+	if got[0].value != "12" {
+		t.Errorf("Filter.expandHeaders() URL group = %s, want 12", got[0].value)
+	}
+
+	if got[1].to != "/acme$$1/$1" {
+		t.Errorf("Filter.expandHeaders() regex replacement = %s, want /acme$$1/$1", got[1].to)
+	}
+}
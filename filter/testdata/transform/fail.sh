@@ -0,0 +1,4 @@
+#!/bin/sh
+echo "partial output"
+echo "cannot transform" >&2
+exit 3
@@ -0,0 +1,3 @@
+#!/bin/sh
+echo "tenant=$VILLIP_HEADER_X_TENANT url=$VILLIP_URL"
+cat
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transformer is a custom transformation of the requests and of the responses compiled in the programs embedding
+// villip. The transformers referenced by a filter are called for all its requests and responses, after the rules of
+// the configuration. A transformer replacing a body must also update its ContentLength and Content-Length header.
+type Transformer interface {
+	// TransformRequest modifies the request before it is sent to the upstream, an error is logged and the request
+	// is sent anyway.
+	TransformRequest(r *http.Request) error
+	// TransformResponse modifies the response before it is sent to the client, an error aborts the response
+	// (the client receives a 502 Bad Gateway).
+	TransformResponse(r *http.Response) error
+}
+
+var (
+	transformersMu sync.RWMutex
+	transformers   = map[string]Transformer{} //nolint: gochecknoglobals
+)
+
+// RegisterTransformer makes a transformer available to the configurations by its name. It panics if the name is
+// already used or if the transformer is nil, it must be called before the configurations are read.
+func RegisterTransformer(name string, t Transformer) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	if t == nil {
+		panic("villip: RegisterTransformer transformer is nil")
+	}
+
+	if _, dup := transformers[name]; dup {
+		panic("villip: RegisterTransformer called twice for transformer " + name)
+	}
+
+	transformers[name] = t
+}
+
+// unregisterTransformer removes a transformer from the registry, used by the tests.
+func unregisterTransformer(name string) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	delete(transformers, name)
+}
+
+// Transformers returns the sorted names of the registered transformers.
+func Transformers() []string {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// namedTransformer is a registered transformer used by a filter.
+type namedTransformer struct {
+	name        string
+	transformer Transformer
+}
+
+// parseTransformersConfig finds the registered transformers referenced by the configuration.
+func parseTransformersConfig(log logrus.FieldLogger, names []string) []namedTransformer {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	result := make([]namedTransformer, 0, len(names))
+
+	for _, name := range names {
+		t, ok := transformers[name]
+		if !ok {
+			log.Fatalf("Unknown transformer '%s'", name)
+
+			continue
+		}
+
+		result = append(result, namedTransformer{name: name, transformer: t})
+	}
+
+	return result
+}
+
+// transformRequest calls the transformers of the request in the order of the configuration.
+func (f *Filter) transformRequest(log logrus.FieldLogger, r *http.Request) {
+	for _, t := range f.request.Transformers {
+		if err := t.transformer.TransformRequest(r); err != nil {
+			log.WithField("transformer", t.name).Errorf("Transformer failed on the request: %v", err)
+		}
+	}
+}
+
+// transformResponse calls the transformers of the response in the order of the configuration, it stops at the
+// first error.
+func (f *Filter) transformResponse(log logrus.FieldLogger, r *http.Response) error {
+	for _, t := range f.response.Transformers {
+		if err := t.transformer.TransformResponse(r); err != nil {
+			log.WithField("transformer", t.name).Errorf("Transformer failed on the response: %v", err)
+
+			return fmt.Errorf("transformer %s: %w", t.name, err)
+		}
+	}
+
+	return nil
+}
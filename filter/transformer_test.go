@@ -0,0 +1,225 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// testTransformer records its calls in a shared trace.
+type testTransformer struct {
+	name  string
+	trace *[]string
+	err   error
+}
+
+func (t *testTransformer) TransformRequest(r *http.Request) error {
+	*t.trace = append(*t.trace, t.name+" request "+r.Header.Get("X-Env"))
+	r.Header.Set("X-Transformed-By", t.name)
+
+	return t.err
+}
+
+func (t *testTransformer) TransformResponse(r *http.Response) error {
+	b, _ := io.ReadAll(r.Body)
+	*t.trace = append(*t.trace, fmt.Sprintf("%s response %s", t.name, b))
+
+	body := strings.ToUpper(string(b))
+	r.Body = io.NopCloser(strings.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+
+	return t.err
+}
+
+func TestRegisterTransformer(t *testing.T) {
+	trace := []string{}
+	first := &testTransformer{name: "first", trace: &trace}
+
+	RegisterTransformer("test-register-b", first)
+	RegisterTransformer("test-register-a", first)
+	t.Cleanup(func() {
+		unregisterTransformer("test-register-a")
+		unregisterTransformer("test-register-b")
+	})
+
+	names := Transformers()
+	if !strings.Contains(strings.Join(names, ","), "test-register-a,test-register-b") {
+		t.Errorf("Transformers() = %v", names)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		transformer Transformer
+	}{
+		{"test-register-a", first},
+		{"test-register-nil", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterTransformer() did not panic")
+				}
+			}()
+
+			RegisterTransformer(tt.name, tt.transformer)
+		})
+	}
+}
+
+func Test_parseTransformersConfig(t *testing.T) {
+	trace := []string{}
+	one := &testTransformer{name: "one", trace: &trace}
+	RegisterTransformer("test-parse-one", one)
+	t.Cleanup(func() { unregisterTransformer("test-parse-one") })
+
+	tests := []struct {
+		name        string
+		names       []string
+		expectFatal bool
+		want        []namedTransformer
+	}{
+		{"registered", []string{"test-parse-one"}, false, []namedTransformer{{name: "test-parse-one", transformer: one}}},
+		{"unknown", []string{"test-parse-one", "test-parse-unknown"}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use logrus abilities to test log.Fatal
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+			defer func() { log.ExitFunc = nil }()
+
+			got := parseTransformersConfig(log, tt.names)
+
+			fatal := HadErrorLevel(hook, logrus.FatalLevel)
+			if fatal != tt.expectFatal {
+				t.Errorf("parseTransformersConfig() fatal got = %v, want %v", fatal, tt.expectFatal)
+			}
+
+			if !fatal && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTransformersConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_UpdateRequestTransformers(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+	trace := []string{}
+
+	f := &Filter{
+		url: "http://localhost:8080",
+		request: request{
+			Header: []headerRule{{name: "X-Env", value: "dev"}},
+			Transformers: []namedTransformer{
+				{name: "first", transformer: &testTransformer{name: "first", trace: &trace, err: errors.New("boom")}},
+				{name: "second", transformer: &testTransformer{name: "second", trace: &trace}},
+			},
+		},
+		log: log,
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	f.UpdateRequest(req)
+
+	// The transformers are called after the header rules, in order, and an error does not stop the pipeline
+	if want := []string{"first request dev", "second request dev"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("Filter.UpdateRequest() calls = %v, want %v", trace, want)
+	}
+
+	if req.Header.Get("X-Transformed-By") != "second" {
+		t.Errorf("Filter.UpdateRequest() X-Transformed-By = %s", req.Header.Get("X-Transformed-By"))
+	}
+
+	if !HadErrorLevel(hook, logrus.ErrorLevel) {
+		t.Errorf("Filter.UpdateRequest() error of the transformer not logged")
+	}
+}
+
+func TestFilter_UpdateResponseTransformers(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		err         error
+		wantTrace   []string
+		wantBody    string
+		wantErr     bool
+	}{
+		{
+			"filtered",
+			"text/html",
+			nil,
+			[]string{"first response a novel", "second response A NOVEL"},
+			"A NOVEL",
+			false,
+		},
+		{
+			"not filtered content type",
+			"image/png",
+			nil,
+			[]string{"first response a book", "second response A BOOK"},
+			"A BOOK",
+			false,
+		},
+		{
+			"error",
+			"text/html",
+			errors.New("boom"),
+			[]string{"first response a novel"},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, _ := logrustest.NewNullLogger()
+			trace := []string{}
+
+			f := &Filter{
+				response: response{
+					Replace: []replaceParameters{{from: "book", to: "novel"}},
+					Transformers: []namedTransformer{
+						{name: "first", transformer: &testTransformer{name: "first", trace: &trace, err: tt.err}},
+						{name: "second", transformer: &testTransformer{name: "second", trace: &trace}},
+					},
+				},
+				contentTypes: []string{"text/html"},
+				log:          log,
+			}
+
+			req, _ := http.NewRequest("GET", "http://localhost:8081/", nil)
+			r := http.Response{
+				Header:     http.Header{"Content-Type": []string{tt.contentType}},
+				StatusCode: http.StatusOK,
+				Request:    req,
+				Body:       io.NopCloser(bytes.NewBufferString("a book")),
+			}
+
+			err := f.UpdateResponse(&r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Filter.UpdateResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(trace, tt.wantTrace) {
+				t.Errorf("Filter.UpdateResponse() calls = %v, want %v", trace, tt.wantTrace)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			got, _ := io.ReadAll(r.Body)
+			if string(got) != tt.wantBody || r.Header.Get("Content-Length") != fmt.Sprint(len(tt.wantBody)) {
+				t.Errorf("Filter.UpdateResponse() = %s with length %s", got, r.Header.Get("Content-Length"))
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Default values of the connections to the upstreams.
+const (
+	defaultMaxIdleConnsPerHost = 16
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+)
+
+// parseTransportConfig returns the pool of connections to the upstreams shared by the requests of the filter.
+func parseTransportConfig(log logrus.FieldLogger, c Ctransport, insecure bool) *http.Transport {
+	if c.MaxIdleConnsPerHost < 0 {
+		log.Fatalf("%d is not a valid maxIdleConnsPerHost", c.MaxIdleConnsPerHost)
+	}
+
+	parseDuration := func(name string, value string, defaultValue time.Duration) time.Duration {
+		if value == "" {
+			return defaultValue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Fatalf("%s is not a valid %s", value, name)
+		}
+
+		return d
+	}
+
+	transport := newTransport(
+		parseDuration("dialTimeout", c.DialTimeout, defaultDialTimeout),
+		parseDuration("idleTimeout", c.IdleTimeout, defaultIdleConnTimeout),
+		insecure,
+	)
+
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+
+	transport.ResponseHeaderTimeout = parseDuration("responseHeaderTimeout", c.ResponseHeaderTimeout, 0)
+	// The HTTP/2 is negotiated with the TLS upstreams, the others are still called in HTTP/1.1
+	transport.ForceAttemptHTTP2 = c.HTTP2
+
+	if insecure {
+		log.Debug("Not checking SSL certificates")
+	}
+
+	return transport
+}
+
+// newTransport returns a pool of connections to the upstreams.
+func newTransport(dialTimeout time.Duration, idleTimeout time.Duration, insecure bool) *http.Transport {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: dialTimeout, KeepAlive: defaultKeepAlive}).DialContext,
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     idleTimeout,
+		TLSHandshakeTimeout: defaultTLSHandshakeTimeout,
+	}
+
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint: gosec
+	}
+
+	return transport
+}
+
+// newReverseProxy returns the proxy of the filter, it sends the requests to the upstream chosen for them.
+func (f *Filter) newReverseProxy(transport http.RoundTripper) *httputil.ReverseProxy {
+	directors := map[string]func(*http.Request){}
+
+	for _, upstream := range f.upstreamURLs() {
+		u, _ := url.Parse(upstream)
+		directors[upstream] = httputil.NewSingleHostReverseProxy(u).Director
+	}
+
+	if f.breakers != nil {
+		// Inside the retries, each attempt is counted and the retries stop when the circuit opens
+		transport = &circuitTransport{RoundTripper: transport, filter: f}
+	}
+
+	if f.retry != nil {
+		transport = &retryTransport{RoundTripper: transport, policy: f.retry, log: f.log}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director:      func(req *http.Request) { directors[f.upstreamURL(req)](req) },
+		Transport:     transport,
+		FlushInterval: f.flushInterval,
+		ErrorHandler:  f.proxyError,
+	}
+
+	if len(f.response.Replace) > 0 || len(f.response.Header) > 0 || len(f.response.JSON) > 0 || len(f.response.HTML) > 0 ||
+		len(f.response.Dictionary) > 0 || len(f.response.Inject) > 0 || len(f.response.Transform) > 0 ||
+		len(f.response.Transformers) > 0 ||
+		f.response.Cookies != nil || f.hasReversePrefix() || f.autoRebase ||
+		f.dumpFolder != "" || len(f.dumpURLs) != 0 {
+		proxy.ModifyResponse = f.UpdateResponse
+	}
+
+	if len(f.request.Replace) > 0 || len(f.request.Header) > 0 || len(f.request.JSON) > 0 || len(f.request.Dictionary) > 0 ||
+		len(f.request.Transform) > 0 || len(f.request.Transformers) > 0 ||
+		(f.response.Cookies != nil && len(f.response.Cookies.name) > 0) || f.autoRebase ||
+		f.dumpFolder != "" || len(f.dumpURLs) != 0 {
+		proxy.Director = f.UpdateRequest
+	}
+
+	if f.websocket == websocketFilter {
+		proxy.Transport = &websocketTransport{RoundTripper: transport, filter: f}
+	}
+
+	return proxy
+}
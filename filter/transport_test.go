@@ -0,0 +1,244 @@
+package filter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseTransportConfig(t *testing.T) {
+	tests := []struct {
+		name                      string
+		config                    Ctransport
+		wantFatal                 bool
+		wantMaxIdleConnsPerHost   int
+		wantIdleConnTimeout       time.Duration
+		wantResponseHeaderTimeout time.Duration
+		wantHTTP2                 bool
+	}{
+		{"defaults", Ctransport{}, false, 16, 90 * time.Second, 0, false},
+		{
+			"configured",
+			Ctransport{MaxIdleConnsPerHost: 64, IdleTimeout: "1m", DialTimeout: "5s", ResponseHeaderTimeout: "20s", HTTP2: true},
+			false,
+			64,
+			time.Minute,
+			20 * time.Second,
+			true,
+		},
+		{"negative idle connections", Ctransport{MaxIdleConnsPerHost: -1}, true, 0, 0, 0, false},
+		{"wrong idle timeout", Ctransport{IdleTimeout: "1"}, true, 0, 0, 0, false},
+		{"wrong dial timeout", Ctransport{DialTimeout: "-5s"}, true, 0, 0, 0, false},
+		{"wrong response header timeout", Ctransport{ResponseHeaderTimeout: "soon"}, true, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseTransportConfig(log, tt.config, false)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Fatalf("parseTransportConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if tt.wantFatal {
+				return
+			}
+
+			if got.MaxIdleConnsPerHost != tt.wantMaxIdleConnsPerHost || got.IdleConnTimeout != tt.wantIdleConnTimeout ||
+				got.ResponseHeaderTimeout != tt.wantResponseHeaderTimeout || got.ForceAttemptHTTP2 != tt.wantHTTP2 {
+				t.Errorf("parseTransportConfig() = %d %s %s %v", got.MaxIdleConnsPerHost, got.IdleConnTimeout,
+					got.ResponseHeaderTimeout, got.ForceAttemptHTTP2)
+			}
+
+			if got.TLSClientConfig != nil {
+				t.Errorf("parseTransportConfig() TLSClientConfig = %v", got.TLSClientConfig)
+			}
+		})
+	}
+}
+
+// countingBackend counts the connections opened by the proxy.
+func countingBackend(tls bool) (*httptest.Server, *atomic.Int64) {
+	var connections atomic.Int64
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("take your book"))
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+
+	if tls {
+		backend.StartTLS()
+	} else {
+		backend.Start()
+	}
+
+	return backend, &connections
+}
+
+func newTestProxyFilter(backendURL string, shared bool) *Filter {
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url:          backendURL,
+		insecure:     true,
+		log:          log,
+		contentTypes: []string{"text/html"},
+		status:       []int{http.StatusOK},
+		response:     response{Replace: []replaceParameters{{from: "book", to: "smartphone"}}},
+	}
+
+	if shared {
+		f.transport = parseTransportConfig(log, Ctransport{}, f.insecure)
+		f.proxy = f.newReverseProxy(f.transport)
+	}
+
+	return f
+}
+
+func TestFilter_ServeSharedProxy(t *testing.T) {
+	tests := []struct {
+		name            string
+		shared          bool
+		wantConnections int64
+	}{
+		{"shared proxy", true, 1},
+		{"proxy per request", false, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, connections := countingBackend(true)
+			defer backend.Close()
+
+			f := newTestProxyFilter(backend.URL, tt.shared)
+
+			for i := 0; i < 5; i++ {
+				w := httptest.NewRecorder()
+				f.Serve(w, httptest.NewRequest("GET", "http://localhost:8080/books", nil))
+
+				if got := w.Body.String(); got != "take your smartphone" {
+					t.Fatalf("Filter.Serve() = %q", got)
+				}
+			}
+
+			if got := connections.Load(); got != tt.wantConnections {
+				t.Errorf("Filter.Serve() opened %d connections, want %d", got, tt.wantConnections)
+			}
+		})
+	}
+}
+
+func TestFilter_newReverseProxy(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+	transport := newTransport(defaultDialTimeout, defaultIdleConnTimeout, false)
+
+	tests := []struct {
+		name          string
+		filter        *Filter
+		wantModify    bool
+		wantWebsocket bool
+	}{
+		{"no rule", &Filter{url: "http://app1", log: log}, false, false},
+		{
+			"response rule",
+			&Filter{url: "http://app1", log: log, response: response{Replace: []replaceParameters{{from: "a", to: "b"}}}},
+			true,
+			false,
+		},
+		{
+			"request rule and websocket",
+			&Filter{
+				url:       "http://app1",
+				log:       log,
+				request:   request{Replace: []replaceParameters{{from: "a", to: "b"}}},
+				websocket: websocketFilter,
+			},
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := tt.filter.newReverseProxy(transport)
+
+			if (proxy.ModifyResponse != nil) != tt.wantModify {
+				t.Errorf("Filter.newReverseProxy() ModifyResponse set = %v, want %v", proxy.ModifyResponse != nil, tt.wantModify)
+			}
+
+			if _, ok := proxy.Transport.(*websocketTransport); ok != tt.wantWebsocket {
+				t.Errorf("Filter.newReverseProxy() Transport = %T", proxy.Transport)
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/books", nil)
+			proxy.Director(req)
+
+			if req.URL.String() != "http://app1/books" {
+				t.Errorf("Filter.newReverseProxy() Director URL = %s", req.URL)
+			}
+		})
+	}
+}
+
+func TestFilter_printTransportInLog(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	f := &Filter{
+		log:     log,
+		timeout: 30 * time.Second,
+		retry:   &retryPolicy{attempts: 3, backoff: 100 * time.Millisecond, maxBackoff: 2 * time.Second, status: []int{502, 503}},
+		circuit: &circuitConfig{failures: 5, failurePercent: 50, window: 20, cooldown: 30 * time.Second, status: 503},
+	}
+	f.transport = parseTransportConfig(log, Ctransport{IdleTimeout: "1m", ResponseHeaderTimeout: "20s", HTTP2: true}, false)
+	f.printTransportInLog()
+
+	verifyLogged("Filter.printTransportInLog", []string{
+		"Keep up to 16 idle connections per upstream during 1m0s",
+		"Wait the response headers of the upstream during 20s",
+		"Use HTTP/2 with the TLS upstreams supporting it",
+		"Abort the requests to the upstream after 30s",
+		"Try the idempotent requests up to 3 times on connection errors or status [502 503] (backoff 100ms to 2s)",
+		"Open the circuit of an upstream after 5 consecutive failures or 50% of failures over 20 requests, answer 503 during 30s",
+	}, hook, t)
+}
+
+func benchmarkServe(b *testing.B, tls bool, shared bool) {
+	backend, _ := countingBackend(tls)
+	defer backend.Close()
+
+	f := newTestProxyFilter(backend.URL, shared)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			f.Serve(w, httptest.NewRequest("GET", "http://localhost:8080/books", nil))
+
+			if w.Code != http.StatusOK {
+				b.Fatalf("Filter.Serve() status = %d", w.Code)
+			}
+		}
+	})
+}
+
+// BenchmarkFilter_Serve compares the proxy shared by the requests with the proxy built for each request, the time
+// by operation is the latency, its inverse the throughput.
+func BenchmarkFilter_Serve(b *testing.B) {
+	b.Run("http/shared", func(b *testing.B) { benchmarkServe(b, false, true) })
+	b.Run("http/per-request", func(b *testing.B) { benchmarkServe(b, false, false) })
+	b.Run("https/shared", func(b *testing.B) { benchmarkServe(b, true, true) })
+	b.Run("https/per-request", func(b *testing.B) { benchmarkServe(b, true, false) })
+}
@@ -0,0 +1,487 @@
+package filter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marema31/villip/health"
+	"github.com/sirupsen/logrus"
+)
+
+// Load balancing strategies between the upstreams of a filter.
+const (
+	balanceRoundRobin       = "round-robin"
+	balanceRandom           = "random"
+	balanceLeastConnections = "least-connections"
+	balanceHash             = "hash"
+)
+
+// Default values of the active health checks.
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultUnhealthyThreshold  = 3
+	defaultHealthyThreshold    = 1
+)
+
+// Number of points of each upstream on the consistent hashing ring.
+const hashRingPointsPerUpstream = 100
+
+// Maximum size of the health check response read to reuse the connection.
+const healthCheckBodyLimit = 64 * 1024
+
+// Path of the health server giving the state of the upstreams.
+const upstreamStatusPath = "/upstreams"
+
+// upstream is one of the servers proxyfied by a filter.
+type upstream struct {
+	url     string
+	healthy atomic.Bool
+	active  atomic.Int64 // Requests in progress
+
+	mu        sync.Mutex // Protects the result of the health checks
+	failures  int        // Consecutive failed health checks
+	successes int        // Consecutive successful health checks
+	lastCheck time.Time
+	lastError string
+
+	breaker *circuitBreaker // nil if the filter has no circuit breaker
+}
+
+// healthCheck is the configuration of the active health checks of the upstreams.
+type healthCheck struct {
+	path               string
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int // Consecutive failures ejecting an upstream
+	healthyThreshold   int // Consecutive successes bringing back an ejected upstream
+	client             *http.Client
+}
+
+// ringPoint is a position of an upstream on the consistent hashing ring.
+type ringPoint struct {
+	hash     uint32
+	upstream *upstream
+}
+
+// upstreamPool balances the requests of a filter between its upstreams.
+type upstreamPool struct {
+	upstreams []*upstream
+	strategy  string
+	cookie    string // Consistent hashing key, the header is used if the cookie is absent
+	header    string
+	ring      []ringPoint
+	next      atomic.Uint64
+	check     *healthCheck // nil without active health checks
+}
+
+// parseUpstreamsConfig verifies the upstreams and the load balancing configuration.
+func parseUpstreamsConfig(log logrus.FieldLogger, urls []string, b Cbalancing, hc ChealthCheck, insecure bool) *upstreamPool {
+	p := &upstreamPool{strategy: b.Strategy, cookie: b.Cookie, header: b.Header}
+
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			log.Fatalf("%s is not a valid upstream URL", raw)
+
+			continue
+		}
+
+		up := &upstream{url: strings.TrimSuffix(raw, "/")}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+
+	switch p.strategy {
+	case "":
+		p.strategy = balanceRoundRobin
+	case balanceRoundRobin, balanceRandom, balanceLeastConnections:
+	case balanceHash:
+		if p.cookie == "" && p.header == "" {
+			log.Fatal("The hash load balancing needs a cookie or a header")
+		}
+
+		p.ring = hashRing(p.upstreams)
+	default:
+		log.Fatalf("Unknown load balancing strategy '%s' (%s, %s, %s or %s)",
+			p.strategy, balanceRoundRobin, balanceRandom, balanceLeastConnections, balanceHash)
+	}
+
+	if p.strategy != balanceHash && (p.cookie != "" || p.header != "") {
+		log.Fatal("The cookie and the header of the load balancing are only used by the hash strategy")
+	}
+
+	if hc.Path != "" {
+		p.check = parseHealthCheckConfig(log, hc, insecure)
+	}
+
+	return p
+}
+
+// parseHealthCheckConfig verifies the active health checks configuration.
+func parseHealthCheckConfig(log logrus.FieldLogger, hc ChealthCheck, insecure bool) *healthCheck {
+	check := &healthCheck{
+		path:               hc.Path,
+		interval:           defaultHealthCheckInterval,
+		timeout:            defaultHealthCheckTimeout,
+		unhealthyThreshold: defaultUnhealthyThreshold,
+		healthyThreshold:   defaultHealthyThreshold,
+	}
+
+	if !strings.HasPrefix(check.path, "/") {
+		log.Fatalf("The health check path %s must start with /", check.path)
+	}
+
+	var err error
+
+	if hc.Interval != "" {
+		if check.interval, err = time.ParseDuration(hc.Interval); err != nil || check.interval <= 0 {
+			log.Fatalf("%s is not a valid health check interval", hc.Interval)
+		}
+	}
+
+	if hc.Timeout != "" {
+		if check.timeout, err = time.ParseDuration(hc.Timeout); err != nil || check.timeout <= 0 {
+			log.Fatalf("%s is not a valid health check timeout", hc.Timeout)
+		}
+	}
+
+	if hc.UnhealthyThreshold < 0 || hc.HealthyThreshold < 0 {
+		log.Fatal("The health check thresholds cannot be negative")
+	}
+
+	if hc.UnhealthyThreshold > 0 {
+		check.unhealthyThreshold = hc.UnhealthyThreshold
+	}
+
+	if hc.HealthyThreshold > 0 {
+		check.healthyThreshold = hc.HealthyThreshold
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSHandshakeTimeout: check.timeout}
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint: gosec
+	}
+
+	check.client = &http.Client{
+		Timeout:   check.timeout,
+		Transport: transport,
+		// The redirections are answers of the upstream, they are not followed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	return check
+}
+
+func hashKey(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+
+	return h.Sum32()
+}
+
+// hashRing places each upstream at several points of the ring to spread the keys evenly.
+func hashRing(upstreams []*upstream) []ringPoint {
+	ring := make([]ringPoint, 0, len(upstreams)*hashRingPointsPerUpstream)
+
+	for _, up := range upstreams {
+		for i := 0; i < hashRingPointsPerUpstream; i++ {
+			ring = append(ring, ringPoint{hash: hashKey(fmt.Sprintf("%s#%d", up.url, i)), upstream: up})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	return ring
+}
+
+// available returns true if the upstream is neither ejected by the health checks nor by its circuit breaker.
+func (up *upstream) available() bool {
+	return up.healthy.Load() && (up.breaker == nil || up.breaker.available())
+}
+
+// healthyUpstreams returns the upstreams not ejected by the health checks or the circuit breakers.
+func (p *upstreamPool) healthyUpstreams() []*upstream {
+	healthy := make([]*upstream, 0, len(p.upstreams))
+
+	for _, up := range p.upstreams {
+		if up.available() {
+			healthy = append(healthy, up)
+		}
+	}
+
+	return healthy
+}
+
+// anyHealthy returns true if at least one upstream is not ejected by the health checks.
+func (p *upstreamPool) anyHealthy() bool {
+	for _, up := range p.upstreams {
+		if up.healthy.Load() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashValue returns the consistent hashing key of the request, an empty string if it has none.
+func (p *upstreamPool) hashValue(req *http.Request) string {
+	if p.cookie != "" {
+		if c, err := req.Cookie(p.cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+
+	if p.header != "" {
+		return req.Header.Get(p.header)
+	}
+
+	return ""
+}
+
+// pick chooses the upstream of the request, nil if all of them are ejected.
+func (p *upstreamPool) pick(req *http.Request) *upstream {
+	healthy := p.healthyUpstreams()
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	switch p.strategy {
+	case balanceRandom:
+		return healthy[rand.Intn(len(healthy))] //nolint: gosec
+	case balanceLeastConnections:
+		// The rotation of the starting point spreads the requests between the upstreams with the same load
+		start := int(p.next.Add(1) % uint64(len(healthy)))
+		best := healthy[start]
+
+		for i := 1; i < len(healthy); i++ {
+			if up := healthy[(start+i)%len(healthy)]; up.active.Load() < best.active.Load() {
+				best = up
+			}
+		}
+
+		return best
+	case balanceHash:
+		if key := p.hashValue(req); key != "" {
+			return p.ringUpstream(hashKey(key))
+		}
+	}
+
+	// Round robin, also used by the hash strategy for the requests without key
+	return healthy[(p.next.Add(1)-1)%uint64(len(healthy))]
+}
+
+// ringUpstream returns the first healthy upstream following the hash on the ring.
+func (p *upstreamPool) ringUpstream(h uint32) *upstream {
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+
+	for i := 0; i < len(p.ring); i++ {
+		if up := p.ring[(start+i)%len(p.ring)].upstream; up.available() {
+			return up
+		}
+	}
+
+	return nil
+}
+
+// start runs the active health checks until the context is canceled.
+func (p *upstreamPool) start(ctx context.Context, log logrus.FieldLogger) {
+	if p.check == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.check.interval)
+		defer ticker.Stop()
+
+		for {
+			p.checkAll(ctx, log)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkAll checks all the upstreams concurrently.
+func (p *upstreamPool) checkAll(ctx context.Context, log logrus.FieldLogger) {
+	var wg sync.WaitGroup
+
+	for _, up := range p.upstreams {
+		wg.Add(1)
+
+		go func(up *upstream) {
+			defer wg.Done()
+
+			p.record(log, up, p.check.probe(ctx, up.url))
+		}(up)
+	}
+
+	wg.Wait()
+}
+
+// probe requests the health check path of the upstream, the upstream is healthy if it answers without error status.
+func (hc *healthCheck) probe(ctx context.Context, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+hc.path, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	// Read the body to keep the connection reusable
+	_, _ = io.CopyN(io.Discard, res.Body, healthCheckBodyLimit)
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// record updates the state of the upstream with the result of a health check.
+func (p *upstreamPool) record(log logrus.FieldLogger, up *upstream, err error) {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	up.lastCheck = time.Now()
+
+	if err != nil {
+		up.lastError = err.Error()
+		up.successes = 0
+		up.failures++
+
+		if up.healthy.Load() && up.failures >= p.check.unhealthyThreshold {
+			up.healthy.Store(false)
+			log.WithField("upstream", up.url).Warnf("Upstream ejected after %d failed health checks: %v", up.failures, err)
+		}
+
+		return
+	}
+
+	up.lastError = ""
+	up.failures = 0
+	up.successes++
+
+	if !up.healthy.Load() && up.successes >= p.check.healthyThreshold {
+		up.healthy.Store(true)
+		log.WithField("upstream", up.url).Infof("Upstream back after %d successful health checks", up.successes)
+	}
+}
+
+// upstreamStatus is the state of an upstream displayed by the health server.
+type upstreamStatus struct {
+	URL            string `json:"url"`
+	Healthy        bool   `json:"healthy"`
+	ActiveRequests int64  `json:"activeRequests"`
+	LastCheck      string `json:"lastCheck,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+}
+
+// poolStatus is the state of the upstreams of a filter displayed by the health server.
+type poolStatus struct {
+	Port      string           `json:"port"`
+	Priority  string           `json:"priority"`
+	Strategy  string           `json:"strategy"`
+	Upstreams []upstreamStatus `json:"upstreams"`
+}
+
+// upstreamsStatus returns the state of the upstreams of the filter.
+func (f *Filter) upstreamsStatus() interface{} {
+	status := poolStatus{Port: f.port, Priority: f.priority, Strategy: f.upstreams.strategy}
+
+	for _, up := range f.upstreams.upstreams {
+		up.mu.Lock()
+		s := upstreamStatus{
+			URL:            up.url,
+			Healthy:        up.healthy.Load(),
+			ActiveRequests: up.active.Load(),
+			LastError:      up.lastError,
+		}
+
+		if !up.lastCheck.IsZero() {
+			s.LastCheck = up.lastCheck.Format(time.RFC3339)
+		}
+		up.mu.Unlock()
+
+		status.Upstreams = append(status.Upstreams, s)
+	}
+
+	return status
+}
+
+// Start runs the active health checks of the upstreams of the filter until Stop is called.
+func (f *Filter) Start() {
+	if f.upstreams == nil || f.stopChecks != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.stopChecks = cancel
+	f.upstreams.start(ctx, f.log)
+}
+
+// Stop stops the health checks started by Start.
+func (f *Filter) Stop() {
+	if f.stopChecks != nil {
+		f.stopChecks()
+		f.stopChecks = nil
+	}
+}
+
+// Statuses returns the functions giving the states of the filter served by the health server, by path.
+func (f *Filter) Statuses() map[string]health.StatusFunc {
+	statuses := map[string]health.StatusFunc{}
+
+	if f.upstreams != nil {
+		statuses[upstreamStatusPath] = f.upstreamsStatus
+	}
+
+	if f.circuit != nil {
+		statuses[circuitStatusPath] = f.circuitsStatus
+	}
+
+	return statuses
+}
+
+// upstreamURL returns the URL of the upstream chosen for the request.
+func (f *Filter) upstreamURL(r *http.Request) string {
+	if u, ok := r.Context().Value(upstreamKey).(string); ok {
+		return u
+	}
+
+	return f.url
+}
+
+// upstreamURLs returns the URLs of all the upstreams of the filter.
+func (f *Filter) upstreamURLs() []string {
+	if f.upstreams == nil {
+		return []string{f.url}
+	}
+
+	urls := make([]string, 0, len(f.upstreams.upstreams))
+	for _, up := range f.upstreams.upstreams {
+		urls = append(urls, up.url)
+	}
+
+	return urls
+}
@@ -0,0 +1,497 @@
+package filter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseUpstreamsConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		urls         []string
+		balancing    Cbalancing
+		healthCheck  ChealthCheck
+		wantFatal    bool
+		wantStrategy string
+		wantURLs     []string
+		wantCheck    *healthCheck
+	}{
+		{
+			"default strategy",
+			[]string{"http://app1:8080/", "http://app2:8080"},
+			Cbalancing{},
+			ChealthCheck{},
+			false,
+			balanceRoundRobin,
+			[]string{"http://app1:8080", "http://app2:8080"},
+			nil,
+		},
+		{
+			"hash by cookie",
+			[]string{"http://app1:8080", "http://app2:8080"},
+			Cbalancing{Strategy: balanceHash, Cookie: "SESSION"},
+			ChealthCheck{},
+			false,
+			balanceHash,
+			[]string{"http://app1:8080", "http://app2:8080"},
+			nil,
+		},
+		{
+			"health check defaults",
+			[]string{"http://app1:8080"},
+			Cbalancing{Strategy: balanceLeastConnections},
+			ChealthCheck{Path: "/health"},
+			false,
+			balanceLeastConnections,
+			[]string{"http://app1:8080"},
+			&healthCheck{path: "/health", interval: 10 * time.Second, timeout: 2 * time.Second, unhealthyThreshold: 3, healthyThreshold: 1},
+		},
+		{
+			"health check",
+			[]string{"http://app1:8080"},
+			Cbalancing{Strategy: balanceRandom},
+			ChealthCheck{Path: "/health", Interval: "1s", Timeout: "500ms", UnhealthyThreshold: 1, HealthyThreshold: 2},
+			false,
+			balanceRandom,
+			[]string{"http://app1:8080"},
+			&healthCheck{path: "/health", interval: time.Second, timeout: 500 * time.Millisecond, unhealthyThreshold: 1, healthyThreshold: 2},
+		},
+		{"invalid url", []string{"app1:8080"}, Cbalancing{}, ChealthCheck{}, true, "", nil, nil},
+		{"unknown strategy", []string{"http://app1"}, Cbalancing{Strategy: "sticky"}, ChealthCheck{}, true, "", nil, nil},
+		{"hash without key", []string{"http://app1"}, Cbalancing{Strategy: balanceHash}, ChealthCheck{}, true, "", nil, nil},
+		{"key without hash", []string{"http://app1"}, Cbalancing{Header: "X-User"}, ChealthCheck{}, true, "", nil, nil},
+		{"relative path", []string{"http://app1"}, Cbalancing{}, ChealthCheck{Path: "health"}, true, "", nil, nil},
+		{"wrong interval", []string{"http://app1"}, Cbalancing{}, ChealthCheck{Path: "/", Interval: "10"}, true, "", nil, nil},
+		{"wrong timeout", []string{"http://app1"}, Cbalancing{}, ChealthCheck{Path: "/", Timeout: "-1s"}, true, "", nil, nil},
+		{"negative threshold", []string{"http://app1"}, Cbalancing{}, ChealthCheck{Path: "/", HealthyThreshold: -1}, true, "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+			log.ExitFunc = func(int) { return }
+
+			got := parseUpstreamsConfig(log, tt.urls, tt.balancing, tt.healthCheck, false)
+
+			if HadErrorLevel(hook, logrus.FatalLevel) != tt.wantFatal {
+				t.Fatalf("parseUpstreamsConfig() fatal = %v, want %v", !tt.wantFatal, tt.wantFatal)
+			}
+
+			if tt.wantFatal {
+				return
+			}
+
+			if got.strategy != tt.wantStrategy {
+				t.Errorf("parseUpstreamsConfig() strategy = %v, want %v", got.strategy, tt.wantStrategy)
+			}
+
+			urls := []string{}
+			for _, up := range got.upstreams {
+				urls = append(urls, up.url)
+
+				if !up.healthy.Load() {
+					t.Errorf("parseUpstreamsConfig() upstream %s is not healthy", up.url)
+				}
+			}
+
+			if !reflect.DeepEqual(urls, tt.wantURLs) {
+				t.Errorf("parseUpstreamsConfig() urls = %v, want %v", urls, tt.wantURLs)
+			}
+
+			if tt.wantStrategy == balanceHash && len(got.ring) != len(tt.wantURLs)*hashRingPointsPerUpstream {
+				t.Errorf("parseUpstreamsConfig() ring has %d points", len(got.ring))
+			}
+
+			if got.check != nil {
+				got.check.client = nil
+			}
+
+			if !reflect.DeepEqual(got.check, tt.wantCheck) {
+				t.Errorf("parseUpstreamsConfig() check = %#v, want %#v", got.check, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func newTestPool(strategy string, urls ...string) *upstreamPool {
+	p := &upstreamPool{strategy: strategy, cookie: "SESSION", header: "X-User"}
+
+	for _, u := range urls {
+		up := &upstream{url: u}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+
+	p.ring = hashRing(p.upstreams)
+
+	return p
+}
+
+func pickURLs(p *upstreamPool, req *http.Request, n int) []string {
+	urls := []string{}
+
+	for i := 0; i < n; i++ {
+		if up := p.pick(req); up != nil {
+			urls = append(urls, up.url)
+		} else {
+			urls = append(urls, "")
+		}
+	}
+
+	return urls
+}
+
+func Test_upstreamPool_pick(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://localhost/", nil)
+
+	t.Run("round robin", func(t *testing.T) {
+		p := newTestPool(balanceRoundRobin, "a", "b", "c")
+
+		if got := pickURLs(p, req, 4); !reflect.DeepEqual(got, []string{"a", "b", "c", "a"}) {
+			t.Errorf("upstreamPool.pick() = %v", got)
+		}
+
+		p.upstreams[1].healthy.Store(false)
+
+		if got := pickURLs(p, req, 4); !reflect.DeepEqual(got, []string{"a", "c", "a", "c"}) {
+			t.Errorf("upstreamPool.pick() with an ejected upstream = %v", got)
+		}
+
+		for _, up := range p.upstreams {
+			up.healthy.Store(false)
+		}
+
+		if got := p.pick(req); got != nil {
+			t.Errorf("upstreamPool.pick() without healthy upstream = %v", got.url)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		p := newTestPool(balanceRandom, "a", "b", "c")
+		p.upstreams[0].healthy.Store(false)
+
+		for _, got := range pickURLs(p, req, 20) {
+			if got != "b" && got != "c" {
+				t.Errorf("upstreamPool.pick() = %v", got)
+			}
+		}
+	})
+
+	t.Run("least connections", func(t *testing.T) {
+		p := newTestPool(balanceLeastConnections, "a", "b", "c")
+		p.upstreams[0].active.Store(3)
+		p.upstreams[1].active.Store(1)
+		p.upstreams[2].active.Store(2)
+
+		if got := pickURLs(p, req, 3); !reflect.DeepEqual(got, []string{"b", "b", "b"}) {
+			t.Errorf("upstreamPool.pick() = %v", got)
+		}
+
+		p.upstreams[1].active.Store(2)
+
+		// Ties are spread between the upstreams
+		got := map[string]bool{}
+		for _, u := range pickURLs(p, req, 4) {
+			got[u] = true
+		}
+
+		if !reflect.DeepEqual(got, map[string]bool{"b": true, "c": true}) {
+			t.Errorf("upstreamPool.pick() with ties = %v", got)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		p := newTestPool(balanceHash, "a", "b", "c")
+
+		byCookie := httptest.NewRequest("GET", "http://localhost/", nil)
+		byCookie.AddCookie(&http.Cookie{Name: "SESSION", Value: "alice"})
+		byCookie.Header.Set("X-User", "bob")
+
+		byHeader := httptest.NewRequest("GET", "http://localhost/", nil)
+		byHeader.Header.Set("X-User", "alice")
+
+		first := p.pick(byCookie).url
+		if got := pickURLs(p, byCookie, 5); !reflect.DeepEqual(got, []string{first, first, first, first, first}) {
+			t.Errorf("upstreamPool.pick() by cookie = %v", got)
+		}
+
+		// The cookie has precedence, the same key gives the same upstream
+		if got := p.pick(byHeader).url; got != first {
+			t.Errorf("upstreamPool.pick() by header = %v, want %v", got, first)
+		}
+
+		for _, up := range p.upstreams {
+			if up.url == first {
+				up.healthy.Store(false)
+			}
+		}
+
+		second := p.pick(byCookie).url
+		if second == first || p.pick(byCookie).url != second {
+			t.Errorf("upstreamPool.pick() with the upstream ejected = %v", second)
+		}
+
+		// Without key the requests are balanced in round robin
+		if got := pickURLs(p, req, 2); got[0] == got[1] {
+			t.Errorf("upstreamPool.pick() without key = %v", got)
+		}
+	})
+}
+
+func Test_upstreamPool_record(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	p := newTestPool(balanceRoundRobin, "http://app1")
+	p.check = &healthCheck{unhealthyThreshold: 2, healthyThreshold: 2}
+	up := p.upstreams[0]
+
+	steps := []struct {
+		err         error
+		wantHealthy bool
+	}{
+		{io.ErrUnexpectedEOF, true},
+		{nil, true},
+		{io.ErrUnexpectedEOF, true},
+		{io.ErrUnexpectedEOF, false},
+		{io.ErrUnexpectedEOF, false},
+		{nil, false},
+		{nil, true},
+	}
+	for i, s := range steps {
+		p.record(log, up, s.err)
+
+		if up.healthy.Load() != s.wantHealthy {
+			t.Errorf("upstreamPool.record() step %d healthy = %v, want %v", i, up.healthy.Load(), s.wantHealthy)
+		}
+	}
+
+	verifyLogged("upstreamPool.record", []string{
+		"Upstream ejected after 2 failed health checks: unexpected EOF",
+		"Upstream back after 2 successful health checks",
+	}, hook, t)
+}
+
+func Test_upstreamPool_checkAll(t *testing.T) {
+	var paths []string
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	log, _ := logrustest.NewNullLogger()
+	p := parseUpstreamsConfig(log, []string{up.URL, down.URL, "http://127.0.0.1:1"}, Cbalancing{},
+		ChealthCheck{Path: "/health", UnhealthyThreshold: 1}, false)
+
+	p.checkAll(context.Background(), log)
+
+	if !reflect.DeepEqual(paths, []string{"/health"}) {
+		t.Errorf("upstreamPool.checkAll() requested %v", paths)
+	}
+
+	want := []bool{true, false, false}
+	for i, u := range p.upstreams {
+		if u.healthy.Load() != want[i] {
+			t.Errorf("upstreamPool.checkAll() %s healthy = %v, want %v", u.url, u.healthy.Load(), want[i])
+		}
+	}
+
+	if p.upstreams[1].lastError != "status 503" || p.upstreams[0].lastCheck.IsZero() {
+		t.Errorf("upstreamPool.checkAll() state = %q %v", p.upstreams[1].lastError, p.upstreams[0].lastCheck)
+	}
+}
+
+func TestFilter_ServeUpstreams(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(name + " at http://" + r.Host + r.URL.Path))
+		}))
+	}
+
+	app1 := backend("app1")
+	defer app1.Close()
+
+	app2 := backend("app2")
+	defer app2.Close()
+
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		url:          app1.URL,
+		log:          log,
+		autoRebase:   true,
+		publicURL:    "https://public.example.com",
+		contentTypes: []string{"text/html"},
+		status:       []int{http.StatusOK},
+		upstreams:    parseUpstreamsConfig(log, []string{app1.URL, app2.URL}, Cbalancing{}, ChealthCheck{}, false),
+	}
+
+	front := httptest.NewServer(http.HandlerFunc(f.Serve))
+	defer front.Close()
+
+	get := func() (int, string) {
+		res, err := http.Get(front.URL + "/books")
+		if err != nil {
+			t.Fatalf("Get error = %v", err)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+
+		return res.StatusCode, string(body)
+	}
+
+	// The URLs of each upstream are rebased
+	for _, want := range []string{
+		"app1 at https://public.example.com/books",
+		"app2 at https://public.example.com/books",
+		"app1 at https://public.example.com/books",
+	} {
+		if _, got := get(); got != want {
+			t.Errorf("Filter.Serve() = %q, want %q", got, want)
+		}
+	}
+
+	for _, up := range f.upstreams.upstreams {
+		up.healthy.Store(false)
+	}
+
+	if status, got := get(); status != http.StatusServiceUnavailable || got != "No healthy upstream\n" {
+		t.Errorf("Filter.Serve() without healthy upstream = %d %q", status, got)
+	}
+}
+
+func TestFilter_StartStop(t *testing.T) {
+	checks := make(chan struct{}, 100)
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks <- struct{}{}
+	}))
+	defer up.Close()
+
+	log, _ := logrustest.NewNullLogger()
+	f := &Filter{
+		log:       log,
+		upstreams: parseUpstreamsConfig(log, []string{up.URL}, Cbalancing{}, ChealthCheck{Path: "/health", Interval: "10ms"}, false),
+		circuit:   &circuitConfig{failures: 1, window: 20, cooldown: time.Minute},
+	}
+
+	if got := f.Statuses(); len(got) != 2 || got[upstreamStatusPath] == nil || got[circuitStatusPath] == nil {
+		t.Errorf("Filter.Statuses() = %v", got)
+	}
+
+	// The health checks are not running before Start
+	select {
+	case <-checks:
+		t.Fatalf("health check before Filter.Start()")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	f.Start()
+
+	select {
+	case <-checks:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no health check after Filter.Start()")
+	}
+
+	f.Stop()
+
+	// A check may have been running during Stop
+	time.Sleep(30 * time.Millisecond)
+
+	for len(checks) > 0 {
+		<-checks
+	}
+
+	select {
+	case <-checks:
+		t.Errorf("health check after Filter.Stop()")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFilter_upstreamsStatus(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	f := &Filter{
+		port:      "8080",
+		priority:  "10",
+		upstreams: parseUpstreamsConfig(log, []string{"http://app1", "http://app2"}, Cbalancing{}, ChealthCheck{}, false),
+	}
+
+	f.upstreams.upstreams[0].active.Store(2)
+	f.upstreams.upstreams[1].healthy.Store(false)
+	f.upstreams.upstreams[1].lastError = "status 500"
+	f.upstreams.upstreams[1].lastCheck = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	want := poolStatus{
+		Port:     "8080",
+		Priority: "10",
+		Strategy: balanceRoundRobin,
+		Upstreams: []upstreamStatus{
+			{URL: "http://app1", Healthy: true, ActiveRequests: 2},
+			{URL: "http://app2", LastCheck: "2024-05-01T10:00:00Z", LastError: "status 500"},
+		},
+	}
+
+	if got := f.upstreamsStatus(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter.upstreamsStatus() = %#v, want %#v", got, want)
+	}
+}
+
+func TestFilter_printUpstreamsInLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		balancing Cbalancing
+		check     ChealthCheck
+		want      []string
+	}{
+		{
+			"round robin",
+			Cbalancing{},
+			ChealthCheck{},
+			[]string{"Balance the requests (round-robin) between:", "    http://app1", "    http://app2"},
+		},
+		{
+			"hash",
+			Cbalancing{Strategy: balanceHash, Cookie: "SESSION", Header: "X-User"},
+			ChealthCheck{Path: "/health"},
+			[]string{
+				"Balance the requests by hash of the SESSION cookie or of the X-User header between:",
+				"    http://app1",
+				"    http://app2",
+				"Check /health every 10s (timeout 2s), eject after 3 failures and bring back after 1 successes",
+			},
+		},
+		{
+			"hash by header",
+			Cbalancing{Strategy: balanceHash, Header: "X-User"},
+			ChealthCheck{},
+			[]string{"Balance the requests by hash of the X-User header between:", "    http://app1", "    http://app2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := logrustest.NewNullLogger()
+
+			f := &Filter{log: log, upstreams: parseUpstreamsConfig(log, []string{"http://app1", "http://app2"}, tt.balancing, tt.check, false)}
+			f.printUpstreamsInLog()
+
+			verifyLogged("Filter.printUpstreamsInLog", tt.want, hook, t)
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package filter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Modes of the WebSocket proxying.
+const (
+	websocketPassthrough = "passthrough"
+	websocketFilter      = "filter"
+)
+
+// WebSocket frame opcodes (RFC 6455).
+const (
+	wsContinuation byte = 0x0
+	wsText         byte = 0x1
+	wsBinary       byte = 0x2
+	wsClose        byte = 0x8
+)
+
+// Maximum size of a text message gathered to be filtered.
+const wsMaxMessageSize = 16 * 1024 * 1024
+
+// Status of the close frame sent when a text message is too large to be filtered (RFC 6455).
+const wsStatusTooBig = 1009
+
+var errWSMessageTooLarge = errors.New("websocket text message too large to be filtered")
+
+// isWebSocketUpgrade returns true if the request asks for a WebSocket connection.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// wsHeader is the header of a WebSocket frame.
+type wsHeader struct {
+	fin    bool
+	rsv    byte // RSV1-3 bits, set by the extensions
+	opcode byte
+	masked bool
+	mask   [4]byte
+	length uint64 // Length of the payload
+	size   int    // Length of the header
+}
+
+// wsFrame is a WebSocket frame with its payload unmasked.
+type wsFrame struct {
+	fin     bool
+	rsv     byte // RSV1-3 bits, set by the extensions
+	opcode  byte
+	masked  bool
+	mask    [4]byte
+	payload []byte
+}
+
+// parseWSHeader decodes the frame header at the beginning of b, it returns false if the header is not complete.
+func parseWSHeader(b []byte) (*wsHeader, bool) {
+	if len(b) < 2 {
+		return nil, false
+	}
+
+	h := &wsHeader{fin: b[0]&0x80 != 0, rsv: b[0] & 0x70, opcode: b[0] & 0x0f, masked: b[1]&0x80 != 0}
+	h.length = uint64(b[1] & 0x7f)
+	h.size = 2
+
+	switch h.length {
+	case 126:
+		if len(b) < h.size+2 {
+			return nil, false
+		}
+
+		h.length = uint64(binary.BigEndian.Uint16(b[h.size:]))
+		h.size += 2
+	case 127:
+		if len(b) < h.size+8 {
+			return nil, false
+		}
+
+		h.length = binary.BigEndian.Uint64(b[h.size:])
+		h.size += 8
+	}
+
+	if h.masked {
+		if len(b) < h.size+4 {
+			return nil, false
+		}
+
+		copy(h.mask[:], b[h.size:])
+		h.size += 4
+	}
+
+	return h, true
+}
+
+// parseWSFrame decodes the frame at the beginning of b, it returns the number of bytes used or 0 if the frame is
+// not complete.
+func parseWSFrame(b []byte) (*wsFrame, int) {
+	h, ok := parseWSHeader(b)
+	if !ok || uint64(len(b)-h.size) < h.length {
+		return nil, 0
+	}
+
+	end := h.size + int(h.length)
+	fr := &wsFrame{fin: h.fin, rsv: h.rsv, opcode: h.opcode, masked: h.masked, mask: h.mask}
+	fr.payload = append([]byte(nil), b[h.size:end]...)
+
+	if fr.masked {
+		maskBytes(fr.mask, fr.payload)
+	}
+
+	return fr, end
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// encode returns the frame as sent on the wire, the payload is masked again with the same key if needed.
+func (fr *wsFrame) encode() []byte {
+	var b bytes.Buffer
+
+	first := fr.rsv | fr.opcode
+	if fr.fin {
+		first |= 0x80
+	}
+
+	b.WriteByte(first)
+
+	var maskBit byte
+	if fr.masked {
+		maskBit = 0x80
+	}
+
+	switch length := len(fr.payload); {
+	case length < 126:
+		b.WriteByte(maskBit | byte(length))
+	case length <= 0xffff:
+		b.WriteByte(maskBit | 126)
+		_ = binary.Write(&b, binary.BigEndian, uint16(length))
+	default:
+		b.WriteByte(maskBit | 127)
+		_ = binary.Write(&b, binary.BigEndian, uint64(length))
+	}
+
+	payload := append([]byte(nil), fr.payload...)
+
+	if fr.masked {
+		b.Write(fr.mask[:])
+		maskBytes(fr.mask, payload)
+	}
+
+	b.Write(payload)
+
+	return b.Bytes()
+}
+
+// wsCloseFrame returns a close frame with the status, masked with a random key for the frames sent to the upstream.
+func wsCloseFrame(status uint16, masked bool) []byte {
+	fr := &wsFrame{fin: true, opcode: wsClose, masked: masked, payload: binary.BigEndian.AppendUint16(nil, status)}
+
+	if masked {
+		_, _ = rand.Read(fr.mask[:])
+	}
+
+	return fr.encode()
+}
+
+// wsDirection filters the text messages going in one direction of a WebSocket connection.
+type wsDirection struct {
+	in        []byte   // Incomplete header or text frame kept for the next call
+	message   *wsFrame // Fragmented text message being gathered, nil if there is none
+	remaining uint64   // Payload bytes of the forwarded frame not received yet
+	filter    func(string) string
+	frames    atomic.Int64
+	messages  atomic.Int64 // Text messages filtered
+}
+
+// filtered returns true if the frame is part of a text message to filter, the other frames are forwarded as is.
+func (d *wsDirection) filtered(h *wsHeader) bool {
+	return (h.opcode == wsText && h.rsv == 0) || (h.opcode == wsContinuation && d.message != nil)
+}
+
+// process consumes the data received and returns the data to forward. The payload of the frames which are not
+// filtered is forwarded as soon as it is received, only an incomplete header or text frame is kept for the next call.
+func (d *wsDirection) process(data []byte) ([]byte, error) {
+	owned := len(d.in) > 0
+	if owned {
+		d.in = append(d.in, data...)
+		data = d.in
+	}
+
+	var out bytes.Buffer
+
+	consumed := 0
+
+	for consumed < len(data) {
+		if d.remaining > 0 {
+			n := min(uint64(len(data)-consumed), d.remaining)
+			out.Write(data[consumed : consumed+int(n)])
+			consumed += int(n)
+			d.remaining -= n
+
+			continue
+		}
+
+		h, ok := parseWSHeader(data[consumed:])
+		if !ok {
+			break
+		}
+
+		if !d.filtered(h) {
+			// Binary, control and compressed frames are forwarded as is
+			d.frames.Add(1)
+			out.Write(data[consumed : consumed+h.size])
+			consumed += h.size
+			d.remaining = h.length
+
+			continue
+		}
+
+		size := h.length
+		if d.message != nil && h.opcode == wsContinuation {
+			size += uint64(len(d.message.payload))
+		}
+
+		if size > wsMaxMessageSize {
+			return out.Bytes(), errWSMessageTooLarge
+		}
+
+		fr, n := parseWSFrame(data[consumed:])
+		if n == 0 {
+			break
+		}
+
+		consumed += n
+		d.frames.Add(1)
+
+		switch {
+		case fr.opcode == wsText && fr.fin:
+			out.Write(d.filterMessage(fr))
+		case fr.opcode == wsText:
+			d.message = fr
+		default:
+			d.message.payload = append(d.message.payload, fr.payload...)
+
+			if fr.fin {
+				d.message.fin = true
+				out.Write(d.filterMessage(d.message))
+				d.message = nil
+			}
+		}
+	}
+
+	switch {
+	case consumed == len(data):
+		d.in = nil
+	case !owned || consumed > 0:
+		// Only the incomplete frame is kept, the data already buffered is not copied again while it grows
+		d.in = append(d.in[:0:0], data[consumed:]...)
+	}
+
+	return out.Bytes(), nil
+}
+
+func (d *wsDirection) filterMessage(fr *wsFrame) []byte {
+	d.messages.Add(1)
+	fr.payload = []byte(d.filter(string(fr.payload)))
+
+	return fr.encode()
+}
+
+// wsConn wraps the upstream connection of an upgraded request, the messages read from it come from the upstream and
+// the messages written to it come from the client.
+type wsConn struct {
+	upstream   io.ReadWriteCloser
+	fromClient *wsDirection
+	toClient   *wsDirection
+	log        logrus.FieldLogger
+	buf        []byte       // Reused by each read of upstream
+	out        bytes.Buffer // Filtered data from the upstream not yet read
+	readDone   bool         // The upstream sent a message too large, the client got a close frame
+	writeDone  bool         // The client sent a message too large, the upstream got a close frame
+	onClose    func()
+	closeOnce  sync.Once
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.out.Len() == 0 {
+		if c.readDone {
+			return 0, io.EOF
+		}
+
+		if c.buf == nil {
+			c.buf = make([]byte, streamChunkSize)
+		}
+
+		n, err := c.upstream.Read(c.buf)
+		if n > 0 {
+			data, perr := c.toClient.process(c.buf[:n])
+			c.out.Write(data)
+
+			if perr != nil {
+				c.log.Warnf("Closing the WebSocket connection: %v from the upstream", perr)
+				c.out.Write(wsCloseFrame(wsStatusTooBig, false))
+				c.readDone = true
+			}
+		}
+
+		if err != nil && c.out.Len() == 0 {
+			return 0, err
+		}
+	}
+
+	return c.out.Read(p)
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if c.writeDone {
+		// Waiting for the close frame of the upstream
+		return len(p), nil
+	}
+
+	data, err := c.fromClient.process(p)
+	if err != nil {
+		c.log.Warnf("Closing the WebSocket connection: %v from the client", err)
+		data = append(data, wsCloseFrame(wsStatusTooBig, true)...)
+		c.writeDone = true
+	}
+
+	if _, err := c.upstream.Write(data); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(c.onClose)
+
+	return c.upstream.Close()
+}
+
+// websocketTransport filters the WebSocket connections established through the transport.
+type websocketTransport struct {
+	http.RoundTripper
+	filter *Filter
+}
+
+func (t *websocketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusSwitchingProtocols || !isWebSocketUpgrade(req) {
+		return res, err
+	}
+
+	if upstream, ok := res.Body.(io.ReadWriteCloser); ok {
+		res.Body = t.filter.newWebSocketConn(req, res, upstream)
+	}
+
+	return res, nil
+}
+
+// newWebSocketConn returns the connection to the upstream filtering the text messages with the replacement rules.
+func (f *Filter) newWebSocketConn(req *http.Request, res *http.Response, upstream io.ReadWriteCloser) *wsConn {
+	requestURL := strings.TrimPrefix(req.URL.String(), f.upstreamURL(req))
+	log := f.log.WithFields(logrus.Fields{"url": req.URL.String(), "action": "websocket", "source": req.RemoteAddr})
+	tmplData := newTemplateData(req)
+
+	requestRules := bodyRules{
+		replace:      f.expandReplace(requestURL, selectReplace(f.request.Replace, newRuleContext(req, nil)), tmplData),
+		rebase:       f.rebaseRules(req, false),
+		dictionaries: selectDictionaries(f.request.Dictionary, newRuleContext(req, nil)),
+	}
+
+	responseCtx := newRuleContext(req, res)
+	responseRules := bodyRules{
+		replace:      f.expandReplace(requestURL, selectReplace(f.response.Replace, responseCtx), tmplData),
+		rebase:       f.rebaseRules(req, true),
+		dictionaries: selectDictionaries(f.response.Dictionary, responseCtx),
+	}
+
+	text := func(rules bodyRules) func(string) string {
+		return func(message string) string {
+			modified := _do(requestURL, message, rules.replace, false)
+			modified = f.applyDictionaries(requestURL, rules.dictionaries, modified)
+
+			return _do(requestURL, modified, rules.rebase, false)
+		}
+	}
+
+	c := &wsConn{
+		upstream:   upstream,
+		log:        log,
+		fromClient: &wsDirection{filter: text(requestRules)},
+		toClient:   &wsDirection{filter: text(responseRules)},
+	}
+
+	requestID := ""
+	if f.dumpFolder != "" || len(f.dumpURLs) != 0 {
+		requestID = f.dumpWebSocket(requestID, requestURL, "websocketOpen", req.Header, "")
+	}
+
+	log.WithField("requestID", requestID).Info("WebSocket opened")
+
+	c.onClose = func() {
+		summary := fmt.Sprintf(
+			"%d frames (%d text messages filtered) from the client, %d frames (%d text messages filtered) from the upstream",
+			c.fromClient.frames.Load(), c.fromClient.messages.Load(), c.toClient.frames.Load(), c.toClient.messages.Load(),
+		)
+
+		if requestID != "" {
+			f.dumpWebSocket(requestID, requestURL, "websocketClose", res.Header, summary+"\n")
+		}
+
+		log.WithField("requestID", requestID).Info("WebSocket closed after " + summary)
+	}
+
+	return c
+}
+
+// dumpWebSocket dumps an event of a WebSocket connection, the identifier is generated if requestID is empty.
+func (f *Filter) dumpWebSocket(requestID string, url string, event string, header http.Header, body string) string {
+	if requestID == "" {
+		rID, err := _generateID()
+		if err != nil {
+			f.log.Fatalf("Failed to generate requestId: %v", err)
+		}
+
+		requestID = rID
+	}
+
+	if !f.isDumpURL(url) {
+		return requestID
+	}
+
+	if f.dumpFolder != "" {
+		return f.dumpToFile(event, requestID, url, header, body)
+	}
+
+	return f.dumpToLog(event, requestID, url, header, body)
+}
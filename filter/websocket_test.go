@@ -0,0 +1,459 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_isWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"upgrade", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, true},
+		{"token list", http.Header{"Connection": {"keep-alive, upgrade"}, "Upgrade": {"WebSocket"}}, true},
+		{"other protocol", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"h2c"}}, false},
+		{"no connection upgrade", http.Header{"Connection": {"keep-alive"}, "Upgrade": {"websocket"}}, false},
+		{"plain request", http.Header{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWebSocketUpgrade(&http.Request{Header: tt.header}); got != tt.want {
+				t.Errorf("isWebSocketUpgrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_wsFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame wsFrame
+	}{
+		{"small unmasked", wsFrame{fin: true, opcode: wsText, payload: []byte("hello")}},
+		{"small masked", wsFrame{fin: true, opcode: wsText, masked: true, mask: [4]byte{1, 2, 3, 4}, payload: []byte("hello")}},
+		{"16 bits length", wsFrame{opcode: wsBinary, payload: bytes.Repeat([]byte("a"), 300)}},
+		{"64 bits length", wsFrame{fin: true, opcode: wsText, masked: true, mask: [4]byte{9, 8, 7, 6}, payload: bytes.Repeat([]byte("b"), 70000)}},
+		{"empty with rsv", wsFrame{fin: true, rsv: 0x40, opcode: wsText, payload: []byte{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := tt.frame.encode()
+
+			if fr, n := parseWSFrame(encoded[:len(encoded)-1]); fr != nil || n != 0 {
+				t.Errorf("parseWSFrame() of an incomplete frame = %v, %d", fr, n)
+			}
+
+			got, n := parseWSFrame(append(encoded, 0x81))
+			if n != len(encoded) {
+				t.Fatalf("parseWSFrame() length = %d, want %d", n, len(encoded))
+			}
+
+			if got.fin != tt.frame.fin || got.rsv != tt.frame.rsv || got.opcode != tt.frame.opcode ||
+				got.masked != tt.frame.masked || got.mask != tt.frame.mask || !bytes.Equal(got.payload, tt.frame.payload) {
+				t.Errorf("parseWSFrame() = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+}
+
+func Test_wsDirection_process(t *testing.T) {
+	mask := [4]byte{0x11, 0x22, 0x33, 0x44}
+	text := func(fin bool, opcode byte, payload string) []byte {
+		return (&wsFrame{fin: fin, opcode: opcode, masked: true, mask: mask, payload: []byte(payload)}).encode()
+	}
+	ping := (&wsFrame{fin: true, opcode: 0x9, payload: []byte("ping")}).encode()
+	binaryFrame := (&wsFrame{fin: true, opcode: wsBinary, payload: []byte("book")}).encode()
+	compressed := (&wsFrame{fin: true, rsv: 0x40, opcode: wsText, payload: []byte("book")}).encode()
+
+	tests := []struct {
+		name         string
+		chunks       [][]byte
+		want         []byte
+		wantFrames   int64
+		wantMessages int64
+	}{
+		{
+			"text",
+			[][]byte{text(true, wsText, "a book")},
+			text(true, wsText, "a novel"),
+			1,
+			1,
+		},
+		{
+			"frame split in several writes",
+			[][]byte{text(true, wsText, "a book")[:3], text(true, wsText, "a book")[3:]},
+			text(true, wsText, "a novel"),
+			1,
+			1,
+		},
+		{
+			"fragmented message with a control frame",
+			[][]byte{
+				text(false, wsText, "a bo"),
+				ping,
+				text(false, wsContinuation, "ok and a "),
+				text(true, wsContinuation, "book"),
+			},
+			append(append([]byte{}, ping...), text(true, wsText, "a novel and a novel")...),
+			4,
+			1,
+		},
+		{
+			"binary and compressed frames",
+			[][]byte{binaryFrame, compressed},
+			append(append([]byte{}, binaryFrame...), compressed...),
+			2,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &wsDirection{filter: func(s string) string { return strings.ReplaceAll(s, "book", "novel") }}
+
+			var got []byte
+
+			for _, chunk := range tt.chunks {
+				out, err := d.process(chunk)
+				if err != nil {
+					t.Fatalf("wsDirection.process() error = %v", err)
+				}
+
+				got = append(got, out...)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("wsDirection.process() = %q, want %q", got, tt.want)
+			}
+
+			if d.frames.Load() != tt.wantFrames || d.messages.Load() != tt.wantMessages {
+				t.Errorf("wsDirection counts = %d frames %d messages, want %d %d",
+					d.frames.Load(), d.messages.Load(), tt.wantFrames, tt.wantMessages)
+			}
+		})
+	}
+}
+
+func Test_wsDirection_processStream(t *testing.T) {
+	d := &wsDirection{filter: func(s string) string { return strings.ReplaceAll(s, "book", "novel") }}
+	binaryFrame := (&wsFrame{fin: true, opcode: wsBinary, payload: bytes.Repeat([]byte("b"), 100000)}).encode()
+	text := (&wsFrame{fin: true, opcode: wsText, payload: []byte("a book")}).encode()
+
+	// The payload of a binary frame is forwarded as soon as it is received, without being kept
+	var got []byte
+
+	for _, chunk := range [][]byte{binaryFrame[:5], binaryFrame[5:1000], append(binaryFrame[1000:], text[:3]...), text[3:]} {
+		out, err := d.process(chunk)
+		if err != nil {
+			t.Fatalf("wsDirection.process() error = %v", err)
+		}
+
+		got = append(got, out...)
+
+		if len(d.in) > len(text) {
+			t.Errorf("wsDirection.process() kept %d bytes", len(d.in))
+		}
+	}
+
+	want := append(append([]byte{}, binaryFrame...), (&wsFrame{fin: true, opcode: wsText, payload: []byte("a novel")}).encode()...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("wsDirection.process() = %d bytes, want %d", len(got), len(want))
+	}
+
+	if d.frames.Load() != 2 || d.messages.Load() != 1 {
+		t.Errorf("wsDirection counts = %d frames %d messages, want 2 1", d.frames.Load(), d.messages.Load())
+	}
+}
+
+func Test_wsDirection_processTooLarge(t *testing.T) {
+	header := func(opcode byte, length uint64) []byte {
+		return binary.BigEndian.AppendUint64([]byte{0x80 | opcode, 127}, length)
+	}
+
+	tests := []struct {
+		name    string
+		message *wsFrame
+		data    []byte
+		wantErr error
+	}{
+		{"text frame", nil, header(wsText, wsMaxMessageSize+1), errWSMessageTooLarge},
+		{
+			"fragmented message",
+			&wsFrame{opcode: wsText, payload: make([]byte, wsMaxMessageSize)},
+			header(wsContinuation, 1),
+			errWSMessageTooLarge,
+		},
+		{"binary frame", nil, header(wsBinary, wsMaxMessageSize+1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &wsDirection{message: tt.message, filter: func(s string) string { return s }}
+
+			// The length is verified before receiving the payload
+			if _, err := d.process(tt.data); err != tt.wantErr {
+				t.Errorf("wsDirection.process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// wsUpstream is an upstream connection answering the data of from and recording the data written.
+type wsUpstream struct {
+	from    io.Reader
+	written bytes.Buffer
+}
+
+func (u *wsUpstream) Read(p []byte) (int, error)  { return u.from.Read(p) }
+func (u *wsUpstream) Write(p []byte) (int, error) { return u.written.Write(p) }
+func (u *wsUpstream) Close() error                { return nil }
+
+func Test_wsConnTooLarge(t *testing.T) {
+	tooLarge := binary.BigEndian.AppendUint64([]byte{0x81, 127}, wsMaxMessageSize+1)
+	ping := (&wsFrame{fin: true, opcode: 0x9, payload: []byte("ping")}).encode()
+	closeStatus := func(b []byte) (byte, bool, uint16) {
+		fr, n := parseWSFrame(b)
+		if n == 0 || len(fr.payload) != 2 {
+			return 0, false, 0
+		}
+
+		return fr.opcode, fr.masked, binary.BigEndian.Uint16(fr.payload)
+	}
+
+	t.Run("from the upstream", func(t *testing.T) {
+		log, hook := logrustest.NewNullLogger()
+		upstream := &wsUpstream{from: bytes.NewReader(append(append([]byte{}, ping...), tooLarge...))}
+		c := &wsConn{upstream: upstream, fromClient: &wsDirection{}, toClient: &wsDirection{}, log: log}
+
+		got, err := io.ReadAll(c)
+		if err != nil || !bytes.HasPrefix(got, ping) {
+			t.Fatalf("wsConn.Read() = %q, %v", got, err)
+		}
+
+		if opcode, masked, status := closeStatus(got[len(ping):]); opcode != wsClose || masked || status != wsStatusTooBig {
+			t.Errorf("wsConn.Read() close frame = %x", got[len(ping):])
+		}
+
+		if !HadErrorLevel(hook, logrus.WarnLevel) {
+			t.Errorf("wsConn.Read() did not warn about the message too large")
+		}
+	})
+
+	t.Run("from the client", func(t *testing.T) {
+		log, _ := logrustest.NewNullLogger()
+		upstream := &wsUpstream{from: bytes.NewReader(nil)}
+		c := &wsConn{upstream: upstream, fromClient: &wsDirection{}, toClient: &wsDirection{}, log: log}
+
+		for _, chunk := range [][]byte{ping, tooLarge, []byte("payload")} {
+			if n, err := c.Write(chunk); n != len(chunk) || err != nil {
+				t.Fatalf("wsConn.Write() = %d, %v", n, err)
+			}
+		}
+
+		got := upstream.written.Bytes()
+		if !bytes.HasPrefix(got, ping) {
+			t.Fatalf("wsConn.Write() sent %q", got)
+		}
+
+		// The data after the message too large is not forwarded
+		if opcode, masked, status := closeStatus(got[len(ping):]); opcode != wsClose || !masked || status != wsStatusTooBig ||
+			len(got) != len(ping)+len(wsCloseFrame(wsStatusTooBig, true)) {
+			t.Errorf("wsConn.Write() sent %x after the ping", got[len(ping):])
+		}
+	})
+}
+
+// websocketBackend answers to the upgrade and sends back each text message received prefixed by echo.
+func websocketBackend(t *testing.T, extensions chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extensions <- r.Header.Get("Sec-WebSocket-Extensions")
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack error = %v", err)
+
+			return
+		}
+		defer conn.Close()
+
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+
+		buf := []byte{}
+		chunk := make([]byte, 1024)
+
+		for {
+			n, err := rw.Read(chunk)
+			if err != nil {
+				return
+			}
+
+			buf = append(buf, chunk[:n]...)
+
+			fr, used := parseWSFrame(buf)
+			if used == 0 {
+				continue
+			}
+
+			buf = buf[used:]
+			echo := &wsFrame{fin: true, opcode: wsText, payload: append([]byte("echo: "), fr.payload...)}
+
+			_, _ = conn.Write(echo.encode())
+		}
+	}))
+}
+
+func TestFilter_ServeWebSocket(t *testing.T) {
+	tests := []struct {
+		name           string
+		websocket      string
+		wantMessage    string
+		wantExtensions string
+		wantLog        string
+	}{
+		{
+			"filter",
+			websocketFilter,
+			"ECHO: a novel",
+			"",
+			"WebSocket closed after 1 frames (1 text messages filtered) from the client, 1 frames (1 text messages filtered) from the upstream",
+		},
+		{
+			"passthrough",
+			"",
+			"echo: a book",
+			"permessage-deflate",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extensions := make(chan string, 1)
+			backend := websocketBackend(t, extensions)
+			defer backend.Close()
+
+			log, hook := logrustest.NewNullLogger()
+
+			f := &Filter{
+				contentTypes: []string{"text/html"},
+				request:      request{Replace: []replaceParameters{{from: "book", to: "novel"}}},
+				response:     response{Replace: []replaceParameters{{from: "echo", to: "ECHO"}}},
+				url:          backend.URL,
+				websocket:    tt.websocket,
+				log:          log,
+			}
+
+			front := httptest.NewServer(http.HandlerFunc(f.Serve))
+			defer front.Close()
+
+			u, _ := url.Parse(front.URL)
+
+			conn, err := net.Dial("tcp", u.Host)
+			if err != nil {
+				t.Fatalf("Dial error = %v", err)
+			}
+			defer conn.Close()
+
+			_, _ = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: " + u.Host + "\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+				"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+				"Sec-WebSocket-Extensions: permessage-deflate\r\n\r\n"))
+
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			reader := bufio.NewReader(conn)
+
+			res, err := http.ReadResponse(reader, nil)
+			if err != nil || res.StatusCode != http.StatusSwitchingProtocols {
+				t.Fatalf("Upgrade response = %v, %v", res, err)
+			}
+
+			if got := <-extensions; got != tt.wantExtensions {
+				t.Errorf("Sec-WebSocket-Extensions = %q, want %q", got, tt.wantExtensions)
+			}
+
+			message := &wsFrame{fin: true, opcode: wsText, masked: true, mask: [4]byte{1, 2, 3, 4}, payload: []byte("a book")}
+			_, _ = conn.Write(message.encode())
+
+			buf := []byte{}
+
+			for {
+				b, err := reader.ReadByte()
+				if err != nil {
+					t.Fatalf("Read error = %v", err)
+				}
+
+				buf = append(buf, b)
+
+				if fr, n := parseWSFrame(buf); n > 0 {
+					if string(fr.payload) != tt.wantMessage {
+						t.Errorf("Message = %q, want %q", fr.payload, tt.wantMessage)
+					}
+
+					break
+				}
+			}
+
+			conn.Close()
+
+			if tt.wantLog == "" {
+				return
+			}
+
+			// The connection is closed asynchronously by the proxy
+			for i := 0; i < 100 && !hasLogged(hook, tt.wantLog); i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			if !hasLogged(hook, "WebSocket opened") || !hasLogged(hook, tt.wantLog) {
+				t.Errorf("Filter.Serve() logs do not contain the WebSocket opening and %q", tt.wantLog)
+			}
+		})
+	}
+}
+
+func hasLogged(hook *logrustest.Hook, message string) bool {
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.InfoLevel && entry.Message == message {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestFilter_dumpWebSocket(t *testing.T) {
+	oldGenerateID := _generateID
+	_generateID = func() (string, error) { return "ws1", nil }
+
+	defer func() { _generateID = oldGenerateID }()
+
+	log, hook := logrustest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	f := &Filter{log: log}
+
+	if id := f.dumpWebSocket("", "/ws", "websocketOpen", http.Header{"Upgrade": {"websocket"}}, ""); id != "ws1" {
+		t.Errorf("Filter.dumpWebSocket() = %s, want ws1", id)
+	}
+
+	f.dumpWebSocket("ws1", "/ws", "websocketClose", http.Header{}, "2 frames")
+
+	verifyLogged("Filter.dumpWebSocket", []string{"Upgrade: websocket", "", "2 frames"}, hook, t)
+
+	entries := hook.AllEntries()
+	if entries[len(entries)-1].Data["response-type"] != "websocketClose" {
+		t.Errorf("Filter.dumpWebSocket() type = %v", entries[len(entries)-1].Data["response-type"])
+	}
+
+}
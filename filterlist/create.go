@@ -7,6 +7,7 @@ import (
 	"sort"
 
 	"github.com/marema31/villip/filter"
+	"github.com/marema31/villip/health"
 	"github.com/marema31/villip/server"
 	"github.com/marema31/villip/server/http"
 	"github.com/marema31/villip/server/tcp"
@@ -17,6 +18,8 @@ import (
 type List struct {
 	filters map[string]map[uint8][]filter.FilteredServer
 	factory filter.Creator
+	// Remove the states of the filters from the health server
+	unregister []func()
 	// Make os.LookupEnv mockable for unit test.
 	lookupEnv func(string) (string, bool)
 }
@@ -57,7 +60,12 @@ func sortFilter(filters map[uint8][]filter.FilteredServer) []filter.FilteredServ
 	sort.Slice(priorities, func(i, j int) bool { return priorities[i] > priorities[j] })
 
 	for _, priority := range priorities {
+		start := len(fl)
 		fl = append(fl, filters[priority]...)
+
+		// Within a priority, the filters matching the longest paths first, the others keep their insertion order
+		same := fl[start:]
+		sort.SliceStable(same, func(i, j int) bool { return same[i].RouteSpecificity() > same[j].RouteSpecificity() })
 	}
 
 	return fl
@@ -98,6 +106,39 @@ func (fl *List) CreateServers(upLog logrus.FieldLogger) map[string]server.Server
 	return servers
 }
 
+// Start starts the health checks of the filters and registers their states on the health server, it must be called
+// once all the configurations are read.
+func (fl *List) Start() {
+	for _, priorities := range fl.filters {
+		for _, filters := range priorities {
+			for _, f := range filters {
+				f.Start()
+
+				for path, status := range f.Statuses() {
+					fl.unregister = append(fl.unregister, health.Register(path, status))
+				}
+			}
+		}
+	}
+}
+
+// Stop stops the health checks of the filters and removes their states from the health server.
+func (fl *List) Stop() {
+	for _, priorities := range fl.filters {
+		for _, filters := range priorities {
+			for _, f := range filters {
+				f.Stop()
+			}
+		}
+	}
+
+	for _, unregister := range fl.unregister {
+		unregister()
+	}
+
+	fl.unregister = nil
+}
+
 func (fl *List) readConfigFiles(upLog logrus.FieldLogger, folderPath string, recurse bool) {
 
 	files, err := os.ReadDir(folderPath)
@@ -143,6 +143,14 @@ func TestList_insert(t *testing.T) {
 	}
 }
 
+// pathMock returns a mock matching the paths starting with the prefix.
+func pathMock(position int, path string, t *testing.T) *filter.Mock {
+	m := filter.NewMock(filter.HTTP, position, true, path != "", "", http.Header{}, "", http.Header{}, t)
+	m.Path = path
+
+	return m
+}
+
 func Test_sortFilter(t *testing.T) {
 	type args struct {
 		filters map[uint8][]filter.FilteredServer
@@ -166,6 +174,24 @@ func Test_sortFilter(t *testing.T) {
 				},
 			},
 		},
+		{
+			"Paths",
+			args{
+				map[uint8][]filter.FilteredServer{
+					1: {
+						pathMock(5, "/static/", t),
+						pathMock(6, "", t),
+					},
+					10: {
+						pathMock(2, "/api/", t),
+						filter.NewMock(filter.HTTP, 3, true, true, "", http.Header{}, "", http.Header{}, t),
+						pathMock(0, "/api/v2/", t),
+						pathMock(1, "/books", t),
+						pathMock(4, "", t),
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -272,6 +298,43 @@ func TestList_CreateServers(t *testing.T) {
 	}
 }
 
+func TestList_StartStop(t *testing.T) {
+	mocks := []*filter.Mock{
+		filter.NewMock(filter.HTTP, 0, false, false, "", http.Header{}, "", http.Header{}, t),
+		filter.NewMock(filter.HTTP, 1, false, false, "", http.Header{}, "", http.Header{}, t),
+		filter.NewMock(filter.TCP, 0, false, false, "", http.Header{}, "", http.Header{}, t),
+	}
+
+	fl := New()
+	fl.insert("8080", 10, mocks[0])
+	fl.insert("8080", 5, mocks[1])
+	fl.insert("8088", 10, mocks[2])
+
+	fl.Start()
+
+	for i, m := range mocks {
+		if !m.Started {
+			t.Errorf("List.Start() did not start the filter %d", i)
+		}
+	}
+
+	if len(fl.unregister) != len(mocks) {
+		t.Errorf("List.Start() registered %d states, want %d", len(fl.unregister), len(mocks))
+	}
+
+	fl.Stop()
+
+	for i, m := range mocks {
+		if m.Started {
+			t.Errorf("List.Stop() did not stop the filter %d", i)
+		}
+	}
+
+	if len(fl.unregister) != 0 {
+		t.Errorf("List.Stop() kept %d states", len(fl.unregister))
+	}
+}
+
 func TestList_readConfigFiles(t *testing.T) {
 	type fields struct {
 		filters map[string]map[uint8][]filter.FilteredServer
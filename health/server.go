@@ -1,14 +1,79 @@
 package health
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+// StatusFunc returns the current state of a component of the proxy, it is served in JSON.
+type StatusFunc func() interface{}
+
+var (
+	statusMu sync.RWMutex
+	statuses = map[string][]*StatusFunc{} //nolint: gochecknoglobals
+)
+
+// Register adds the state of a component to the list served on the path of the health endpoint, the returned
+// function removes it.
+func Register(path string, status StatusFunc) func() {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	entry := &status
+	statuses[path] = append(statuses[path], entry)
+
+	return func() { unregister(path, entry) }
+}
+
+func unregister(path string, entry *StatusFunc) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	funcs := statuses[path]
+
+	for i, status := range funcs {
+		if status == entry {
+			// A new slice, the handlers may still be using the old one
+			funcs = append(funcs[:i:i], funcs[i+1:]...)
+
+			break
+		}
+	}
+
+	if len(funcs) == 0 {
+		delete(statuses, path)
+
+		return
+	}
+
+	statuses[path] = funcs
+}
+
 func healthz(w http.ResponseWriter, req *http.Request) {
-	fmt.Fprintf(w, "OK\n")
+	statusMu.RLock()
+	funcs, ok := statuses[req.URL.Path]
+	statusMu.RUnlock()
+
+	if !ok {
+		fmt.Fprintf(w, "OK\n")
+
+		return
+	}
+
+	states := make([]interface{}, 0, len(funcs))
+	for _, status := range funcs {
+		states = append(states, (*status)())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(states); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // Start an health endpoint.
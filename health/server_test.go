@@ -0,0 +1,43 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_healthz(t *testing.T) {
+	t.Cleanup(Register("/test-states", func() interface{} { return map[string]bool{"healthy": true} }))
+	unregister := Register("/test-states", func() interface{} { return "removed" })
+	t.Cleanup(Register("/test-states", func() interface{} { return "second" }))
+	unregister()
+
+	removeAll := Register("/test-removed", func() interface{} { return "removed" })
+	removeAll()
+
+	tests := []struct {
+		name            string
+		path            string
+		wantBody        string
+		wantContentType string
+	}{
+		{"health", "/", "OK\n", "text/plain; charset=utf-8"},
+		{"unknown path", "/healthz", "OK\n", "text/plain; charset=utf-8"},
+		{"states", "/test-states", "[{\"healthy\":true},\"second\"]\n", "application/json"},
+		{"all states removed", "/test-removed", "OK\n", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			healthz(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("healthz() body = %q, want %q", got, tt.wantBody)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("healthz() Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+		})
+	}
+}
@@ -30,6 +30,8 @@ func main() {
 		log.Fatal("No filter configuration provided")
 	}
 
+	filters.Start()
+
 	g := new(errgroup.Group)
 
 	for _, s := range servers {
@@ -44,7 +46,11 @@ func main() {
 
 	g.Go(func() error { return health.Serve(log, healthPort) })
 
-	if err := g.Wait(); err != nil {
+	err := g.Wait()
+
+	filters.Stop()
+
+	if err != nil {
 		log.Fatalf("One server exiting in error: %v", err)
 	}
 }
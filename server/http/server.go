@@ -46,7 +46,7 @@ func (s *Server) ConditionalProxy(res http.ResponseWriter, req *http.Request) {
 	ip := net.ParseIP(sip)
 
 	for _, f := range s.filters {
-		if f.IsConcerned(ip, req.Header) {
+		if f.IsConcerned(ip, req.Header) && f.Matches(req) {
 			f.Serve(res, req)
 
 			return
@@ -295,3 +295,43 @@ func TestServer_ConditionalProxy(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_ConditionalProxyPaths(t *testing.T) {
+	newMock := func(path string, resBody string) *filter.Mock {
+		m := filter.NewMock(filter.HTTP, 0, true, true, "", http.Header{}, resBody, http.Header{}, t)
+		m.Path = path
+
+		return m
+	}
+
+	log, _ := logrustest.NewNullLogger()
+
+	// Inserted like sorted by the filter list, the most specific path first
+	s := server.New(log, "64535", newMock("/api/v2/", "api v2"))
+	s.Insert(newMock("/api/", "api"))
+	s.Insert(newMock("/static/", "static"))
+
+	tests := []struct {
+		path       string
+		wantBody   string
+		wantStatus int
+	}{
+		{"/api/v2/books", "api v2", http.StatusOK},
+		{"/api/v1/books", "api", http.StatusOK},
+		{"/static/logo.png", "static", http.StatusOK},
+		{"/", "No filter correspond to this requests\n", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, strings.NewReader(""))
+			req.RemoteAddr = "192.168.1.2:65432"
+
+			res := httptest.NewRecorder()
+			s.ConditionalProxy(res, req)
+
+			if res.Code != tt.wantStatus || res.Body.String() != tt.wantBody {
+				t.Errorf("ConditionalProxy() = %d %q, want %d %q", res.Code, res.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}